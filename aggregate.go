@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	commandAggregate = "aggregate"
+	formatCSV        = "csv"
+	formatGantt      = "gantt"
+	formatHTML       = "html"
+	formatICS        = "ics"
+	formatJiraCSV    = "jira-csv"
+	formatJSON       = "json"
+	formatKanban     = "kanban"
+	formatMarkdown   = "markdown"
+	formatTaskPaper  = "taskpaper"
+	formatTodoTxt    = "todotxt"
+	formatTSV        = "tsv"
+	// modeOverwrite replaces the output file with each run's report, and
+	// modeAppendSnapshot appends a dated summary of each run to it instead.
+	modeAppendSnapshot = "append-dated-snapshot"
+	modeOverwrite      = "overwrite"
+	// stdoutFilename as the output file writes the report to stdout.
+	stdoutFilename = "-"
+)
+
+// defaultOutputFilenames maps each output format to the file written when no
+// output filename is given.
+var defaultOutputFilenames = map[string]string{
+	formatCSV:       "tasks.csv",
+	formatGantt:     "gantt.md",
+	formatHTML:      "tasks.html",
+	formatICS:       "tasks.ics",
+	formatJiraCSV:   "jira.csv",
+	formatJSON:      "tasks.json",
+	formatKanban:    "kanban.md",
+	formatMarkdown:  tasks.DefaultOutputFilename,
+	formatTaskPaper: "tasks.taskpaper",
+	formatTodoTxt:   "todo.txt",
+	formatTSV:       "tasks.tsv",
+}
+
+// modes lists the ways output files can be written.
+var modes = []string{modeOverwrite, modeAppendSnapshot}
+
+// formats lists the output formats in the order they're documented.
+var formats = []string{formatMarkdown, formatHTML, formatJSON, formatCSV, formatTSV, formatICS, formatTodoTxt, formatTaskPaper, formatKanban, formatGantt, formatJiraCSV}
+
+func setupAggregate(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineAggregateFlags(flags, &options)
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		outputs, err := options.outputs(flags)
+		if err != nil {
+			return err
+		}
+		if options.Watch && contains(options.Roots, stdinPath) {
+			return fmt.Errorf("-watch can't be used when reading from stdin")
+		}
+		if options.Watch && options.DryRun {
+			return fmt.Errorf("-watch can't be used with -dry-run")
+		}
+		if err := checkNotifyURLs(options.Notify); err != nil {
+			return err
+		}
+
+		scanned, err := scan(outputs[0])
+		if err != nil {
+			return err
+		}
+		err = aggregate(outputs, scanned)
+		if options.Watch {
+			watch(options, outputFilenames(outputs), func() {
+				regenerate(outputs)
+			}, nil)
+		}
+		return err
+	}
+}
+
+// regenerate scans the roots again and rewrites the outputs, for -watch.
+// Paths that can't be read and outputs that can't be written are logged
+// rather than ending the watch, even with -strict.
+func regenerate(outputs []Options) {
+	scanned, err := scan(outputs[0])
+	if err != nil {
+		slog.Warn("not regenerating", "error", err)
+		return
+	}
+	if err := aggregate(outputs, scanned); err != nil {
+		slog.Error("regenerating", "error", err)
+	}
+}
+
+// defineAggregateFlags defines the flags of the default command, which
+// writes the tasks to output files.
+func defineAggregateFlags(flags *flag.FlagSet, options *Options) {
+	defineScanFlags(flags, options)
+	defineRenderFlags(flags, options)
+	defineGateFlags(flags, options)
+	flags.StringVar(&options.Chart, "chart", "", fmt.Sprintf("chart to add to the top of markdown output, one of %s (default=none)", strings.Join(tasks.ChartOptions, ", ")))
+	flags.StringVar(&options.Columns, "columns", strings.Join(tasks.CSVColumns, ","), "comma-separated columns to output in csv or tsv format")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "true to print a unified diff of the changes to each output file instead of writing it (default=false)")
+	flags.StringVar(&options.Format, "format", formatMarkdown, fmt.Sprintf("output format, one of %s (default=%s)", strings.Join(formats, ", "), formatMarkdown))
+	flags.StringVar(&options.ICSComponent, "ics-component", tasks.ICSTodo, fmt.Sprintf("what ics output writes each task as, one of %s (default=%s)", strings.Join(tasks.ICSComponentOptions, ", "), tasks.ICSTodo))
+	flags.StringVar(&options.Mode, "mode", modeOverwrite, fmt.Sprintf("how to write each output file, one of %s, where %s appends the date, counts, and tasks completed since the last run to it as a running journal instead of replacing it (default=%s)", strings.Join(modes, ", "), modeAppendSnapshot, modeOverwrite))
+	flags.Var(&options.Notify, "notify", "slack://<webhook> or discord://<webhook> URL to post the tasks added, completed, and newly overdue since the last run to, may be repeated")
+	flags.Var(&options.Outputs, "o", fmt.Sprintf("name of file to output, or - for stdout, may be repeated with settings for one output after a ?, as in OPEN.md?incomplete-only&group-by=tag (default=%s, or tasks.<format> for other formats)", tasks.DefaultOutputFilename))
+	flags.BoolVar(&options.PerDirectory, "per-directory", false, "true to also write an output file into each top-level subdirectory of the roots with only the tasks under it (default=false)")
+	flags.BoolVar(&options.PerDirectoryOnly, "per-directory-only", false, "true to write only the per-directory output files, not the aggregate of all tasks (default=false)")
+	flags.BoolVar(&options.RelativeLinks, "relative-links", false, "true to write links relative to the output file's directory rather than to the root, for reports written outside it (default=false)")
+	flags.BoolVar(&options.SplitByAssignee, "split-by-assignee", false, "true to also write an output file for each person tasks are assigned to, named like TASKS-alice.md, with only their tasks (default=false)")
+	flags.BoolVar(&options.Watch, "watch", false, "true to keep running and regenerate the output when markdown files change (default=false)")
+}
+
+// aggregate writes the tasks scanned once from the roots to each output,
+// returning the error of the first output that couldn't be rendered or
+// written, or else of the first whose gates they fail. An output failing
+// doesn't stop the others being written. The changes to the tasks kept by the
+// first output are then posted to any -notify webhooks.
+func aggregate(outputs []Options, scanned tasks.Tasks) error {
+	var writeErr, gateErr error
+	for _, options := range outputs {
+		aggregated := options.arrange(scanned)
+		written := []error{}
+		if options.PerDirectory {
+			written = append(written, writePerDirectory(aggregated, options))
+		}
+		if options.SplitByAssignee {
+			written = append(written, writePerAssignee(aggregated, options))
+		}
+		if !options.PerDirectoryOnly {
+			aggregated.LinkBase, _ = options.linkBase()
+			written = append(written, writeToFile(aggregated, options))
+		}
+		for _, err := range written {
+			if err != nil && writeErr == nil {
+				writeErr = err
+			}
+		}
+		if err := options.checkGates(aggregated); err != nil && gateErr == nil {
+			gateErr = err
+		}
+	}
+	if first := outputs[0]; len(first.Notify) > 0 && !first.DryRun {
+		notify(tasks.Flatten(first.arrange(scanned).Tasks), first.Notify)
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	return gateErr
+}
+
+// writePerDirectory writes an output file, named like the aggregate, into
+// each top-level subdirectory of the roots holding tasks, with only the tasks
+// under it and links relative to it. It returns the first error writing one,
+// after writing the rest.
+func writePerDirectory(aggregated tasks.Tasks, options Options) error {
+	var firstErr error
+	for _, root := range options.Roots {
+		if root == stdinPath {
+			continue
+		}
+		// with several roots, file paths start with the root's name, as
+		// FileMeta.RelativePath writes them
+		prefix := ""
+		if base := filepath.Base(root); len(options.Roots) > 1 && base != "." {
+			prefix = base + "/"
+		}
+
+		byDirectory := map[string][]tasks.Task{}
+		directories := []string{}
+		for _, task := range aggregated.Tasks {
+			relPath := strings.TrimPrefix(task.FilePath, prefix)
+			directory, _, nested := strings.Cut(relPath, "/")
+			if !strings.HasPrefix(task.FilePath, prefix) || !nested {
+				continue
+			}
+			if _, ok := byDirectory[directory]; !ok {
+				directories = append(directories, directory)
+			}
+			byDirectory[directory] = append(byDirectory[directory], task)
+		}
+
+		for _, directory := range directories {
+			// a root named . has no prefix, so tasks from other roots
+			// can look like they are in its subdirectories
+			if info, err := os.Stat(filepath.Join(root, directory)); err != nil || !info.IsDir() {
+				continue
+			}
+			subtree := aggregated
+			subtree.Tasks = trimFilePaths(byDirectory[directory], prefix+directory+"/")
+			directoryOptions := options
+			directoryOptions.OutputFilename = filepath.Join(root, directory, filepath.Base(options.OutputFilename))
+			if err := writeToFile(subtree, directoryOptions); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// writePerAssignee writes an output file for each person the tasks are
+// assigned to, named after the aggregate with the person's name added, with
+// only the tasks assigned to them. It returns the first error writing one,
+// after writing the rest.
+func writePerAssignee(aggregated tasks.Tasks, options Options) error {
+	assignees := []string{}
+	for _, task := range tasks.Flatten(aggregated.Tasks) {
+		for _, assignee := range task.Assignees {
+			if !contains(assignees, strings.ToLower(assignee)) {
+				assignees = append(assignees, strings.ToLower(assignee))
+			}
+		}
+	}
+	sort.Strings(assignees)
+
+	var firstErr error
+	ext := filepath.Ext(options.OutputFilename)
+	for _, assignee := range assignees {
+		assigneeOptions := options
+		name := strings.ReplaceAll(assignee, "/", "-")
+		assigneeOptions.OutputFilename = fmt.Sprintf("%s-%s%s", strings.TrimSuffix(options.OutputFilename, ext), name, ext)
+		if err := writeToFile(aggregated.Filter(tasks.Filter{Assignees: []string{assignee}}), assigneeOptions); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// trimFilePaths removes the prefix from the file paths of the tasks and their
+// subtasks.
+func trimFilePaths(all []tasks.Task, prefix string) []tasks.Task {
+	trimmed := []tasks.Task{}
+	for _, task := range all {
+		task.FilePath = strings.TrimPrefix(task.FilePath, prefix)
+		task.Subtasks = trimFilePaths(task.Subtasks, prefix)
+		trimmed = append(trimmed, task)
+	}
+	return trimmed
+}
+
+func (options Options) columns() []string {
+	return strings.Split(options.Columns, ",")
+}
+
+// tableColumns returns the columns of -layout table, or none to use the
+// defaults.
+func (options Options) tableColumns() []string {
+	if options.TableColumns == "" {
+		return nil
+	}
+	return strings.Split(options.TableColumns, ",")
+}
+
+// readTemplate returns the text of the template file, or an empty string for
+// the built-in template when no file is given.
+func readTemplate(templateFilename string) (string, error) {
+	if templateFilename == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(templateFilename)
+	return string(data), err
+}
+
+func writeHTML(w io.Writer, aggregated tasks.Tasks, templateFilename string) error {
+	templateText, err := readTemplate(templateFilename)
+	if err != nil {
+		return err
+	}
+	return aggregated.WriteHTML(w, templateText)
+}
+
+func writeMarkdown(w io.Writer, aggregated tasks.Tasks, templateFilename string) error {
+	templateText, err := readTemplate(templateFilename)
+	if err != nil {
+		return err
+	}
+	return aggregated.WriteMarkdownTemplate(w, templateText)
+}
+
+func writeToFile(aggregated tasks.Tasks, options Options) error {
+	if options.Mode == modeAppendSnapshot {
+		return appendSnapshot(aggregated, options)
+	}
+
+	var out bytes.Buffer
+	if err := render(&out, aggregated, options); err != nil {
+		return fmt.Errorf("can't render tasks for %s: %w", options.OutputFilename, err)
+	}
+
+	if options.OutputFilename == stdoutFilename {
+		slog.Info("writing tasks to stdout", "incomplete", aggregated.IncompleteCount(), "total", aggregated.TotalCount())
+		if _, err := os.Stdout.Write(out.Bytes()); err != nil {
+			return fmt.Errorf("can't write tasks: %w", err)
+		}
+		return nil
+	}
+
+	if options.DryRun {
+		return previewFile(aggregated, options, out.Bytes())
+	}
+
+	changed, err := tasks.WriteFileAtomic(options.OutputFilename, out.Bytes())
+	if err != nil {
+		return fmt.Errorf("can't write tasks to %s: %w", options.OutputFilename, err)
+	}
+	if !changed {
+		slog.Info("tasks unchanged", "file", options.OutputFilename, "incomplete", aggregated.IncompleteCount(), "total", aggregated.TotalCount())
+		return nil
+	}
+	slog.Info("writing tasks to file", "file", options.OutputFilename, "incomplete", aggregated.IncompleteCount(), "total", aggregated.TotalCount())
+	return nil
+}
+
+// appendSnapshot appends a dated summary of the tasks, listing those
+// completed that earlier snapshots in the output file don't, to the file, or
+// prints it for stdout or -dry-run.
+func appendSnapshot(aggregated tasks.Tasks, options Options) error {
+	history := []byte{}
+	if options.OutputFilename != stdoutFilename {
+		var err error
+		history, err = os.ReadFile(options.OutputFilename)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("can't read snapshots: %w", err)
+		}
+	}
+
+	var out bytes.Buffer
+	if len(history) > 0 {
+		out.WriteString("\n")
+	}
+	if err := aggregated.WriteSnapshot(&out, time.Now(), string(history)); err != nil {
+		return fmt.Errorf("can't render snapshot for %s: %w", options.OutputFilename, err)
+	}
+	if options.OutputFilename == stdoutFilename || options.DryRun {
+		if _, err := os.Stdout.Write(out.Bytes()); err != nil {
+			return fmt.Errorf("can't write snapshot: %w", err)
+		}
+		return nil
+	}
+
+	file, err := os.OpenFile(options.OutputFilename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = file.Write(out.Bytes())
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("can't append snapshot: %w", err)
+	}
+	slog.Info("appending snapshot to file", "file", options.OutputFilename, "incomplete", aggregated.IncompleteCount(), "total", aggregated.TotalCount())
+	return nil
+}
+
+// previewFile prints the changes writing the output file would make as a
+// unified diff, leaving the file as it is.
+func previewFile(aggregated tasks.Tasks, options Options, data []byte) error {
+	oldName := options.OutputFilename
+	existing, err := os.ReadFile(options.OutputFilename)
+	if errors.Is(err, fs.ErrNotExist) {
+		oldName = "/dev/null"
+	} else if err != nil {
+		return fmt.Errorf("can't read tasks: %w", err)
+	}
+
+	diff := unifiedDiff(oldName, options.OutputFilename, string(existing), string(data))
+	if diff == "" {
+		slog.Info("tasks unchanged", "file", options.OutputFilename, "incomplete", aggregated.IncompleteCount(), "total", aggregated.TotalCount())
+		return nil
+	}
+	if _, err := fmt.Print(diff); err != nil {
+		return fmt.Errorf("can't write diff: %w", err)
+	}
+	slog.Info("would write tasks to file", "file", options.OutputFilename, "incomplete", aggregated.IncompleteCount(), "total", aggregated.TotalCount())
+	return nil
+}
+
+// render writes the tasks to w in the output format.
+func render(w io.Writer, aggregated tasks.Tasks, options Options) error {
+	switch options.Format {
+	case formatCSV:
+		return aggregated.WriteCSV(w, options.columns(), ',')
+	case formatGantt:
+		return aggregated.WriteGantt(w)
+	case formatHTML:
+		return writeHTML(w, aggregated, options.Template)
+	case formatICS:
+		return aggregated.WriteICS(w, options.ICSComponent)
+	case formatJiraCSV:
+		return aggregated.WriteJiraCSV(w)
+	case formatJSON:
+		return aggregated.WriteJSON(w)
+	case formatKanban:
+		return aggregated.WriteKanban(w)
+	case formatTaskPaper:
+		return aggregated.WriteTaskPaper(w)
+	case formatTodoTxt:
+		return aggregated.WriteTodoTxt(w)
+	case formatTSV:
+		return aggregated.WriteCSV(w, options.columns(), '\t')
+	default:
+		if renderer, ok := tasks.LookupRenderer(options.Format); ok {
+			return renderer.Render(w, aggregated)
+		}
+		return writeMarkdown(w, aggregated, options.Template)
+	}
+}
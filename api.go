@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// httpClient makes every request to the APIs and webhooks, with a timeout so
+// a server that stops answering can't hang a run or a watch.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// apiRequest calls a JSON API authorized by the bearer token, when there is
+// one, sending body as JSON unless it's nil and reading the response into
+// result unless it's nil. Responses other than success are returned as errors
+// with the message the API gave, and the response headers are returned for
+// APIs that page through results.
+func apiRequest(method, url, token string, body, result interface{}) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		failure := struct {
+			Message string `json:"message"`
+		}{}
+		if json.Unmarshal(data, &failure) != nil || failure.Message == "" {
+			failure.Message = strings.TrimSpace(string(data))
+		}
+		return nil, fmt.Errorf("%s %s: %s %s", method, request.URL.Path, response.Status, failure.Message)
+	}
+	if result != nil && response.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+			return nil, err
+		}
+	}
+	return response.Header, nil
+}
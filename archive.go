@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	commandArchive = "archive"
+	// archiveSuffix ends the name of the archive written beside each note
+	// with -per-file, as in notes.archive.md.
+	archiveSuffix = ".archive.md"
+)
+
+func setupArchive(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	flags.StringVar(&options.AnchorStyle, "anchor-style", tasks.AnchorGitHub, fmt.Sprintf("how links back to each task's note point to its header, one of %s (default=%s)", strings.Join(tasks.AnchorStyleOptions, ", "), tasks.AnchorGitHub))
+	days := flags.Int("days", 30, "move tasks completed more than this many days ago, by their completion date or else their date (default=30)")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "true to list the tasks that would be archived without moving them (default=false)")
+	flags.StringVar(&options.OutputFilename, "o", "ARCHIVE.md", "name of the file to move completed tasks into (default=ARCHIVE.md)")
+	perFile := flags.Bool("per-file", false, fmt.Sprintf("true to move each note's tasks into an archive beside it, named like notes%s, instead (default=false)", archiveSuffix))
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		if *days < 0 {
+			return fmt.Errorf("-days can't be negative")
+		}
+		if options.OutputFilename == stdoutFilename {
+			return fmt.Errorf("archive can't move tasks to stdout")
+		}
+		return archive(options, time.Now().AddDate(0, 0, -*days), *perFile)
+	}
+}
+
+// archive moves the completed tasks completed before the cutoff, with their
+// subtasks, out of their notes and into the archive file, or with perFile an
+// archive beside each note. Archives are never archived themselves.
+func archive(options Options, cutoff time.Time, perFile bool) error {
+	files, err := sourceFiles(options)
+	if err != nil {
+		return err
+	}
+	sourceBase, err := options.sourceBase()
+	if err != nil {
+		return err
+	}
+	globalArchive, err := filepath.Abs(options.OutputFilename)
+	if err != nil {
+		return err
+	}
+
+	displayPaths := []string{}
+	for displayPath := range files {
+		displayPaths = append(displayPaths, displayPath)
+	}
+	sort.Strings(displayPaths)
+
+	archived := 0
+	for _, displayPath := range displayPaths {
+		file := files[displayPath]
+		filePath, err := filepath.Abs(file.Path)
+		if err != nil {
+			return err
+		}
+		if filePath == globalArchive || strings.HasSuffix(filePath, archiveSuffix) {
+			continue
+		}
+
+		parsed, err := tasks.ParseFilePath(file, options.parseOptions())
+		// tasks on lines too long to read are left where they are
+		var longLines *tasks.LongLinesError
+		if err != nil && !errors.As(err, &longLines) {
+			return err
+		}
+		archivable := tasks.Archivable(parsed.Tasks, cutoff)
+		if len(archivable) == 0 {
+			continue
+		}
+
+		archivePath := globalArchive
+		if perFile {
+			archivePath = strings.TrimSuffix(filePath, filepath.Ext(filePath)) + archiveSuffix
+		}
+		lines := []int{}
+		for _, task := range archivable {
+			fmt.Printf("%s (%s:%d)\n", task.Text, file.Path, task.Line)
+			lines = append(lines, task.Line)
+		}
+		archived += len(archivable)
+		if options.DryRun {
+			continue
+		}
+
+		linkBase, err := filepath.Rel(filepath.Dir(archivePath), sourceBase)
+		if err != nil {
+			return err
+		}
+		archiveTasks := tasks.Tasks{AnchorStyle: options.AnchorStyle, LinkBase: filepath.ToSlash(linkBase), Tasks: archivable}
+		err = tasks.CutTasks(file.Path, lines, func(blocks []string) error {
+			return appendArchive(archivePath, archiveTasks, blocks)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.DryRun {
+		fmt.Printf("%d tasks would be archived\n", archived)
+		return nil
+	}
+	fmt.Printf("%d tasks archived\n", archived)
+	return nil
+}
+
+// appendArchive adds the archived tasks to the end of the archive file,
+// creating it with a title when it doesn't exist.
+func appendArchive(archivePath string, archived tasks.Tasks, blocks []string) error {
+	_, err := os.Stat(archivePath)
+	isNew := errors.Is(err, fs.ErrNotExist)
+	file, err := os.OpenFile(archivePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if isNew {
+		if _, err := fmt.Fprintln(file, "# Archive"); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := archived.WriteArchive(file, blocks); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
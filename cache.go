@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	// cacheVersion is bumped whenever the shape of cached tasks changes so
+	// caches written by older versions are discarded.
+	cacheVersion         = 20
+	defaultCacheFilename = `.task-aggregator-cache.json`
+)
+
+// Cache holds the tasks previously parsed from each file, keyed by file path,
+// so files that are unchanged since the last run don't need to be re-scanned.
+type Cache struct {
+	Files map[string]CacheEntry
+	// Location is the time zone file dates were read in. Dates read in
+	// another zone aren't reused.
+	Location string
+	// ParseOptions are those the tasks were parsed with. Tasks parsed with
+	// other options aren't reused.
+	ParseOptions tasks.ParseOptions
+	// Parsers describes the parsers registered for other formats, by
+	// extension. Tasks parsed with other parsers aren't reused.
+	Parsers map[string]string
+	Version int
+}
+
+type CacheEntry struct {
+	DisplayPath string
+	ModTime     time.Time
+	Size        int64
+	Tasks       []tasks.Task
+}
+
+func newCache(parseOptions tasks.ParseOptions) Cache {
+	return Cache{Files: map[string]CacheEntry{}, Location: time.Local.String(), ParseOptions: parseOptions, Parsers: registeredParsers(), Version: cacheVersion}
+}
+
+// registeredParsers describes each parser registered for other formats with
+// its type and settings, by extension.
+func registeredParsers() map[string]string {
+	described := map[string]string{}
+	for extension, parser := range tasks.Parsers() {
+		described[extension] = fmt.Sprintf("%T%+v", parser, parser)
+	}
+	return described
+}
+
+func loadCache(filename string, parseOptions tasks.ParseOptions) Cache {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("can't read cache", "error", err)
+		}
+		return newCache(parseOptions)
+	}
+
+	cache := Cache{}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		slog.Warn("can't read cache", "file", filename, "error", err)
+		return newCache(parseOptions)
+	}
+	if cache.Version != cacheVersion || cache.Files == nil || cache.Location != time.Local.String() || !reflect.DeepEqual(cache.ParseOptions, parseOptions) || !reflect.DeepEqual(cache.Parsers, registeredParsers()) {
+		return newCache(parseOptions)
+	}
+
+	return cache
+}
+
+// lookup returns the cached tasks for the file if its modification time and
+// size still match what was recorded.
+func (cache Cache) lookup(file tasks.FileMeta) ([]tasks.Task, bool) {
+	entry, ok := cache.Files[file.Path]
+	if !ok || entry.DisplayPath != file.DisplayPath || entry.Size != file.Size || !entry.ModTime.Equal(file.ModTime) {
+		return nil, false
+	}
+	return entry.Tasks, true
+}
+
+func (cache Cache) save(filename string) {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		slog.Warn("can't save cache", "error", err)
+		return
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		slog.Warn("can't save cache", "error", err)
+	}
+}
+
+func (cache Cache) store(file tasks.FileMeta, fileTasks []tasks.Task) {
+	cache.Files[file.Path] = CacheEntry{
+		DisplayPath: file.DisplayPath,
+		ModTime:     file.ModTime,
+		Size:        file.Size,
+		Tasks:       fileTasks,
+	}
+}
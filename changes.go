@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	commandChanges = "changes"
+	// changesStateFilename records the tasks found by the last run of
+	// changes, so the next one can tell what changed.
+	changesStateFilename = ".task-aggregator-changes.json"
+)
+
+// changesState is what changes records of each run for the next one.
+type changesState struct {
+	At    time.Time    `json:"at"`
+	Tasks []tasks.Task `json:"tasks"`
+}
+
+// taskChanges are the tasks added, completed, reopened, and removed since
+// the last run, as the changes command prints them.
+type taskChanges struct {
+	Since     time.Time    `json:"since"`
+	Added     []tasks.Task `json:"added"`
+	Completed []tasks.Task `json:"completed"`
+	Reopened  []tasks.Task `json:"reopened"`
+	Removed   []tasks.Task `json:"removed"`
+}
+
+func setupChanges(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	asJSON := flags.Bool("json", false, "true to print the changes as JSON instead of markdown (default=false)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		if contains(options.Roots, stdinPath) {
+			return fmt.Errorf("changes can't read notes from stdin")
+		}
+		filter, err := options.filter()
+		if err != nil {
+			return err
+		}
+
+		previous, err := loadChangesState(changesStateFilename)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		scanned, err := scan(options)
+		if err != nil {
+			return err
+		}
+		found := []tasks.Task{}
+		for _, task := range tasks.Flatten(scanned.Tasks) {
+			// upcoming instances of recurring tasks come and go with the date
+			if task.RecurrenceOf == "" {
+				task.Subtasks = nil
+				found = append(found, task)
+			}
+		}
+		if previous == nil {
+			slog.Info("no earlier run of changes to compare with, recorded the tasks found for the next one", "tasks", len(found))
+			return saveChangesState(changesStateFilename, changesState{At: now, Tasks: found})
+		}
+
+		before := tasks.Tasks{Tasks: previous.Tasks}.Filter(filter).Tasks
+		after := tasks.Tasks{Tasks: found}.Filter(filter).Tasks
+		changes := newTaskChanges(taskEvents(before, after), previous.At)
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(changes)
+		} else {
+			_, err = os.Stdout.WriteString(changes.markdown())
+		}
+		if err != nil {
+			return err
+		}
+		// recorded only once printed, so changes that failed to print are
+		// shown again
+		return saveChangesState(changesStateFilename, changesState{At: now, Tasks: found})
+	}
+}
+
+// loadChangesState returns what the last run of changes recorded, or nil
+// when there was none.
+func loadChangesState(filename string) (*changesState, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := changesState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &state, nil
+}
+
+func saveChangesState(filename string, state changesState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// newTaskChanges sorts the events found between the last run, at since, and
+// this one by their type.
+func newTaskChanges(events []taskEvent, since time.Time) taskChanges {
+	changes := taskChanges{Since: since, Added: []tasks.Task{}, Completed: []tasks.Task{}, Reopened: []tasks.Task{}, Removed: []tasks.Task{}}
+	for _, event := range events {
+		switch event.Type {
+		case eventAdded:
+			changes.Added = append(changes.Added, event.Task)
+		case eventCompleted:
+			changes.Completed = append(changes.Completed, event.Task)
+		case eventReopened:
+			changes.Reopened = append(changes.Reopened, event.Task)
+		case eventRemoved:
+			changes.Removed = append(changes.Removed, event.Task)
+		}
+	}
+	return changes
+}
+
+// markdown writes the changes as a summary line followed by a list of the
+// tasks under a heading for each kind of change, with their file and line.
+func (changes taskChanges) markdown() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "**Since %s:** %d added, %d completed, %d reopened, %d removed\n", changes.Since.Format("2006-01-02 15:04"), len(changes.Added), len(changes.Completed), len(changes.Reopened), len(changes.Removed))
+	for _, section := range []struct {
+		title string
+		tasks []tasks.Task
+	}{
+		{"Added", changes.Added},
+		{"Completed", changes.Completed},
+		{"Reopened", changes.Reopened},
+		{"Removed", changes.Removed},
+	} {
+		if len(section.tasks) == 0 {
+			continue
+		}
+		fmt.Fprintf(&out, "\n## %s\n\n", section.title)
+		for _, task := range section.tasks {
+			check := " "
+			if task.Complete {
+				check = "x"
+			}
+			fmt.Fprintf(&out, "- [%s] %s (%s:%d)\n", check, task.Text, task.FilePath, task.Line)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandComplete = "complete"
+
+func setupComplete(flags *flag.FlagSet) func(args []string) error {
+	undo := flags.Bool("undo", false, "true to mark the tasks incomplete instead (default=false)")
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("no tasks given, expected file:line arguments")
+		}
+
+		for _, arg := range args {
+			separator := strings.LastIndex(arg, ":")
+			if separator < 0 {
+				return fmt.Errorf("'%s' isn't a file:line location", arg)
+			}
+			line, err := strconv.Atoi(arg[separator+1:])
+			if err != nil {
+				return fmt.Errorf("'%s' isn't a file:line location", arg)
+			}
+			if err := tasks.SetComplete(arg[:separator], line, !*undo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
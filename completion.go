@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandCompletion = "completion"
+
+// completionShells lists the shells completion writes scripts for.
+var completionShells = []string{"bash", "fish", "powershell", "zsh"}
+
+// completionValues are the values completed for each flag taking one of a
+// list.
+var completionValues = map[string][]string{
+	"anchor-style":  tasks.AnchorStyleOptions,
+	"bullet":        tasks.BulletOptions,
+	"chart":         tasks.ChartOptions,
+	"date-from":     tasks.DateFromOptions,
+	"dates":         tasks.DateStyleOptions,
+	"editor":        tasks.EditorOptions,
+	"flavor":        tasks.FlavorOptions,
+	"format":        formats,
+	"group-by":      tasks.GroupByOptions,
+	"ics-component": tasks.ICSComponentOptions,
+	"layout":        tasks.LayoutOptions,
+	"link-style":    tasks.LinkStyleOptions,
+	"log-format":    logFormats,
+	"mode":          modes,
+	"order":         tasks.OrderOptions,
+	"sort":          tasks.SortOptions,
+	"status":        tasks.StatusOptions,
+}
+
+// completionTagFlags are the flags taking a tag, completed with the tags of
+// the tasks in the cache.
+var completionTagFlags = []string{"exclude-tag", "tag"}
+
+// completedCommands are the commands scripts complete. They're set once
+// commands is, which can't be read while it's being set as it includes
+// completion itself.
+var completedCommands []Command
+
+func init() {
+	completedCommands = commands
+}
+
+// completionCommand is a command as completion scripts see it: its name,
+// and its flags, each marked when it takes no value.
+type completionCommand struct {
+	Command
+	flags []completionFlag
+}
+
+type completionFlag struct {
+	name   string
+	isBool bool
+}
+
+func setupCompletion(flags *flag.FlagSet) func(args []string) error {
+	listTags := flags.Bool("tags", false, "true to print the tags of the cached tasks, one per line without their # or @, as scripts do to complete -tag and -exclude-tag (default=false)")
+
+	return func(args []string) error {
+		if *listTags {
+			return printCachedTags(os.Stdout, defaultCacheFilename)
+		}
+		if len(args) != 1 || !contains(completionShells, args[0]) {
+			return fmt.Errorf("completion needs a shell, one of %s, as in: completion bash", strings.Join(completionShells, ", "))
+		}
+
+		program := filepath.Base(os.Args[0])
+		all := completionCommands()
+		switch args[0] {
+		case "bash":
+			writeBashCompletion(os.Stdout, program, all)
+		case "fish":
+			writeFishCompletion(os.Stdout, program, all)
+		case "powershell":
+			writePowerShellCompletion(os.Stdout, program, all)
+		case "zsh":
+			writeZshCompletion(os.Stdout, program, all)
+		}
+		return nil
+	}
+}
+
+// completionCommands returns each command with the flags its Setup defines
+// and those every command takes, in order of name.
+func completionCommands() []completionCommand {
+	all := []completionCommand{}
+	for _, command := range completedCommands {
+		flags := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+		defineCommonFlags(flags, &LogOptions{})
+		command.Setup(flags)
+
+		completed := completionCommand{Command: command}
+		flags.VisitAll(func(f *flag.Flag) {
+			boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+			completed.flags = append(completed.flags, completionFlag{name: f.Name, isBool: ok && boolFlag.IsBoolFlag()})
+		})
+		all = append(all, completed)
+	}
+	return all
+}
+
+// printCachedTags prints the tags of the tasks in the cache, and their
+// subtasks, without their # or @, once each in order. It prints nothing when
+// there's no cache, as it's run by scripts where errors would only get in the
+// way.
+func printCachedTags(out io.Writer, cacheFilename string) error {
+	data, err := os.ReadFile(cacheFilename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cache := Cache{}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return fmt.Errorf("%s: %w", cacheFilename, err)
+	}
+
+	seen := map[string]bool{}
+	var add func(all []tasks.Task)
+	add = func(all []tasks.Task) {
+		for _, task := range all {
+			for _, tag := range task.Tags {
+				seen[tag[1:]] = true
+			}
+			add(task.Subtasks)
+		}
+	}
+	for _, entry := range cache.Files {
+		add(entry.Tasks)
+	}
+	tags := []string{}
+	for tag := range seen {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		fmt.Fprintln(out, tag)
+	}
+	return nil
+}
+
+// completionFunction returns a name for the program's completion function,
+// made of the characters shells allow in one.
+func completionFunction(program string) string {
+	return "_" + regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(program, "_") + "_complete"
+}
+
+// firstWords returns the first word of each command's name once each, as
+// typed right after the program.
+func firstWords(all []completionCommand) []string {
+	words := []string{}
+	for _, command := range all {
+		word, _, _ := strings.Cut(command.Name, " ")
+		if !contains(words, word) {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// secondWords returns the second words of the commands named by two, such as
+// github in sync github.
+func secondWords(all []completionCommand) []string {
+	words := []string{}
+	for _, command := range all {
+		if _, second, ok := strings.Cut(command.Name, " "); ok {
+			words = append(words, second)
+		}
+	}
+	return words
+}
+
+// parentCommand returns the first word of the commands named by two.
+func parentCommand(all []completionCommand) string {
+	for _, command := range all {
+		if first, _, ok := strings.Cut(command.Name, " "); ok {
+			return first
+		}
+	}
+	return ""
+}
+
+func (command completionCommand) flagNames() []string {
+	names := []string{}
+	for _, f := range command.flags {
+		names = append(names, "-"+f.name)
+	}
+	return names
+}
+
+// sortedValueFlags returns the flags of completionValues in order.
+func sortedValueFlags() []string {
+	names := []string{}
+	for name := range completionValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flagPattern returns a shell case pattern matching the flags written with
+// one dash or two.
+func flagPattern(names []string) string {
+	patterns := []string{}
+	for _, name := range names {
+		patterns = append(patterns, "-"+name, "--"+name)
+	}
+	return strings.Join(patterns, "|")
+}
+
+func writeBashCompletion(out io.Writer, program string, all []completionCommand) {
+	function := completionFunction(program)
+	fmt.Fprintf(out, "# bash completion for %[1]s, loaded by: source <(%[1]s completion bash)\n", program)
+	fmt.Fprintf(out, "%s() {\n", function)
+	fmt.Fprint(out, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}\n")
+	fmt.Fprintf(out, "\tlocal command=%s\n", completedCommands[0].Name)
+	fmt.Fprintf(out, "\tcase \"${COMP_WORDS[1]}\" in\n\t%s) command=${COMP_WORDS[1]} ;;\n\tesac\n", strings.Join(firstWords(all), "|"))
+	fmt.Fprintf(out, "\tif [[ $COMP_CWORD -gt 2 && ${COMP_WORDS[1]} == %s ]]; then\n", parentCommand(all))
+	fmt.Fprintf(out, "\t\tcase \"${COMP_WORDS[2]}\" in\n\t\t%s) command=\"${COMP_WORDS[1]} ${COMP_WORDS[2]}\" ;;\n\t\tesac\n\tfi\n", strings.Join(secondWords(all), "|"))
+
+	fmt.Fprint(out, "\tcase \"$prev\" in\n")
+	fmt.Fprintf(out, "\t%s)\n\t\tCOMPREPLY=($(compgen -W \"$(%s completion -tags 2>/dev/null)\" -- \"$cur\"))\n\t\treturn ;;\n", flagPattern(completionTagFlags), program)
+	for _, name := range sortedValueFlags() {
+		fmt.Fprintf(out, "\t%s)\n\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n\t\treturn ;;\n", flagPattern([]string{name}), strings.Join(completionValues[name], " "))
+	}
+	fmt.Fprint(out, "\tesac\n")
+
+	fmt.Fprint(out, "\tif [[ $cur == -* ]]; then\n\t\tcase \"$command\" in\n")
+	for _, command := range all {
+		fmt.Fprintf(out, "\t\t%q) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", command.Name, strings.Join(command.flagNames(), " "))
+	}
+	fmt.Fprint(out, "\t\tesac\n\telif [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(out, "\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\") $(compgen -d -- \"$cur\"))\n", strings.Join(firstWords(all), " "))
+	fmt.Fprintf(out, "\telif [[ $COMP_CWORD -eq 2 && ${COMP_WORDS[1]} == %s ]]; then\n", parentCommand(all))
+	fmt.Fprintf(out, "\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\") $(compgen -d -- \"$cur\"))\n\tfi\n}\n", strings.Join(secondWords(all), " "))
+	fmt.Fprintf(out, "complete -o default -F %s %s\n", function, program)
+}
+
+func writeZshCompletion(out io.Writer, program string, all []completionCommand) {
+	function := completionFunction(program)
+	fmt.Fprintf(out, "#compdef %s\n# zsh completion for %[1]s, loaded by: source <(%[1]s completion zsh)\n", program)
+	fmt.Fprintf(out, "%s() {\n", function)
+	fmt.Fprintf(out, "\tlocal command=%s\n", completedCommands[0].Name)
+	fmt.Fprintf(out, "\tcase $words[2] in\n\t(%s) command=$words[2] ;;\n\tesac\n", strings.Join(firstWords(all), "|"))
+	fmt.Fprintf(out, "\tif (( CURRENT > 3 )) && [[ $words[2] == %s ]]; then\n", parentCommand(all))
+	fmt.Fprintf(out, "\t\tcase $words[3] in\n\t\t(%s) command=\"$words[2] $words[3]\" ;;\n\t\tesac\n\tfi\n", strings.Join(secondWords(all), "|"))
+
+	fmt.Fprint(out, "\tcase $words[CURRENT-1] in\n")
+	fmt.Fprintf(out, "\t(%s)\n\t\tcompadd -- ${(f)\"$(%s completion -tags 2>/dev/null)\"}\n\t\treturn ;;\n", flagPattern(completionTagFlags), program)
+	for _, name := range sortedValueFlags() {
+		fmt.Fprintf(out, "\t(%s)\n\t\tcompadd -- %s\n\t\treturn ;;\n", flagPattern([]string{name}), strings.Join(completionValues[name], " "))
+	}
+	fmt.Fprint(out, "\tesac\n")
+
+	fmt.Fprint(out, "\tif [[ $PREFIX == -* ]]; then\n\t\tcase $command in\n")
+	for _, command := range all {
+		fmt.Fprintf(out, "\t\t(%q) compadd -- %s ;;\n", command.Name, strings.Join(command.flagNames(), " "))
+	}
+	fmt.Fprint(out, "\t\tesac\n\t\treturn\n\tfi\n")
+	fmt.Fprintf(out, "\tif (( CURRENT == 2 )); then\n\t\tcompadd -- %s\n", strings.Join(firstWords(all), " "))
+	fmt.Fprintf(out, "\telif (( CURRENT == 3 )) && [[ $words[2] == %s ]]; then\n\t\tcompadd -- %s\n\tfi\n", parentCommand(all), strings.Join(secondWords(all), " "))
+	fmt.Fprint(out, "\t_files\n}\n")
+	fmt.Fprintf(out, "if [[ $funcstack[1] == %[1]s ]]; then\n\t%[1]s \"$@\"\nelse\n\tcompdef %[1]s %[2]s\nfi\n", function, program)
+}
+
+func writeFishCompletion(out io.Writer, program string, all []completionCommand) {
+	function := completionFunction(program)
+	fmt.Fprintf(out, "# fish completion for %[1]s, loaded by: %[1]s completion fish | source\n", program)
+	fmt.Fprintf(out, "function %s_command\n\tset -l words (commandline -opc)\n", function)
+	fmt.Fprintf(out, "\tif test (count $words) -gt 2; and test \"$words[2]\" = %s; and contains -- $words[3] %s\n", parentCommand(all), strings.Join(secondWords(all), " "))
+	fmt.Fprint(out, "\t\techo \"$words[2] $words[3]\"\n")
+	fmt.Fprintf(out, "\telse if test (count $words) -gt 1; and contains -- $words[2] %s\n\t\techo $words[2]\n", strings.Join(firstWords(all), " "))
+	fmt.Fprintf(out, "\telse\n\t\techo %s\n\tend\nend\n", completedCommands[0].Name)
+	fmt.Fprintf(out, "function %s_using\n\ttest (%[1]s_command) = \"$argv\"\nend\n", function)
+
+	for _, command := range all {
+		if first, second, ok := strings.Cut(command.Name, " "); ok {
+			fmt.Fprintf(out, "complete -c %s -n 'test (count (commandline -opc)) -eq 2; and __fish_seen_subcommand_from %s' -a %s -d %s\n", program, first, second, fishQuote(command.Description))
+		} else {
+			fmt.Fprintf(out, "complete -c %s -n 'test (count (commandline -opc)) -eq 1' -a %s -d %s\n", program, command.Name, fishQuote(command.Description))
+		}
+	}
+	for _, command := range all {
+		condition := fmt.Sprintf("%s_using %s", function, command.Name)
+		for _, f := range command.flags {
+			switch values, ok := completionValues[f.name]; {
+			case contains(completionTagFlags, f.name):
+				fmt.Fprintf(out, "complete -c %s -n '%s' -o %s -x -a '(%s completion -tags 2>/dev/null)'\n", program, condition, f.name, program)
+			case ok:
+				fmt.Fprintf(out, "complete -c %s -n '%s' -o %s -x -a '%s'\n", program, condition, f.name, strings.Join(values, " "))
+			case f.isBool:
+				fmt.Fprintf(out, "complete -c %s -n '%s' -o %s\n", program, condition, f.name)
+			default:
+				fmt.Fprintf(out, "complete -c %s -n '%s' -o %s -r\n", program, condition, f.name)
+			}
+		}
+	}
+}
+
+// fishQuote returns the text as a single-quoted fish string.
+func fishQuote(text string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(text) + "'"
+}
+
+func writePowerShellCompletion(out io.Writer, program string, all []completionCommand) {
+	list := func(values []string) string {
+		quoted := []string{}
+		for _, value := range values {
+			quoted = append(quoted, "'"+value+"'")
+		}
+		return "@(" + strings.Join(quoted, ", ") + ")"
+	}
+	fmt.Fprintf(out, "# PowerShell completion for %[1]s, loaded by: %[1]s completion powershell | Out-String | Invoke-Expression\n", program)
+	fmt.Fprintf(out, "Register-ArgumentCompleter -Native -CommandName '%s' -ScriptBlock {\n", program)
+	fmt.Fprint(out, "\tparam($wordToComplete, $commandAst, $cursorPosition)\n")
+	fmt.Fprint(out, "\t$words = @($commandAst.CommandElements | Where-Object { $_.Extent.EndOffset -le $cursorPosition } | ForEach-Object { $_.ToString() })\n")
+	fmt.Fprint(out, "\tif ($wordToComplete -ne '') { $words = @($words | Select-Object -SkipLast 1) }\n")
+	fmt.Fprintf(out, "\t$command = '%s'\n", completedCommands[0].Name)
+	fmt.Fprintf(out, "\tif ($words.Count -gt 1 -and %s -contains $words[1]) { $command = $words[1] }\n", list(firstWords(all)))
+	fmt.Fprintf(out, "\tif ($words.Count -gt 2 -and $words[1] -eq '%s' -and %s -contains $words[2]) { $command = \"$($words[1]) $($words[2])\" }\n", parentCommand(all), list(secondWords(all)))
+
+	fmt.Fprint(out, "\t$flagValues = @{\n")
+	for _, name := range sortedValueFlags() {
+		fmt.Fprintf(out, "\t\t'-%s' = %s\n", name, list(completionValues[name]))
+	}
+	fmt.Fprint(out, "\t}\n\t$commandFlags = @{\n")
+	for _, command := range all {
+		fmt.Fprintf(out, "\t\t'%s' = %s\n", command.Name, list(command.flagNames()))
+	}
+	fmt.Fprint(out, "\t}\n")
+	fmt.Fprint(out, "\t$previous = $words[-1] -replace '^--', '-'\n")
+	tagFlags := []string{}
+	for _, name := range completionTagFlags {
+		tagFlags = append(tagFlags, "-"+name)
+	}
+	fmt.Fprintf(out, "\tif (%s -contains $previous) {\n", list(tagFlags))
+	fmt.Fprintf(out, "\t\t$candidates = @(& '%s' completion -tags 2>$null)\n", program)
+	fmt.Fprint(out, "\t} elseif ($flagValues.ContainsKey($previous)) {\n\t\t$candidates = $flagValues[$previous]\n")
+	fmt.Fprint(out, "\t} elseif ($wordToComplete.StartsWith('-')) {\n\t\t$candidates = $commandFlags[$command]\n")
+	fmt.Fprintf(out, "\t} elseif ($words.Count -eq 1) {\n\t\t$candidates = %s\n", list(firstWords(all)))
+	fmt.Fprintf(out, "\t} elseif ($words.Count -eq 2 -and $words[1] -eq '%s') {\n\t\t$candidates = %s\n", parentCommand(all), list(secondWords(all)))
+	fmt.Fprint(out, "\t} else {\n\t\treturn\n\t}\n")
+	fmt.Fprint(out, "\t$candidates | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	fmt.Fprint(out, "\t\t[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)\n\t}\n}\n")
+}
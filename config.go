@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configFilenames are the config files looked for in the current directory
+// when no -config flag is given, in order of preference.
+var configFilenames = []string{".taskaggregator.yaml", ".taskaggregator.yml", ".taskaggregator.toml"}
+
+// configAliases maps readable config keys to the short flags they set.
+var configAliases = map[string]string{
+	"completed": "c",
+	"output":    "o",
+}
+
+// findConfig returns the first config file present in the current directory,
+// or an empty string if there is none.
+func findConfig() string {
+	for _, filename := range configFilenames {
+		if _, err := os.Stat(filename); err == nil {
+			return filename
+		}
+	}
+	return ""
+}
+
+// applyConfig sets each flag named in the config file that wasn't given on
+// the command line, so command line flags override config values. Keys are
+// flag names, and lists set repeatable flags once per value. Keys for flags
+// that only other commands define are skipped.
+func applyConfig(flags *flag.FlagSet, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("config file '%s' not found", filename)
+		}
+		return err
+	}
+
+	values := map[string]interface{}{}
+	switch filepath.Ext(filename) {
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	setOnCommandLine := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	for key, value := range values {
+		name := key
+		if alias, ok := configAliases[key]; ok {
+			name = alias
+		}
+		if flags.Lookup(name) == nil {
+			if !isCommandFlag(name) {
+				return fmt.Errorf("%s: unknown setting '%s'", filename, key)
+			}
+			continue
+		}
+		if setOnCommandLine[name] {
+			continue
+		}
+
+		list, isList := value.([]interface{})
+		if !isList {
+			list = []interface{}{value}
+		}
+		for _, item := range list {
+			if err := flags.Set(name, fmt.Sprint(item)); err != nil {
+				return fmt.Errorf("%s: %s: %w", filename, key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isCommandFlag reports whether any command defines the flag.
+func isCommandFlag(name string) bool {
+	for _, command := range commands {
+		flags := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+		command.Setup(flags)
+		if flags.Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
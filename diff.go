@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// diffLine is a line of a diff: unchanged (' '), removed ('-'), or added
+// ('+'), with its index in the old and new text.
+type diffLine struct {
+	kind     byte
+	newIndex int
+	oldIndex int
+	text     string
+}
+
+// unifiedDiff returns the changes from old to new in the unified diff format,
+// or an empty string when they're the same. A name of /dev/null marks a file
+// that doesn't exist.
+func unifiedDiff(oldName, newName, old, new string) string {
+	if old == new {
+		return ""
+	}
+	lines := diffLines(splitLines(old), splitLines(new))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(lines); {
+		// find the next change, then extend the hunk until the changes are
+		// more than twice the context apart
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for i := first + 1; i < len(lines) && i <= last+2*diffContext; i++ {
+			if lines[i].kind != ' ' {
+				last = i
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(lines))
+		writeHunk(&out, lines[from:to])
+		start = to
+	}
+	return out.String()
+}
+
+// writeHunk writes the lines under a header giving where they are in each
+// text, with counts of one left out as diff(1) does.
+func writeHunk(out *strings.Builder, lines []diffLine) {
+	oldStart, oldCount, newStart, newCount := -1, 0, -1, 0
+	for _, line := range lines {
+		if line.kind != '+' {
+			if oldStart < 0 {
+				oldStart = line.oldIndex
+			}
+			oldCount++
+		}
+		if line.kind != '-' {
+			if newStart < 0 {
+				newStart = line.newIndex
+			}
+			newCount++
+		}
+	}
+	// an empty range starts at the line before it
+	if oldStart < 0 {
+		oldStart = lines[0].oldIndex - 1
+	}
+	if newStart < 0 {
+		newStart = lines[0].newIndex - 1
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+
+	for _, line := range lines {
+		out.WriteByte(line.kind)
+		out.WriteString(line.text)
+		if !strings.HasSuffix(line.text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the range of lines starting at the zero-based index.
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text after each newline, keeping them.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script turning old into new, found with
+// Myers' algorithm. The indexes of added lines are where they'd be in old,
+// and those of removed lines where they'd be in new.
+func diffLines(old, new []string) []diffLine {
+	n, m := len(old), len(new)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			x := v[offset+k-1] + 1
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			}
+			y := x - k
+			for x < n && y < m && old[x] == new[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// walk back through the moves made at each distance
+	lines := []diffLine{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			lines = append(lines, diffLine{kind: ' ', newIndex: y, oldIndex: x, text: old[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			lines = append(lines, diffLine{kind: '+', newIndex: prevY, oldIndex: x, text: new[prevY]})
+		} else {
+			lines = append(lines, diffLine{kind: '-', newIndex: y, oldIndex: prevX, text: old[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
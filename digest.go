@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	commandDigest = "digest"
+	// smtpPasswordVariable is the environment variable holding the password
+	// for -smtp-user.
+	smtpPasswordVariable = "SMTP_PASSWORD"
+)
+
+// DigestOptions are the flag values for emailing a digest.
+type DigestOptions struct {
+	DryRun    bool
+	DueWithin string
+	From      string
+	SMTP      string
+	SMTPUser  string
+	Subject   string
+	To        Strings
+}
+
+func setupDigest(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	digestOptions := DigestOptions{}
+	defineScanFlags(flags, &options)
+	defineRenderFlags(flags, &options)
+	flags.BoolVar(&digestOptions.DryRun, "dry-run", false, "true to print the email instead of sending it (default=false)")
+	flags.StringVar(&digestOptions.DueWithin, "due-within", "1d", "only include tasks due within this span from today, such as 1d for tomorrow's, along with overdue tasks, or empty for every open task (default=1d)")
+	flags.StringVar(&digestOptions.From, "from", "", "address to send the digest from")
+	flags.StringVar(&digestOptions.SMTP, "smtp", "localhost:25", "host:port of the SMTP server to send the digest through (default=localhost:25)")
+	flags.StringVar(&digestOptions.SMTPUser, "smtp-user", "", fmt.Sprintf("user to sign in to the SMTP server as, with the password in %s (default=none)", smtpPasswordVariable))
+	flags.StringVar(&digestOptions.Subject, "subject", "Tasks for {date}", "subject of the email, where {date} is today's date in -date-format (default=Tasks for {date})")
+	flags.Var(&digestOptions.To, "to", "address to send the digest to, may be repeated")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		if len(digestOptions.To) == 0 && !digestOptions.DryRun {
+			return fmt.Errorf("-to is required")
+		}
+		if digestOptions.From == "" && !digestOptions.DryRun {
+			return fmt.Errorf("-from is required")
+		}
+		options.IncompleteOnly = true
+		options.OutputCompleted = false
+
+		digest, err := collect(options)
+		if err != nil {
+			return err
+		}
+		if digestOptions.DueWithin != "" {
+			dueBy, err := tasks.ParseHorizon(digestOptions.DueWithin, time.Now())
+			if err != nil {
+				return fmt.Errorf("-due-within: %w", err)
+			}
+			digest = digest.Filter(tasks.Filter{DueBy: &dueBy})
+		}
+		if digest.IncompleteCount() == 0 {
+			slog.Info("no tasks due, so no digest sent")
+			return nil
+		}
+
+		message, err := digestMessage(digest, options, digestOptions)
+		if err != nil {
+			return err
+		}
+		if digestOptions.DryRun {
+			_, err := os.Stdout.Write(message)
+			return err
+		}
+		if err := sendDigest(digestOptions, message); err != nil {
+			return err
+		}
+		slog.Info("sent digest", "to", strings.Join(digestOptions.To, ", "), "incomplete", digest.IncompleteCount())
+		return nil
+	}
+}
+
+// digestMessage renders the tasks as an email with the markdown report as its
+// text and the html report as its alternative, which mail apps show instead.
+func digestMessage(digest tasks.Tasks, options Options, digestOptions DigestOptions) ([]byte, error) {
+	text := bytes.Buffer{}
+	if err := writeMarkdown(&text, digest, options.Template); err != nil {
+		return nil, err
+	}
+	html := bytes.Buffer{}
+	if err := writeHTML(&html, digest, ""); err != nil {
+		return nil, err
+	}
+
+	body := bytes.Buffer{}
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	subject := strings.ReplaceAll(digestOptions.Subject, "{date}", time.Now().Format(options.DateFormat))
+	message := bytes.Buffer{}
+	fmt.Fprintf(&message, "From: %s\r\n", digestOptions.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(digestOptions.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// sendDigest sends the message through the SMTP server, signing in when a
+// user is given. The connection is upgraded with STARTTLS when the server
+// offers it.
+func sendDigest(digestOptions DigestOptions, message []byte) error {
+	var auth smtp.Auth
+	if digestOptions.SMTPUser != "" {
+		host, _, err := net.SplitHostPort(digestOptions.SMTP)
+		if err != nil {
+			return fmt.Errorf("-smtp: %w", err)
+		}
+		auth = smtp.PlainAuth("", digestOptions.SMTPUser, os.Getenv(smtpPasswordVariable), host)
+	}
+	return smtp.SendMail(digestOptions.SMTP, auth, digestOptions.From, digestOptions.To, message)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	// eventsKeepAlive is how often a comment is sent down idle event streams,
+	// so proxies don't close them.
+	eventsKeepAlive = 30 * time.Second
+	// eventsBuffer is the number of events held for a client that hasn't read
+	// them yet. Events past it are dropped for that client.
+	eventsBuffer = 256
+)
+
+// The types of taskEvent.
+const (
+	eventAdded     = "added"
+	eventCompleted = "completed"
+	eventRemoved   = "removed"
+	eventReopened  = "reopened"
+)
+
+// taskEvent is a change to a task found by a re-scan.
+type taskEvent struct {
+	Type string
+	Task tasks.Task
+}
+
+// eventHub passes the changes found by each re-scan to the clients streaming
+// /events.
+type eventHub struct {
+	mutex       sync.Mutex
+	subscribers map[chan taskEvent]bool
+}
+
+func (hub *eventHub) subscribe() chan taskEvent {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	if hub.subscribers == nil {
+		hub.subscribers = map[chan taskEvent]bool{}
+	}
+	events := make(chan taskEvent, eventsBuffer)
+	hub.subscribers[events] = true
+	return events
+}
+
+func (hub *eventHub) unsubscribe(events chan taskEvent) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	delete(hub.subscribers, events)
+}
+
+// publish sends the events to every client, without waiting on clients that
+// have fallen behind.
+func (hub *eventHub) publish(events []taskEvent) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	for subscriber := range hub.subscribers {
+		for _, event := range events {
+			select {
+			case subscriber <- event:
+			default:
+				slog.Debug("dropped event for slow client", "type", event.Type, "id", event.Task.ID)
+			}
+		}
+	}
+}
+
+// taskEvents returns the tasks added, completed, reopened, and removed
+// between two scans, recognizing tasks by their changeKey.
+func taskEvents(before, after []tasks.Task) []taskEvent {
+	previous := map[string]tasks.Task{}
+	for _, task := range before {
+		previous[changeKey(task)] = task
+	}
+
+	events := []taskEvent{}
+	for _, task := range after {
+		key := changeKey(task)
+		old, ok := previous[key]
+		delete(previous, key)
+		switch {
+		case !ok:
+			events = append(events, taskEvent{Type: eventAdded, Task: task})
+		case task.Complete && !old.Complete:
+			events = append(events, taskEvent{Type: eventCompleted, Task: task})
+		case !task.Complete && old.Complete:
+			events = append(events, taskEvent{Type: eventReopened, Task: task})
+		}
+	}
+	for _, task := range before {
+		if _, ok := previous[changeKey(task)]; ok {
+			events = append(events, taskEvent{Type: eventRemoved, Task: task})
+		}
+	}
+	return events
+}
+
+// handleEvents streams the changes found by each re-scan as server-sent
+// events, named by their type with the task as JSON data, until the client
+// disconnects.
+func (board *dashboard) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+
+	events := board.events.subscribe()
+	defer board.events.unsubscribe(events)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event := <-events:
+			data, err := json.Marshal(event.Task)
+			if err != nil {
+				slog.Warn("can't write event", "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandFmt = "fmt"
+
+func setupFmt(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	style := tasks.FormatStyle{}
+	flags.StringVar(&style.Bullet, "bullet", "-", fmt.Sprintf("list marker to write tasks with, one of %s, or empty to keep each as written (default=-)", strings.Join(tasks.BulletOptions, " ")))
+	check := flags.Bool("check", false, fmt.Sprintf("true to list the notes that aren't formatted, exiting with %d if there are any, instead of rewriting them (default=false)", exitFindings))
+	flags.StringVar(&style.Dates, "dates", "", fmt.Sprintf("how to write due, start, and completion dates, one of %s, or empty to keep each as written (default=empty)", strings.Join(tasks.DateStyleOptions, ", ")))
+	flags.BoolVar(&style.TagsLast, "tags-last", false, "true to move the tags of each task to the end of its line (default=false)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		if err := style.Check(); err != nil {
+			return err
+		}
+		if contains(options.Roots, stdinPath) {
+			return fmt.Errorf("fmt can't rewrite notes read from stdin")
+		}
+		return formatNotes(options, style, *check)
+	}
+}
+
+// formatNotes rewrites the task lines of every file under the roots in the
+// style, logging each file rewritten, or with check lists the files that
+// would be and fails if there are any.
+func formatNotes(options Options, style tasks.FormatStyle, check bool) error {
+	files, err := sourceFiles(options)
+	if err != nil {
+		return err
+	}
+	paths := []string{}
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	unformatted := 0
+	for _, path := range paths {
+		changed, err := tasks.FormatFile(files[path].Path, style, !check)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+		unformatted++
+		if check {
+			fmt.Println(path)
+		} else {
+			slog.Info("formatted", "file", path)
+		}
+	}
+	if check && unformatted > 0 {
+		return exitCodeError{code: exitFindings, message: fmt.Sprintf("%d notes aren't formatted", unformatted)}
+	}
+	return nil
+}
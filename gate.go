@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+// Exit codes let scripts and CI pipelines tell failures apart. Invalid flags
+// exit with 2, as the flag package does.
+const (
+	// exitError is for failures such as unreadable roots, settings, or output
+	// files.
+	exitError = 1
+	// exitOpenTasks is for open tasks failing -fail-on-incomplete or
+	// -fail-if-overdue.
+	exitOpenTasks = 3
+	// exitNoTasks is for finding no tasks at all while a gate flag is set,
+	// which usually means the wrong root was given.
+	exitNoTasks = 4
+	// exitProblems is for roots, directories, or files that couldn't be read
+	// while -strict is set.
+	exitProblems = 5
+	// exitFindings is for lint finding suspicious patterns in the notes, or
+	// fmt -check finding notes that aren't formatted.
+	exitFindings = 6
+)
+
+// exitCodeError ends the program with its exit code instead of exitError.
+type exitCodeError struct {
+	code    int
+	message string
+}
+
+func (err exitCodeError) Error() string {
+	return err.message
+}
+
+// defineGateFlags defines the flags that fail the run when open tasks remain.
+func defineGateFlags(flags *flag.FlagSet, options *Options) {
+	flags.BoolVar(&options.FailIfOverdue, "fail-if-overdue", false, fmt.Sprintf("true to exit with %d if any task found is past its due date (default=false)", exitOpenTasks))
+	flags.BoolVar(&options.FailOnIncomplete, "fail-on-incomplete", false, fmt.Sprintf("true to exit with %d if any task found is incomplete (default=false)", exitOpenTasks))
+}
+
+// checkGates returns an exitCodeError when a gate flag is set and the tasks
+// found fail it, or when no tasks were found at all.
+func (options Options) checkGates(found tasks.Tasks) error {
+	if !options.FailIfOverdue && !options.FailOnIncomplete {
+		return nil
+	}
+	if found.TotalCount() == 0 {
+		return exitCodeError{code: exitNoTasks, message: "no tasks found"}
+	}
+
+	incomplete, overdue := 0, 0
+	for _, task := range tasks.Flatten(found.Tasks) {
+		if !task.Complete && !task.Cancelled() {
+			incomplete++
+		}
+		if task.Overdue() {
+			overdue++
+		}
+	}
+	if options.FailIfOverdue && overdue > 0 {
+		return exitCodeError{code: exitOpenTasks, message: fmt.Sprintf("%d overdue tasks found", overdue)}
+	}
+	if options.FailOnIncomplete && incomplete > 0 {
+		return exitCodeError{code: exitOpenTasks, message: fmt.Sprintf("%d incomplete tasks found", incomplete)}
+	}
+	return nil
+}
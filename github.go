@@ -0,0 +1,291 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	commandSyncGitHub = "sync github"
+	defaultGitHubAPI  = "https://api.github.com"
+	// gitHubTokenVariable is the environment variable holding the token used
+	// to call the GitHub API.
+	gitHubTokenVariable = "GITHUB_TOKEN"
+)
+
+// gitHubMarkerPattern matches the comment in an issue body recording the task
+// it was opened for.
+var gitHubMarkerPattern = regexp.MustCompile(`<!-- task id:(\w+) file:(.*?) -->`)
+
+// GitHubOptions are the flag values for syncing tasks to GitHub issues.
+type GitHubOptions struct {
+	APIURL string
+	DryRun bool
+	Labels Strings
+	Repo   string
+}
+
+// gitHubIssue is the part of a GitHub issue that syncing reads and writes.
+type gitHubIssue struct {
+	Body   string `json:"body"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Number      int       `json:"number"`
+	PullRequest *struct{} `json:"pull_request"`
+	State       string    `json:"state"`
+	Title       string    `json:"title"`
+}
+
+// gitHubClient calls the GitHub REST API for one repository.
+type gitHubClient struct {
+	apiURL string
+	repo   string
+	token  string
+}
+
+func setupSyncGitHub(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	gitHubOptions := GitHubOptions{}
+	defineScanFlags(flags, &options)
+	flags.StringVar(&gitHubOptions.APIURL, "api-url", defaultGitHubAPI, fmt.Sprintf("GitHub API to call, for GitHub Enterprise (default=%s)", defaultGitHubAPI))
+	flags.BoolVar(&gitHubOptions.DryRun, "dry-run", false, "true to print the changes that would be made to issues without making them (default=false)")
+	flags.Var(&gitHubOptions.Labels, "label", "label to add to every issue opened, and to find the issues opened before by, may be repeated")
+	flags.StringVar(&gitHubOptions.Repo, "repo", "", "GitHub repository to open issues in, as owner/name")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		if owner, name, ok := strings.Cut(gitHubOptions.Repo, "/"); !ok || owner == "" || name == "" {
+			return fmt.Errorf("-repo must be given as owner/name")
+		}
+		client := gitHubClient{
+			apiURL: strings.TrimSuffix(gitHubOptions.APIURL, "/"),
+			repo:   gitHubOptions.Repo,
+			token:  os.Getenv(gitHubTokenVariable),
+		}
+		if client.token == "" && !gitHubOptions.DryRun {
+			return fmt.Errorf("%s must be set to a token that can write issues in %s", gitHubTokenVariable, gitHubOptions.Repo)
+		}
+		collected, err := collect(options)
+		if err != nil {
+			return err
+		}
+		return syncGitHub(collected, client, gitHubOptions)
+	}
+}
+
+// syncGitHub opens an issue for each incomplete task without one, labelled
+// with the task's tags, and keeps the issues opened before in step with their
+// tasks: issues are closed when their task is completed or cancelled, and
+// reopened when it's unchecked. Issues are matched to tasks by the ID recorded
+// in their body, or when the source has changed since, by file and text.
+func syncGitHub(found tasks.Tasks, client gitHubClient, gitHubOptions GitHubOptions) error {
+	issues, err := client.issues(gitHubOptions.Labels)
+	if err != nil {
+		return err
+	}
+
+	byID := map[string]*gitHubIssue{}
+	for i := range issues {
+		if match := gitHubMarkerPattern.FindStringSubmatch(issues[i].Body); match != nil {
+			byID[match[1]] = &issues[i]
+		}
+	}
+	matched := map[int]bool{}
+	all := tasks.Flatten(found.Tasks)
+	issueFor := func(task tasks.Task) *gitHubIssue {
+		if issue, ok := byID[task.ID]; ok && !matched[issue.Number] {
+			return issue
+		}
+		for i, issue := range issues {
+			match := gitHubMarkerPattern.FindStringSubmatch(issue.Body)
+			if match != nil && !matched[issue.Number] && match[2] == task.FilePath && issue.Title == task.Text && !hasTaskID(all, match[1]) {
+				return &issues[i]
+			}
+		}
+		return nil
+	}
+
+	prefix := ""
+	if gitHubOptions.DryRun {
+		prefix = "would have "
+	}
+	created, updated, closed := 0, 0, 0
+	for _, task := range all {
+		issue := issueFor(task)
+		open := !task.Complete && !task.Cancelled()
+		labels := gitHubLabels(task, gitHubOptions.Labels)
+
+		if issue == nil {
+			if !open {
+				continue
+			}
+			fmt.Printf("%sopened issue: %s (%s:%d)\n", prefix, task.Text, task.FilePath, task.Line)
+			created++
+			if !gitHubOptions.DryRun {
+				issue := map[string]interface{}{"title": task.Text, "body": gitHubBody(task), "labels": labels}
+				if err := client.request(http.MethodPost, "/issues", issue, nil); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		matched[issue.Number] = true
+
+		change := map[string]interface{}{}
+		if body := gitHubBody(task); issue.Body != body {
+			change["body"] = body
+		}
+		// labels are only added, so labels given to the issue on GitHub stay
+		existing := []string{}
+		for _, label := range issue.Labels {
+			existing = append(existing, label.Name)
+		}
+		for _, label := range labels {
+			if !contains(existing, label) {
+				existing = append(existing, label)
+				change["labels"] = existing
+			}
+		}
+		action := "updated"
+		switch {
+		case !open && issue.State == "open":
+			action = "closed"
+			change["state"] = "closed"
+			change["state_reason"] = "completed"
+			if task.Cancelled() {
+				change["state_reason"] = "not_planned"
+			}
+			closed++
+		case open && issue.State == "closed":
+			action = "reopened"
+			change["state"] = "open"
+			updated++
+		case len(change) > 0:
+			updated++
+		default:
+			continue
+		}
+
+		fmt.Printf("%s%s issue #%d: %s (%s:%d)\n", prefix, action, issue.Number, task.Text, task.FilePath, task.Line)
+		if !gitHubOptions.DryRun {
+			if err := client.request(http.MethodPatch, fmt.Sprintf("/issues/%d", issue.Number), change, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("%d issues %sopened, %d updated, %d closed in %s\n", created, prefix, updated, closed, client.repo)
+	return nil
+}
+
+// hasTaskID reports whether one of the tasks has the ID, so an issue recording
+// it belongs to that task rather than to another with the same text.
+func hasTaskID(all []tasks.Task, id string) bool {
+	for _, task := range all {
+		if task.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// gitHubBody is the issue body for the task, naming where it's found and
+// recording its ID to find the issue again.
+func gitHubBody(task tasks.Task) string {
+	body := fmt.Sprintf("From `%s` line %d", task.FilePath, task.Line)
+	if task.PreviousHeader != "" {
+		body += fmt.Sprintf(", under %s", task.PreviousHeader)
+	}
+	if task.Due != nil {
+		body += fmt.Sprintf(", due %s", task.Due.Format(yearMonthDayLayout))
+	}
+	return fmt.Sprintf("%s.\n\n<!-- task id:%s file:%s -->", body, task.ID, task.FilePath)
+}
+
+// gitHubLabels are the task's tags, without their # or @, and the labels
+// given to every issue, sorted without duplicates.
+func gitHubLabels(task tasks.Task, extra []string) []string {
+	labels := append([]string{}, extra...)
+	for _, tag := range task.Tags {
+		if label := tag[1:]; !contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// issues returns every issue in the repository, open or closed, with all of
+// the labels, leaving out pull requests.
+func (client gitHubClient) issues(labels []string) ([]gitHubIssue, error) {
+	path := "/issues?state=all&per_page=100"
+	if len(labels) > 0 {
+		escaped := []string{}
+		for _, label := range labels {
+			escaped = append(escaped, url.QueryEscape(label))
+		}
+		path += "&labels=" + strings.Join(escaped, ",")
+	}
+
+	issues := []gitHubIssue{}
+	for next := client.apiURL + "/repos/" + client.repo + path; next != ""; {
+		page := []gitHubIssue{}
+		header, err := apiRequest(http.MethodGet, next, client.token, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range page {
+			if issue.PullRequest == nil {
+				issues = append(issues, issue)
+			}
+		}
+		if next, err = client.nextPage(header.Get("Link")); err != nil {
+			return nil, err
+		}
+	}
+	return issues, nil
+}
+
+// request sends the JSON body to the repository path, reading any response
+// into result when it isn't nil.
+func (client gitHubClient) request(method, path string, body, result interface{}) error {
+	_, err := apiRequest(method, client.apiURL+"/repos/"+client.repo+path, client.token, body, result)
+	return err
+}
+
+// nextPage returns the URL of the next page named in a Link header, as
+// GitHub gives it, or an empty string if there is none. The URL must be on
+// the API's host, so the token isn't sent elsewhere.
+func (client gitHubClient) nextPage(link string) (string, error) {
+	for _, part := range strings.Split(link, ",") {
+		target, rel, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(rel) != `rel="next"` {
+			continue
+		}
+		target = strings.Trim(strings.TrimSpace(target), "<>")
+		next, err := url.Parse(target)
+		if err != nil {
+			return "", fmt.Errorf("next page '%s': %w", target, err)
+		}
+		api, err := url.Parse(client.apiURL)
+		if err != nil {
+			return "", err
+		}
+		if next.Host != api.Host {
+			return "", fmt.Errorf("next page '%s' isn't on %s", target, api.Host)
+		}
+		return target, nil
+	}
+	return "", nil
+}
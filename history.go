@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandHistory = "history"
+
+func setupHistory(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	asJSON := flags.Bool("json", false, "true to print the history as JSON instead of a table (default=false)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+
+		history := []tasks.Completion{}
+		if options.Store != "" {
+			var err error
+			if history, err = storeHistory(options.storePath()); err != nil {
+				return err
+			}
+		} else {
+			history = options.gitHistory()
+		}
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(history)
+		}
+		return printHistory(history)
+	}
+}
+
+// gitHistory returns when the tasks under each root were checked off or
+// reopened, oldest first, with file paths as they're written in reports.
+// Roots without git history are logged and skipped.
+func (options Options) gitHistory() []tasks.Completion {
+	history := []tasks.Completion{}
+	for _, root := range options.Roots {
+		if root == stdinPath {
+			continue
+		}
+		rootHistory, err := tasks.GitHistory(root)
+		if err != nil {
+			slog.Warn("skipping root without git history", "root", root, "error", err)
+			continue
+		}
+		for _, completion := range rootHistory {
+			if len(options.Roots) > 1 {
+				completion.FilePath = path.Join(filepath.Base(root), completion.FilePath)
+			}
+			history = append(history, completion)
+		}
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Time.Before(history[j].Time)
+	})
+	return history
+}
+
+func printHistory(history []tasks.Completion) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, completion := range history {
+		change := "completed"
+		if !completion.Complete {
+			change = "reopened"
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", completion.Time.Format("2006-01-02 15:04"), change, completion.Text, completion.FilePath)
+	}
+	return out.Flush()
+}
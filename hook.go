@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	commandInstallHook = "install-hook"
+	// hookMarker is in every hook install-hook writes, so it can tell its own
+	// hooks, which it replaces, from others, which it leaves alone.
+	hookMarker = "# written by markdown-task-aggregator install-hook"
+)
+
+func setupInstallHook(flags *flag.FlagSet) func(args []string) error {
+	failOnIncomplete := flags.Bool("fail-on-incomplete", false, fmt.Sprintf("true for the hook to fail the commit when any task is incomplete, as aggregate -fail-on-incomplete exits with %d (default=false)", exitOpenTasks))
+	force := flags.Bool("force", false, "true to replace a pre-commit hook install-hook didn't write (default=false)")
+	outputFilename := flags.String("o", tasks.DefaultOutputFilename, fmt.Sprintf("name of the file the hook regenerates and stages, relative to the top of the repository (default=%s)", tasks.DefaultOutputFilename))
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		hooksDir, err := gitHooksDir()
+		if err != nil {
+			return err
+		}
+		program, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		hookPath := filepath.Join(hooksDir, "pre-commit")
+		existing, err := os.ReadFile(hookPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil && !bytes.Contains(existing, []byte(hookMarker)) && !*force {
+			return fmt.Errorf("%s already exists, use -force to replace it", hookPath)
+		}
+
+		if err := os.MkdirAll(hooksDir, 0755); err != nil {
+			return err
+		}
+		hook := preCommitHook(program, *outputFilename, args, *failOnIncomplete)
+		if err := os.WriteFile(hookPath, []byte(hook), 0755); err != nil {
+			return err
+		}
+		slog.Info("installed hook", "file", hookPath)
+		return nil
+	}
+}
+
+// gitHooksDir returns the directory git runs the current repository's hooks
+// from, honoring core.hooksPath.
+func gitHooksDir() (string, error) {
+	command := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	output, err := command.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", fmt.Errorf("install-hook must be run in a git repository: %s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(strings.TrimSpace(string(output)))
+}
+
+// preCommitHook returns a pre-commit hook that aggregates the tasks in the
+// roots into the output file with the program and stages it, so it's
+// committed with the notes it was made from. Git runs hooks from the top of
+// the repository, so the output and roots are relative to it. With
+// failOnIncomplete, the commit fails instead when any task is incomplete.
+func preCommitHook(program, outputFilename string, roots []string, failOnIncomplete bool) string {
+	args := []string{shellQuote(program), "aggregate", "-o", shellQuote(outputFilename)}
+	if failOnIncomplete {
+		args = append(args, "-fail-on-incomplete")
+	}
+	for _, root := range roots {
+		args = append(args, shellQuote(root))
+	}
+	return fmt.Sprintf(`#!/bin/sh
+%s
+# Regenerates %s from the notes and stages it, so it never drifts from them.
+%s || exit $?
+git add -- %s
+`, hookMarker, outputFilename, strings.Join(args, " "), shellQuote(outputFilename))
+}
+
+// shellQuote returns the text as a single-quoted sh word.
+func shellQuote(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", `'\''`) + "'"
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandLint = "lint"
+
+func setupLint(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	lintOptions := tasks.LintOptions{}
+	fix := flags.Bool("fix", false, "true to rewrite malformed checkboxes in their canonical form, as -lenient reads them, instead of reporting them (default=false)")
+	asJSON := flags.Bool("json", false, "true to print the findings as JSON instead of lines (default=false)")
+	flags.IntVar(&lintOptions.MaxAgeDays, "max-age", 90, "report open tasks dated more than this many days ago, or 0 not to (default=90)")
+	flags.IntVar(&lintOptions.MaxOpen, "max-open", 25, "report files with more open tasks than this, or 0 not to (default=25)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		if lintOptions.MaxAgeDays < 0 || lintOptions.MaxOpen < 0 {
+			return fmt.Errorf("-max-age and -max-open can't be negative")
+		}
+		if contains(options.Roots, stdinPath) {
+			return fmt.Errorf("lint can't read notes from stdin")
+		}
+		lintOptions.Now = time.Now()
+		if *fix {
+			if err := fixCheckboxes(options); err != nil {
+				return err
+			}
+		}
+
+		findings, err := lint(options, lintOptions)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(findings); err != nil {
+				return err
+			}
+		} else {
+			for _, finding := range findings {
+				location := finding.FilePath
+				if finding.Line > 0 {
+					location = fmt.Sprintf("%s:%d", location, finding.Line)
+				}
+				fmt.Printf("%s: %s: %s\n", location, finding.Check, finding.Message)
+			}
+		}
+		if len(findings) > 0 {
+			return exitCodeError{code: exitFindings, message: fmt.Sprintf("%d lint findings", len(findings))}
+		}
+		return nil
+	}
+}
+
+// lint returns the findings of the tasks passing the filter flags and of the
+// checkboxes of every file under the roots, in order of file and line.
+func lint(options Options, lintOptions tasks.LintOptions) ([]tasks.Finding, error) {
+	filter, err := options.filter()
+	if err != nil {
+		return nil, err
+	}
+	scanned, err := scan(options)
+	if err != nil {
+		return nil, err
+	}
+	findings := scanned.Filter(filter).Lint(lintOptions)
+
+	for _, root := range options.Roots {
+		err := tasks.WalkMarkdownFiles(root, options.walkOptions(), func(file tasks.FileMeta) error {
+			f, err := os.Open(file.Path)
+			if err != nil {
+				slog.Warn("can't check checkboxes", "file", file.Path, "error", err)
+				return nil
+			}
+			defer f.Close()
+			malformed, err := tasks.LintCheckboxes(f, file.RelativePath(len(options.Roots) > 1))
+			if err != nil {
+				slog.Warn("can't check checkboxes", "file", file.Path, "error", err)
+			}
+			findings = append(findings, malformed...)
+			return nil
+		})
+		if err := logSkipped(err); err != nil {
+			return nil, err
+		}
+	}
+	tasks.SortFindings(findings)
+	return findings, nil
+}
+
+// fixCheckboxes rewrites the malformed checkboxes of every file under the
+// roots in their canonical form, logging each file fixed.
+func fixCheckboxes(options Options) error {
+	files, err := sourceFiles(options)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fixed, err := tasks.FixCheckboxes(file.Path)
+		if err != nil {
+			return err
+		}
+		if fixed > 0 {
+			slog.Info("fixed checkboxes", "file", file.DisplayPath, "checkboxes", fixed)
+		}
+	}
+	return nil
+}
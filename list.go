@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+	"github.com/muesli/termenv"
+)
+
+const (
+	commandList = "list"
+	// defaultTerminalWidth is the width lists are laid out in when the
+	// terminal's can't be read.
+	defaultTerminalWidth = 100
+	// maxLocationWidth is the widest the file:line column of a list gets, with
+	// longer paths cut from the left.
+	maxLocationWidth = 40
+	// minTextWidth is the narrowest the text column of a list gets, however
+	// narrow the terminal.
+	minTextWidth = 20
+)
+
+var (
+	// statusGlyphs mark each task's status in lists printed to a terminal.
+	statusGlyphs = map[tasks.Status]string{
+		tasks.StatusOpen:       "○",
+		tasks.StatusDone:       "✓",
+		tasks.StatusInProgress: "◐",
+		tasks.StatusCancelled:  "✗",
+		tasks.StatusForwarded:  "→",
+		tasks.StatusQuestion:   "?",
+	}
+	statusStyles = map[tasks.Status]lipgloss.Style{
+		tasks.StatusDone:       lipgloss.NewStyle().Foreground(lipgloss.Color("2")),
+		tasks.StatusInProgress: lipgloss.NewStyle().Foreground(lipgloss.Color("3")),
+		tasks.StatusCancelled:  lipgloss.NewStyle().Faint(true),
+		tasks.StatusForwarded:  lipgloss.NewStyle().Faint(true),
+		tasks.StatusQuestion:   lipgloss.NewStyle().Foreground(lipgloss.Color("6")),
+	}
+	overdueStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+	dueSoonStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+)
+
+// listRow is a task printed by list, indented to its depth.
+type listRow struct {
+	depth int
+	task  tasks.Task
+}
+
+func setupList(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	defineGateFlags(flags, &options)
+	flags.BoolVar(&options.OutputCompleted, "c", false, "true to list completed tasks (default=false)")
+	noColor := flags.Bool("no-color", false, "true to print a terminal list without colors, which are also left out when NO_COLOR is set (default=false)")
+	flags.BoolVar(&options.ShowCancelled, "show-cancelled", false, "true to list cancelled tasks, marked [-] (default=false)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		setColor(!*noColor)
+
+		listed, err := collect(options)
+		if err != nil {
+			return err
+		}
+		if term.IsTerminal(os.Stdout.Fd()) {
+			printTable(listed.Visible(), time.Now())
+		} else {
+			for _, task := range listed.Visible() {
+				printTask(task, 0)
+			}
+		}
+		return options.checkGates(listed)
+	}
+}
+
+// setColor turns colored output off when it isn't enabled. It's otherwise
+// left to lipgloss, which leaves colors out when stdout isn't a terminal or
+// NO_COLOR is set.
+func setColor(enabled bool) {
+	if !enabled {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// printTask prints the task and its subtasks, indented to their depth, with
+// the file and line they're found on.
+func printTask(task tasks.Task, depth int) {
+	check := task.Status.Symbol()
+	indent := strings.Repeat("  ", depth)
+	fmt.Printf("%s- [%s] %s (%s:%d)\n", indent, check, task.Text, task.FilePath, task.Line)
+	for _, line := range task.Context {
+		fmt.Printf("%s    > %s\n", indent, line)
+	}
+	for _, subtask := range task.Subtasks {
+		printTask(subtask, depth+1)
+	}
+}
+
+// printTable prints the tasks for a terminal, one line each in aligned
+// columns: a glyph for the status, the text indented to its depth, when it's
+// due relative to today, and its file and line. Text too long for the
+// terminal is cut short, and paths are cut from the left.
+func printTable(all []tasks.Task, today time.Time) {
+	rows := []listRow{}
+	var add func(all []tasks.Task, depth int)
+	add = func(all []tasks.Task, depth int) {
+		for _, task := range all {
+			rows = append(rows, listRow{depth: depth, task: task})
+			add(task.Subtasks, depth+1)
+		}
+	}
+	add(all, 0)
+
+	dueWidth, locationWidth := 0, 0
+	for _, row := range rows {
+		dueWidth = max(dueWidth, ansi.StringWidth(relativeDue(row.task, today)))
+		locationWidth = max(locationWidth, ansi.StringWidth(taskLocation(row.task)))
+	}
+	locationWidth = min(locationWidth, maxLocationWidth)
+	width := defaultTerminalWidth
+	if terminalWidth, _, err := term.GetSize(os.Stdout.Fd()); err == nil && terminalWidth > 0 {
+		width = terminalWidth
+	}
+	// the glyph and the gaps between columns take 6 cells
+	textWidth := max(width-dueWidth-locationWidth-6, minTextWidth)
+
+	for _, row := range rows {
+		task := row.task
+		glyph := statusStyles[task.Status].Render(statusGlyphs[task.Status])
+		text := ansi.Truncate(strings.Repeat("  ", row.depth)+task.Text, textWidth, "…")
+		if task.Complete || task.Cancelled() {
+			text = doneStyle.Render(text)
+		}
+		due := relativeDue(task, today)
+		dueStyle := dueSoonStyle
+		if task.Overdue() {
+			dueStyle = overdueStyle
+		}
+		location := truncateLeft(taskLocation(task), locationWidth)
+		fmt.Printf("%s %s  %s  %s\n", glyph, padRight(text, textWidth), dueStyle.Render(padRight(due, dueWidth)), pathStyle.Render(location))
+		for _, line := range task.Context {
+			fmt.Printf("  %s\n", pathStyle.Render(ansi.Truncate(strings.Repeat("  ", row.depth+1)+"> "+line, textWidth, "…")))
+		}
+	}
+}
+
+// relativeDue returns when the open task is due counted in days from today,
+// such as "3d overdue", "due today", or "due in 2d", or an empty string when
+// it's closed or has no due date.
+func relativeDue(task tasks.Task, today time.Time) string {
+	if task.Due == nil || task.Complete || task.Cancelled() {
+		return ""
+	}
+	// due dates are midnight UTC, so today is compared as a date too
+	todayDate, _ := time.Parse(yearMonthDayLayout, today.Format(yearMonthDayLayout))
+	days := int(task.Due.Sub(todayDate).Hours() / 24)
+	switch {
+	case days < 0:
+		return fmt.Sprintf("%dd overdue", -days)
+	case days == 0:
+		return "due today"
+	case days == 1:
+		return "due tomorrow"
+	default:
+		return fmt.Sprintf("due in %dd", days)
+	}
+}
+
+// taskLocation returns the task's file and line, as in notes/work.md:12.
+func taskLocation(task tasks.Task) string {
+	return fmt.Sprintf("%s:%d", task.FilePath, task.Line)
+}
+
+// truncateLeft cuts the start off text wider than width, marking the cut
+// with an ellipsis, so the end of a path stays readable.
+func truncateLeft(text string, width int) string {
+	if ansi.StringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && ansi.StringWidth(string(runes))+1 > width {
+		runes = runes[1:]
+	}
+	return "…" + string(runes)
+}
+
+// padRight pads the text with spaces to the width, measuring it as it's
+// shown rather than by its bytes.
+func padRight(text string, width int) string {
+	return text + strings.Repeat(" ", max(width-ansi.StringWidth(text), 0))
+}
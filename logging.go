@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	logFormatJSON = "json"
+	logFormatText = "text"
+)
+
+// logFormats lists the supported formats of log lines.
+var logFormats = []string{logFormatText, logFormatJSON}
+
+// LogOptions are the flag values, shared by every command, deciding what's
+// logged to stderr and how.
+type LogOptions struct {
+	Format  string
+	Quiet   bool
+	Verbose bool
+}
+
+func defineLogFlags(flags *flag.FlagSet, options *LogOptions) {
+	flags.StringVar(&options.Format, "log-format", logFormatText, fmt.Sprintf("format of the lines logged to stderr, one of %s (default=%s)", strings.Join(logFormats, ", "), logFormatText))
+	flags.BoolVar(&options.Quiet, "quiet", false, "true to log nothing but the error ending the program (default=false)")
+	flags.BoolVar(&options.Verbose, "verbose", false, "true to also log the tasks found in each file, files skipped, and timing (default=false)")
+}
+
+// setup makes the default slog logger write at the level and in the format
+// chosen. Text lines leave out the time, as they're read as they're written.
+func (options LogOptions) setup() error {
+	if !contains(logFormats, options.Format) {
+		return fmt.Errorf("unknown log-format '%s'", options.Format)
+	}
+	if options.Quiet && options.Verbose {
+		return fmt.Errorf("-quiet and -verbose can't be used together")
+	}
+
+	handlerOptions := &slog.HandlerOptions{Level: slog.LevelInfo}
+	switch {
+	case options.Quiet:
+		handlerOptions.Level = slog.LevelError
+	case options.Verbose:
+		handlerOptions.Level = slog.LevelDebug
+	}
+
+	var handler slog.Handler
+	if options.Format == logFormatJSON {
+		handler = slog.NewJSONHandler(os.Stderr, handlerOptions)
+	} else {
+		handlerOptions.ReplaceAttr = func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		}
+		handler = slog.NewTextHandler(os.Stderr, handlerOptions)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
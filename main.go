@@ -1,246 +1,151 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
-	"io/fs"
-	"io/ioutil"
-	"log"
+	"log/slog"
 	"os"
-	"path"
-	"regexp"
-	"sort"
 	"strings"
-	"syscall"
-	"time"
-	"unicode"
+	// -timezone can name any zone, even where the system has no zone database
+	_ "time/tzdata"
 )
 
-type File struct {
-	Date *time.Time
-	Name string
-	Path string
+// Command is a subcommand of the CLI.
+type Command struct {
+	Name        string
+	Description string
+	// Setup defines the command's flags and returns the function that runs the
+	// command with the remaining arguments once the flags have been parsed.
+	Setup func(flags *flag.FlagSet) func(args []string) error
 }
 
-type Tasks struct {
-	OutputCompleted bool
-	Tasks           []Task
+// Strings is a repeatable flag collecting each value given.
+type Strings []string
+
+// commands are the CLI's subcommands. The first is run when no command is
+// named, so `tasks ~/notes` aggregates as it always has.
+var commands = []Command{
+	{Name: commandAggregate, Description: "write the tasks found to an output file", Setup: setupAggregate},
+	{Name: commandArchive, Description: "move tasks completed long ago out of their notes into an archive", Setup: setupArchive},
+	{Name: commandChanges, Description: "print the tasks added, completed, reopened, or removed since the last run, for standup summaries", Setup: setupChanges},
+	{Name: commandComplete, Description: "mark tasks complete in their source files, given as file:line", Setup: setupComplete},
+	{Name: commandCompletion, Description: "print a bash, fish, powershell, or zsh script completing commands, flags, and tags", Setup: setupCompletion},
+	{Name: commandDigest, Description: "email the open tasks due soon, for a scheduled job to send each day", Setup: setupDigest},
+	{Name: commandFmt, Description: "rewrite task lines in a consistent style, or list the notes that aren't with -check", Setup: setupFmt},
+	{Name: commandHistory, Description: "print when each task was checked off or reopened, from git history", Setup: setupHistory},
+	{Name: commandInstallHook, Description: "install a git pre-commit hook that regenerates the report and stages it with each commit", Setup: setupInstallHook},
+	{Name: commandLint, Description: "report files with too many open tasks, stale or duplicate tasks, or malformed checkboxes", Setup: setupLint},
+	{Name: commandList, Description: "print the tasks found", Setup: setupList},
+	{Name: commandReview, Description: "print a weekly review of the tasks completed, created, and carried over, to paste into a weekly note", Setup: setupReview},
+	{Name: commandSearch, Description: "print the tasks whose text matches a query, with their file and line", Setup: setupSearch},
+	{Name: commandServe, Description: "serve a live dashboard and JSON API of the tasks found", Setup: setupServe},
+	{Name: commandStale, Description: "print the oldest open tasks by age, in buckets of a week, a month, and three months", Setup: setupStale},
+	{Name: commandStats, Description: "print task completion metrics by file, tag, week, and month", Setup: setupStats},
+	{Name: commandSync, Description: "write checkbox changes made in the markdown report back to the source files", Setup: setupSync},
+	{Name: commandSyncGitHub, Description: "open, update, and close GitHub issues to match the tasks found", Setup: setupSyncGitHub},
+	{Name: commandSyncTodoist, Description: "add incomplete tasks to a Todoist project and close them as they're completed", Setup: setupSyncTodoist},
+	{Name: commandTUI, Description: "browse, search, and check off tasks interactively", Setup: setupTUI},
 }
 
-type Task struct {
-	Complete       bool
-	Date           time.Time
-	FilePath       string
-	PreviousHeader string
-	Text           string
-}
-
-const (
-	completeTaskPattern     = `(?i)^\s*[-|+|\*]?\s*\[x\]`
-	datePattern             = `^(\d{4}-\d{2}-\d{2})`
-	dateHeaderPattern       = `^\#+\s+(\d{4}-\d{2}-\d{2})`
-	defaultOutputFilename   = `TASKS.md`
-	headerPattern           = `^\s*\#+\s+`
-	incompleteTaskPattern   = `^\s*[-|+|\*]?\s*\[\s+\]`
-	markdownFilenamePattern = `(?i).md$`
-	rootPath                = "."
-	yearMonthDayLayout      = "2006-01-02"
-)
-
 func main() {
-	log.SetFlags(log.LstdFlags | log.Llongfile)
-
-	tasks := Tasks{}
-	outputCompletedPtr := flag.Bool("c", false, "true to output completed tasks (default=false)")
-	outputFilename := flag.String("o", defaultOutputFilename, fmt.Sprintf("name of markdown file to output (default=%s)", defaultOutputFilename))
-
-	flag.Parse()
-	tasks.OutputCompleted = *outputCompletedPtr
+	command, args := parseCommand(os.Args[1:])
 
-	for _, filePath := range markdownFilePaths(rootPath) {
-		tasks.Tasks = append(tasks.Tasks, findTasks(filePath)...)
+	flags := flag.NewFlagSet(command.Name, flag.ExitOnError)
+	flags.Usage = func() {
+		usage(flags, command)
 	}
-	// Sort by date, keeping original order or equal elements.
-	sort.SliceStable(tasks.Tasks, func(i, j int) bool {
-		return tasks.Tasks[i].Date.Unix() < tasks.Tasks[j].Date.Unix()
-	})
+	logOptions := LogOptions{}
+	configFilename := defineCommonFlags(flags, &logOptions)
+	run := command.Setup(flags)
+	flags.Parse(args)
 
-	tasks.writeToFile(*outputFilename)
-}
-
-func (tasks Tasks) completedCount() int {
-	count := 0
-	for _, task := range tasks.Tasks {
-		if task.Complete {
-			count++
-		}
+	if *configFilename == "" {
+		*configFilename = findConfig()
 	}
-	return count
-}
-
-func findTasks(file File) []Task {
-	tasks := []Task{}
-	if file.Name == defaultOutputFilename {
-		return tasks
+	if *configFilename != "" {
+		if err := applyConfig(flags, *configFilename); err != nil {
+			fail(err)
+		}
 	}
-
-	readFile, err := os.Open(file.Path)
-	if err != nil {
-		return tasks
+	if err := logOptions.setup(); err != nil {
+		fail(err)
 	}
-	defer readFile.Close()
-
-	date := file.Date
-	lastHeader := ""
-	fileScanner := bufio.NewScanner(readFile)
-	fileScanner.Split(bufio.ScanLines)
 
-	for fileScanner.Scan() {
-		line := fileScanner.Text()
-		date = parseDate(dateHeaderPattern, line, date)
-		lastHeader = parseLastHeader(line, lastHeader)
-
-		if task, isTask := parseTask(*date, lastHeader, file.Path, line); isTask {
-			tasks = append(tasks, *task)
-		}
+	if err := run(flags.Args()); err != nil {
+		fail(err)
 	}
-
-	return tasks
 }
 
-func (tasks Tasks) incompleteCount() int {
-	return len(tasks.Tasks) - tasks.completedCount()
+// defineCommonFlags defines the flags every command takes, returning the
+// -config file named.
+func defineCommonFlags(flags *flag.FlagSet, logOptions *LogOptions) *string {
+	configFilename := flags.String("config", "", fmt.Sprintf("settings file to read flag values from (default=%s if present)", strings.Join(configFilenames, ", ")))
+	defineLogFlags(flags, logOptions)
+	return configFilename
 }
 
-func markdownFilePaths(dirPath string) []File {
-	paths := []File{}
-	files, err := ioutil.ReadDir(dirPath)
-	if err != nil {
-		log.Fatal(err)
+// fail logs the error and exits with its exit code, or exitError when it
+// doesn't have one.
+func fail(err error) {
+	slog.Error(err.Error())
+	var exit exitCodeError
+	if errors.As(err, &exit) {
+		os.Exit(exit.code)
 	}
-
-	for _, file := range files {
-		date := parseDateFromFile(file)
-		filename := file.Name()
-		filePath := path.Join(dirPath, filename)
-		if file.IsDir() {
-			paths = append(paths, markdownFilePaths(filePath)...)
-		} else {
-			isMarkdownFile, _ := regexp.MatchString(markdownFilenamePattern, filename)
-			if isMarkdownFile {
-				paths = append(paths, File{Date: date, Name: file.Name(), Path: filePath})
-			}
-		}
-	}
-
-	return paths
+	os.Exit(exitError)
 }
 
-func parseDate(pattern, text string, lastDate *time.Time) *time.Time {
-	re := regexp.MustCompile(pattern)
-	match := re.FindSubmatch([]byte(text))
-	if len(match) == 2 {
-		parsedDate, err := time.Parse(yearMonthDayLayout, string(match[1]))
-		if err != nil {
-			return lastDate
+// parseCommand returns the command named at the start of the arguments, or
+// the default command, and the arguments after its name. Commands of another
+// command, such as sync github, are named by two words.
+func parseCommand(args []string) (Command, []string) {
+	if len(args) > 1 {
+		if named, ok := findCommand(args[0] + " " + args[1]); ok {
+			return named, args[2:]
 		}
-		return &parsedDate
 	}
-
-	return lastDate
-}
-
-func parseLastHeader(line, lastHeader string) string {
-	isHeader, _ := regexp.MatchString(headerPattern, line)
-	if isHeader {
-		return strings.TrimLeft(line, "# ")
+	if len(args) > 0 {
+		if named, ok := findCommand(args[0]); ok {
+			return named, args[1:]
+		}
 	}
-	return lastHeader
-
+	return commands[0], args
 }
 
-func parseDateFromFile(file fs.FileInfo) *time.Time {
-	var date *time.Time
-	if result := parseDate(datePattern, file.Name(), date); result != nil {
-		return result
-	}
-
-	// TODO: this only works on MAC
-	if call, ok := file.Sys().(*syscall.Stat_t); ok {
-		result := time.Unix((*call).Birthtimespec.Sec, (*call).Birthtimespec.Nsec)
-		date = &result
+func findCommand(name string) (Command, bool) {
+	for _, command := range commands {
+		if command.Name == name {
+			return command, true
+		}
 	}
-
-	return date
+	return Command{}, false
 }
 
-func parseTask(date time.Time, lastHeader, filePath, line string) (*Task, bool) {
-	completeTask, _ := regexp.MatchString(completeTaskPattern, line)
-	incompleteTask, _ := regexp.MatchString(incompleteTaskPattern, line)
-	if completeTask || incompleteTask {
-		text := strings.TrimSpace(line[strings.Index(line, "]")+1:])
-		return &Task{
-			Complete:       completeTask,
-			Date:           date,
-			FilePath:       filePath,
-			PreviousHeader: lastHeader,
-			Text:           text,
-		}, true
-
+func usage(flags *flag.FlagSet, command Command) {
+	out := flags.Output()
+	fmt.Fprintf(out, "Usage: %s [command] [flags] [directories]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-12s %s\n", c.Name, c.Description)
 	}
-
-	return nil, false
+	fmt.Fprintf(out, "\nFlags for %s:\n", command.Name)
+	flags.PrintDefaults()
 }
 
-func (tasks Tasks) String() string {
-	var out strings.Builder
-	lastDate := time.Time{}
-	for _, task := range tasks.Tasks {
-		if task.Complete && !tasks.OutputCompleted {
-			continue
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
-
-		// if new day, make a date header
-		if task.Date.Format(yearMonthDayLayout) != lastDate.Format(yearMonthDayLayout) {
-			// new line before date header if not beginning of file
-			if !lastDate.IsZero() {
-				out.WriteString("\n")
-			}
-			lastDate = task.Date
-			out.WriteString(fmt.Sprintf("# %s\n\n", task.Date.Format(yearMonthDayLayout)))
-		}
-		check := " "
-		if task.Complete {
-			check = "x"
-		}
-
-		out.WriteString(fmt.Sprintf("- [%s] [%s](%s)\n", check, task.Text, taskPath(task.FilePath, task.PreviousHeader)))
 	}
-
-	return out.String()
+	return false
 }
 
-func taskPath(filePath, lastHeader string) string {
-	f := func(c rune) bool {
-		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
-	}
-	taskPath := filePath
-	if lastHeader != "" {
-		taskPath = fmt.Sprintf("%s#%s", filePath, strings.Join(strings.FieldsFunc(lastHeader, f), "-"))
-	}
-
-	return taskPath
+func (values *Strings) Set(value string) error {
+	*values = append(*values, value)
+	return nil
 }
 
-func (tasks Tasks) writeToFile(outputFilename string) {
-	file, err := os.Create(outputFilename)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	defer file.Close()
-
-	fmt.Printf("%d incomplete out of %d total tasks, writing to file '%s'\n", tasks.incompleteCount(), len(tasks.Tasks), outputFilename)
-	file.WriteString(tasks.String())
+func (values *Strings) String() string {
+	return strings.Join(*values, ",")
 }
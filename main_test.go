@@ -0,0 +1,699 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+// inTempDir runs the rest of the test in a new directory holding the notes,
+// by file name under notes/, so reports, caches, and state are written
+// there.
+func inTempDir(t *testing.T, notes map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, note := range notes {
+		if err := os.WriteFile(filepath.Join(dir, "notes", name), []byte(note), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(previous)
+	})
+}
+
+// runCommand runs the command named by the arguments as main does, without
+// reading a config file or exiting.
+func runCommand(t *testing.T, args ...string) error {
+	t.Helper()
+	command, args := parseCommand(args)
+	flags := flag.NewFlagSet(command.Name, flag.ContinueOnError)
+	defineCommonFlags(flags, &LogOptions{})
+	run := command.Setup(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return run(flags.Args())
+}
+
+// readFile returns the contents of the file, failing the test when it can't
+// be read.
+func readFile(t *testing.T, filename string) string {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// editReport rewrites the report with the replacement, failing the test when
+// the report doesn't hold old.
+func editReport(t *testing.T, old, new string) {
+	t.Helper()
+	report := readFile(t, "TASKS.md")
+	if !strings.Contains(report, old) {
+		t.Fatalf("report doesn't hold %q:\n%s", old, report)
+	}
+	if err := os.WriteFile("TASKS.md", []byte(strings.Replace(report, old, new, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		flags []string
+		note  string
+		want  string
+	}{
+		{"checkbox", nil, "- [ ] ship it\n- [ ] keep\n", "- [x] ship it\n- [ ] keep\n"},
+		{"logseq", []string{"-flavor", "logseq"}, "- TODO ship it\n- TODO keep\n", "- DONE ship it\n- TODO keep\n"},
+		{"lenient", []string{"-lenient"}, "- [] ship it\n-[ ] keep\n", "- [x] ship it\n-[ ] keep\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t, map[string]string{"a.md": test.note})
+			if err := runCommand(t, append(append([]string{"-no-cache"}, test.flags...), "notes")...); err != nil {
+				t.Fatal(err)
+			}
+			editReport(t, "- [ ] [ship it]", "- [x] [ship it]")
+			if err := runCommand(t, append(append([]string{"sync", "-no-cache"}, test.flags...), "notes")...); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFile(t, "notes/a.md"); got != test.want {
+				t.Errorf("got note %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	inTempDir(t, map[string]string{"a.md": "# Today\n- [ ] ship it\n"})
+	if err := runCommand(t, "complete", "notes/a.md:2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, "notes/a.md"); got != "# Today\n- [x] ship it\n" {
+		t.Errorf("got note %q after completing", got)
+	}
+	if err := runCommand(t, "complete", "-undo", "notes/a.md:2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, "notes/a.md"); got != "# Today\n- [ ] ship it\n" {
+		t.Errorf("got note %q after undoing", got)
+	}
+	if err := runCommand(t, "complete", "notes/a.md:1"); err == nil {
+		t.Error("completed a header, want an error")
+	}
+}
+
+func TestArchive(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		flags []string
+		note  string
+		want  string
+	}{
+		{"checkbox", nil, "- [x] shipped\n  - notes\n- [ ] open\n", "- [ ] open\n"},
+		{"lenient", []string{"-lenient"}, "- [ x ] shipped\n  - notes\n- [ ] open\n", "- [ ] open\n"},
+		{"logseq", []string{"-flavor", "logseq"}, "- DONE shipped\n  - notes\n- TODO open\n", "- TODO open\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t, map[string]string{"2024-01-01.md": test.note})
+			if err := runCommand(t, append(append([]string{"archive", "-days", "0"}, test.flags...), "notes")...); err != nil {
+				t.Fatal(err)
+			}
+			if got := readFile(t, "notes/2024-01-01.md"); got != test.want {
+				t.Errorf("got note %q, want %q", got, test.want)
+			}
+			if archive := readFile(t, "ARCHIVE.md"); !strings.Contains(archive, "shipped") || !strings.Contains(archive, "  - notes") {
+				t.Errorf("archive is missing the task:\n%s", archive)
+			}
+		})
+	}
+}
+
+func TestSyncTodoistPull(t *testing.T) {
+	inTempDir(t, map[string]string{"a.md": "- [ ] ship it\n"})
+	t.Setenv(todoistTokenVariable, "token")
+	completed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/projects":
+			json.NewEncoder(w).Encode([]map[string]string{{"id": "1", "name": defaultTodoistProject}})
+		case r.Method == http.MethodGet && r.URL.Path == "/tasks":
+			active := []todoistTask{}
+			if !completed {
+				active = append(active, todoistTask{Content: "ship it", ID: "t1"})
+			}
+			json.NewEncoder(w).Encode(active)
+		case r.Method == http.MethodPost && r.URL.Path == "/tasks":
+			json.NewEncoder(w).Encode(todoistTask{ID: "t1"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	if err := runCommand(t, "sync", "todoist", "-no-cache", "-api-url", server.URL, "notes"); err != nil {
+		t.Fatal(err)
+	}
+	completed = true
+	if err := runCommand(t, "sync", "todoist", "-no-cache", "-api-url", server.URL, "-pull", "notes"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, "notes/a.md"); got != "- [x] ship it\n" {
+		t.Errorf("got note %q, want the task checked off", got)
+	}
+}
+
+func TestScanStrict(t *testing.T) {
+	inTempDir(t, map[string]string{"a.md": "- [ ] ship it\n"})
+	if err := os.Symlink("missing.md", filepath.Join("notes", "b.md")); err != nil {
+		t.Skip("can't create symlinks:", err)
+	}
+	// the unreadable path fails the run without exiting, so rescans can go on
+	err := runCommand(t, "stats", "-strict", "-follow-symlinks", "-no-cache", "notes")
+	var exit exitCodeError
+	if !errors.As(err, &exit) || exit.code != exitProblems {
+		t.Errorf("got error %v, want exit code %d", err, exitProblems)
+	}
+}
+
+func TestGates(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		flag string
+		note string
+		code int
+	}{
+		{"overdue", "-fail-if-overdue", "- [ ] late 📅 2024-01-01\n", exitOpenTasks},
+		{"cancelled past due", "-fail-if-overdue", "- [x] shipped\n- [-] dropped 📅 2024-01-01\n", 0},
+		{"incomplete", "-fail-on-incomplete", "- [x] shipped\n- [ ] open\n", exitOpenTasks},
+		{"cancelled", "-fail-on-incomplete", "- [x] shipped\n- [-] dropped\n", 0},
+		{"no tasks", "-fail-on-incomplete", "no tasks here\n", exitNoTasks},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t, map[string]string{"a.md": test.note})
+			err := runCommand(t, "-no-cache", test.flag, "notes")
+			var exit exitCodeError
+			if test.code == 0 && err != nil {
+				t.Errorf("got error %v, want none", err)
+			} else if test.code != 0 && (!errors.As(err, &exit) || exit.code != test.code) {
+				t.Errorf("got error %v, want exit code %d", err, test.code)
+			}
+		})
+	}
+}
+
+func TestGitHubIssuesPaging(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/name/issues":
+			// GitHub names later pages by repository ID, not owner/name
+			w.Header().Set("Link", "<"+server.URL+"/repositories/1/issues?page=2&state=all>; rel=\"next\"")
+			json.NewEncoder(w).Encode([]gitHubIssue{{Number: 1}})
+		case "/repositories/1/issues":
+			if r.URL.Query().Get("page") != "2" {
+				t.Errorf("got query %q, want the one given by the link", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode([]gitHubIssue{{Number: 2}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	issues, err := gitHubClient{apiURL: server.URL, repo: "owner/name"}.issues(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 2 || issues[0].Number != 1 || issues[1].Number != 2 {
+		t.Errorf("got issues %+v, want #1 and #2", issues)
+	}
+
+	if _, err := (gitHubClient{apiURL: server.URL}).nextPage(`<https://elsewhere.example/issues?page=2>; rel="next"`); err == nil {
+		t.Error("followed a next page on another host, want an error")
+	}
+}
+
+func TestStore(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tasks.db")
+	due := time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)
+	open := tasks.Task{Due: &due, FilePath: "a.md", Line: 1, Tags: []string{"#work", "@sam"}, Text: "ship 'it'"}
+	dropped := tasks.Task{FilePath: "a.md", Line: 2, Text: "drop it"}
+	first := time.Date(2024, time.January, 1, 9, 0, 0, 0, time.UTC)
+	if err := updateStore(dbPath, []tasks.Task{open, dropped}, first); err != nil {
+		t.Fatal(err)
+	}
+	done := open
+	done.Complete, done.Status = true, tasks.StatusDone
+	if err := updateStore(dbPath, []tasks.Task{done}, first.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := storeTasks(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("got %d tasks, want only the one still found", len(found))
+	}
+	if got := found[0]; got.Text != "ship 'it'" || !got.Complete || got.Status != tasks.StatusDone || got.Due == nil || !got.Due.Equal(due) || len(got.Assignees) != 1 || got.Assignees[0] != "sam" {
+		t.Errorf("got task %+v, want it as last found", got)
+	}
+
+	history, err := storeHistory(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || !history[0].Complete || history[0].Text != "ship 'it'" || !history[0].Time.Equal(first.Add(time.Hour)) {
+		t.Errorf("got history %+v, want the task checked off at the second update", history)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	inTempDir(t, map[string]string{"a.md": "- [ ] ship it\n- [ ] drop it\n"})
+	changes := func() taskChanges {
+		t.Helper()
+		output, err := os.CreateTemp(t.TempDir(), "stdout")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout := os.Stdout
+		os.Stdout = output
+		err = runCommand(t, "changes", "-json", "notes")
+		os.Stdout = stdout
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes := taskChanges{}
+		if data := readFile(t, output.Name()); data != "" {
+			if err := json.Unmarshal([]byte(data), &changes); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return changes
+	}
+
+	// the first run only records the tasks
+	changes()
+	if err := os.WriteFile("notes/a.md", []byte("- [x] ship it\n- [ ] new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// other runs rewriting the cache don't count as runs of changes
+	if err := runCommand(t, "notes"); err != nil {
+		t.Fatal(err)
+	}
+	got := changes()
+	if len(got.Added) != 1 || got.Added[0].Text != "new" || len(got.Completed) != 1 || got.Completed[0].Text != "ship it" || len(got.Removed) != 1 || got.Removed[0].Text != "drop it" {
+		t.Errorf("got changes %+v, want new added, ship it completed, and drop it removed", got)
+	}
+	if got := changes(); len(got.Added)+len(got.Completed)+len(got.Reopened)+len(got.Removed) != 0 {
+		t.Errorf("got changes %+v on running again, want none", got)
+	}
+}
+
+func TestAggregateWriteError(t *testing.T) {
+	inTempDir(t, map[string]string{"a.md": "- [ ] ship it\n"})
+	// the first output can't be written, but the second still is
+	err := runCommand(t, "-no-cache", "-o", "missing/TASKS.md", "-o", "OTHER.md", "notes")
+	var exit exitCodeError
+	if err == nil || errors.As(err, &exit) {
+		t.Errorf("got error %v, want one exiting with %d", err, exitError)
+	}
+	if report := readFile(t, "OTHER.md"); !strings.Contains(report, "ship it") {
+		t.Errorf("other output is missing the task:\n%s", report)
+	}
+}
+
+// newTestDashboard returns a dashboard serving the tasks in the notes, scanned
+// as serve would with the flags.
+func newTestDashboard(t *testing.T, notes map[string]string, args ...string) *dashboard {
+	t.Helper()
+	inTempDir(t, notes)
+	options := Options{}
+	flags := flag.NewFlagSet(commandServe, flag.ContinueOnError)
+	defineScanFlags(flags, &options)
+	defineRenderFlags(flags, &options)
+	if err := flags.Parse(append([]string{"-no-cache"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := options.prepare([]string{"notes"}); err != nil {
+		t.Fatal(err)
+	}
+	board := &dashboard{options: options}
+	if err := board.scan(); err != nil {
+		t.Fatal(err)
+	}
+	return board
+}
+
+func TestServeTasks(t *testing.T) {
+	board := newTestDashboard(t, map[string]string{"a.md": "- [ ] one #work\n- [ ] two\n- [ ] three #work\n- [x] four #work\n"})
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			request.Header[name] = values
+		}
+		recorder := httptest.NewRecorder()
+		board.handleTasks(recorder, request)
+		return recorder
+	}
+	texts := func(recorder *httptest.ResponseRecorder) []string {
+		t.Helper()
+		found := []tasks.Task{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &found); err != nil {
+			t.Fatalf("%v: %s", err, recorder.Body)
+		}
+		texts := []string{}
+		for _, task := range found {
+			texts = append(texts, strings.Fields(task.Text)[0])
+		}
+		return texts
+	}
+
+	for _, test := range []struct {
+		target string
+		want   string
+		total  string
+		next   string
+	}{
+		{"/api/tasks", "one,two,three", "3", ""},
+		{"/api/tasks?tag=%23work", "one,three", "2", ""},
+		{"/api/tasks?tag=%23work&completed=true", "one,three,four", "3", ""},
+		{"/api/tasks?status=done", "four", "1", ""},
+		{"/api/tasks?limit=2", "one,two", "3", `</api/tasks?limit=2&offset=2>; rel="next"`},
+		{"/api/tasks?limit=2&offset=2", "three", "3", ""},
+		{"/api/tasks?offset=10", "", "3", ""},
+	} {
+		recorder := get(test.target, nil)
+		if recorder.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want 200", test.target, recorder.Code)
+			continue
+		}
+		if got := strings.Join(texts(recorder), ","); got != test.want {
+			t.Errorf("%s: got tasks %q, want %q", test.target, got, test.want)
+		}
+		if got := recorder.Header().Get("X-Total-Count"); got != test.total {
+			t.Errorf("%s: got total %s, want %s", test.target, got, test.total)
+		}
+		if got := recorder.Header().Get("Link"); got != test.next {
+			t.Errorf("%s: got link %q, want %q", test.target, got, test.next)
+		}
+	}
+
+	etag := get("/api/tasks", nil).Header().Get("ETag")
+	if recorder := get("/api/tasks", http.Header{"If-None-Match": {etag}}); recorder.Code != http.StatusNotModified || recorder.Body.Len() > 0 {
+		t.Errorf("got status %d with %d bytes for a matching ETag, want 304 and no body", recorder.Code, recorder.Body.Len())
+	}
+	if recorder := get("/api/tasks", http.Header{"If-None-Match": {`"stale"`}}); recorder.Code != http.StatusOK {
+		t.Errorf("got status %d for a stale ETag, want 200", recorder.Code)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	board := newTestDashboard(t, map[string]string{"a.md": "- [ ] one\n"})
+	for _, test := range []struct {
+		method string
+		target string
+		code   int
+	}{
+		{http.MethodPost, "/api/tasks", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/tasks?status=unknown", http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?since=yesterday", http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?limit=0", http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?limit=1001", http.StatusBadRequest},
+		{http.MethodGet, "/api/tasks?offset=-1", http.StatusBadRequest},
+		{http.MethodDelete, "/api/stats", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/api/files", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/missing", http.StatusNotFound},
+	} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(test.method, test.target, nil)
+		switch request.URL.Path {
+		case "/api/tasks":
+			board.handleTasks(recorder, request)
+		case "/api/stats":
+			board.handleStats(recorder, request)
+		case "/api/files":
+			board.handleFiles(recorder, request)
+		default:
+			board.handleDashboard(recorder, request)
+		}
+		if recorder.Code != test.code {
+			t.Errorf("%s %s: got status %d, want %d", test.method, test.target, recorder.Code, test.code)
+		}
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	board := newTestDashboard(t, map[string]string{"a.md": "- [ ] ship it\n"})
+	server := httptest.NewServer(http.HandlerFunc(board.handleEvents))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	stream := bufio.NewReader(response.Body)
+	// the client is subscribed once the first comment arrives
+	if line, err := stream.ReadString('\n'); err != nil || line != ": connected\n" {
+		t.Fatalf("got %q, %v, want the connected comment", line, err)
+	}
+
+	if err := os.WriteFile("notes/a.md", []byte("- [x] ship it\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := board.scan(); err != nil {
+		t.Fatal(err)
+	}
+	event := ""
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = strings.TrimSpace(name)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			task := tasks.Task{}
+			if err := json.Unmarshal([]byte(data), &task); err != nil {
+				t.Fatal(err)
+			}
+			if event != eventCompleted || task.Text != "ship it" || !task.Complete {
+				t.Errorf("got %s event for %+v, want the task completed", event, task)
+			}
+			break
+		}
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		args []string
+		want []string
+		err  string
+	}{
+		{"default", nil, []string{"TASKS.md markdown date"}, ""},
+		{"settings", []string{"-o", "OPEN.md?incomplete-only&group-by=tag", "-o", "ALL.md"}, []string{"OPEN.md markdown tag incomplete", "ALL.md markdown date"}, ""},
+		{"shared flags", []string{"-group-by", "file", "-o", "A.md", "-o", "B.md?group-by=tag"}, []string{"A.md markdown file", "B.md markdown tag"}, ""},
+		{"format by extension", []string{"-o", "tasks.json", "-o", "tasks.ics"}, []string{"tasks.json json date", "tasks.ics ics date"}, ""},
+		{"format flag", []string{"-format", "csv", "-o", "tasks.json"}, []string{"tasks.json csv date"}, ""},
+		{"format setting", []string{"-o", "report.md?format=html"}, []string{"report.md html date"}, ""},
+		{"stdout", []string{"-o", "-?incomplete-only"}, []string{"- markdown date incomplete"}, ""},
+		{"unknown setting", []string{"-o", "A.md?colour=red"}, nil, "unknown setting 'colour'"},
+		{"scan flag", []string{"-o", "A.md?strict"}, nil, "-strict can't be set for one output"},
+		{"bad value", []string{"-o", "A.md?incomplete-only=maybe"}, nil, "-incomplete-only"},
+		{"bad query", []string{"-o", "A.md?%zz"}, nil, "-o A.md?%zz"},
+		{"bad format", []string{"-o", "A.md?format=pdf"}, nil, "unknown format 'pdf'"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			options := Options{}
+			flags := flag.NewFlagSet(commandAggregate, flag.ContinueOnError)
+			defineAggregateFlags(flags, &options)
+			if err := flags.Parse(test.args); err != nil {
+				t.Fatal(err)
+			}
+
+			outputs, err := options.outputs(flags)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("got error %v, want one mentioning %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, output := range outputs {
+				described := fmt.Sprintf("%s %s %s", output.OutputFilename, output.Format, output.GroupBy)
+				if output.IncompleteOnly {
+					described += " incomplete"
+				}
+				got = append(got, described)
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("got outputs %q, want %q", got, test.want)
+			}
+			if options.IncompleteOnly {
+				t.Error("a setting for one output was left in the shared options")
+			}
+		})
+	}
+}
+
+func TestPerDirectory(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		args []string
+		// want maps each report to the task links it should hold, and the
+		// reports that shouldn't be written to nothing
+		want map[string][]string
+	}{
+		{"one root", []string{"-per-directory", "notes"}, map[string][]string{
+			"TASKS.md":            {"(proj/x.md)", "(top.md)"},
+			"notes/proj/TASKS.md": {"(x.md)"},
+			"other/proj/TASKS.md": nil,
+		}},
+		{"named output", []string{"-o", "OPEN.md?per-directory", "notes"}, map[string][]string{
+			"OPEN.md":            {"(proj/x.md)", "(top.md)"},
+			"notes/proj/OPEN.md": {"(x.md)"},
+		}},
+		{"only", []string{"-per-directory-only", "-per-directory", "notes"}, map[string][]string{
+			"TASKS.md":            nil,
+			"notes/proj/TASKS.md": {"(x.md)"},
+		}},
+		// both roots have a proj directory, which each keep to their own
+		{"same name in two roots", []string{"-per-directory", "notes", "other"}, map[string][]string{
+			"notes/proj/TASKS.md": {"(x.md)"},
+			"other/proj/TASKS.md": {"(z.md)"},
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t, map[string]string{"top.md": "- [ ] top\n"})
+			for name, note := range map[string]string{"notes/proj/x.md": "- [ ] ship x\n", "other/proj/z.md": "- [ ] ship z\n"} {
+				if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(name, []byte(note), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// running again checks the reports written aren't scanned
+			for run := 0; run < 2; run++ {
+				if err := runCommand(t, append([]string{"-no-cache"}, test.args...)...); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for report, links := range test.want {
+				data, err := os.ReadFile(report)
+				if links == nil {
+					if err == nil {
+						t.Errorf("%s was written, want none", report)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := strings.Count(string(data), "]("); got != len(links) {
+					t.Errorf("%s holds %d tasks, want %d:\n%s", report, got, len(links), data)
+				}
+				for _, link := range links {
+					if !strings.Contains(string(data), link) {
+						t.Errorf("%s doesn't link to %s:\n%s", report, link, data)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	inTempDir(t, map[string]string{"a.md": "- [ ] ship it\n"})
+	options := Options{}
+	flags := flag.NewFlagSet(commandAggregate, flag.ContinueOnError)
+	defineAggregateFlags(flags, &options)
+	if err := flags.Parse([]string{"-no-cache", "-watch"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := options.prepare([]string{"notes"}); err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := options.outputs(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regenerated := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		watch(options, outputFilenames(outputs), func() {
+			regenerate(outputs)
+			select {
+			case regenerated <- struct{}{}:
+			default:
+			}
+		}, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	// the note is written again until the watcher, started in the background,
+	// sees it
+	for attempt := 0; ; attempt++ {
+		if err := os.WriteFile("notes/a.md", []byte("- [ ] ship it\n- [ ] new\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-regenerated:
+		case <-time.After(time.Second):
+			if attempt < 10 {
+				continue
+			}
+			t.Fatal("the output wasn't regenerated")
+		}
+		break
+	}
+	if report := readFile(t, "TASKS.md"); !strings.Contains(report, "[new]") {
+		t.Errorf("regenerated report is missing the new task:\n%s", report)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	// notifyStateFilename records the tasks seen by the last run notifying,
+	// so the next one can tell what changed.
+	notifyStateFilename = ".task-aggregator-notify.json"
+	// notifyListLength is the most tasks listed under each heading of a
+	// notification, with the rest counted.
+	notifyListLength = 20
+	// discordMessageLength is the longest message a Discord webhook accepts.
+	discordMessageLength = 2000
+)
+
+// notifySchemes maps the scheme of each -notify URL to the chat it posts to.
+var notifySchemes = map[string]notifyChat{
+	"slack":   {bold: "*", field: "text"},
+	"discord": {bold: "**", field: "content", limit: discordMessageLength},
+}
+
+// notifyChat is how a chat's webhook takes a message: the JSON field holding
+// it, the mark around bold text, and the longest it can be, or zero for no
+// limit.
+type notifyChat struct {
+	bold  string
+	field string
+	limit int
+}
+
+// notifySeen is the state of a task at the last run notifying.
+type notifySeen struct {
+	Complete bool `json:"complete"`
+	Overdue  bool `json:"overdue"`
+}
+
+// notifyChanges are the tasks added, completed, and newly overdue since the
+// last run.
+type notifyChanges struct {
+	added     []tasks.Task
+	completed []tasks.Task
+	overdue   []tasks.Task
+}
+
+// checkNotifyURLs returns an error for a -notify URL that isn't a slack:// or
+// discord:// webhook.
+func checkNotifyURLs(urls []string) error {
+	for _, url := range urls {
+		scheme, _, ok := strings.Cut(url, "://")
+		if _, known := notifySchemes[scheme]; !ok || !known {
+			return fmt.Errorf("-notify '%s' must start with slack:// or discord://", url)
+		}
+	}
+	return nil
+}
+
+// notify posts a summary of the tasks added, completed, and newly overdue
+// since the last run to each -notify webhook, then records the tasks for the
+// next run. The first run only records them, and nothing is posted when
+// nothing changed. Tasks are recognized across runs by their changeKey.
+func notify(all []tasks.Task, urls []string) {
+	seen, err := loadNotifyState(notifyStateFilename)
+	if err != nil {
+		slog.Warn("can't read notify state", "error", err)
+		return
+	}
+
+	changes := notifyChanges{}
+	next := map[string]notifySeen{}
+	for _, task := range all {
+		key := changeKey(task)
+		now := notifySeen{Complete: task.Complete, Overdue: task.Overdue()}
+		next[key] = now
+		before, known := seen[key]
+		switch {
+		case seen == nil:
+		case !known && !task.Complete:
+			changes.added = append(changes.added, task)
+		case task.Complete && known && !before.Complete:
+			changes.completed = append(changes.completed, task)
+		case now.Overdue && !before.Overdue:
+			changes.overdue = append(changes.overdue, task)
+		}
+	}
+
+	if len(changes.added)+len(changes.completed)+len(changes.overdue) > 0 {
+		for _, url := range urls {
+			if err := postNotification(url, changes); err != nil {
+				slog.Warn("can't post notification", "url", redactWebhook(url), "error", err)
+			}
+		}
+	}
+	saveNotifyState(notifyStateFilename, next)
+}
+
+// postNotification posts the changes to the webhook at the slack:// or
+// discord:// URL, which is called over https.
+func postNotification(url string, changes notifyChanges) error {
+	scheme, address, _ := strings.Cut(url, "://")
+	chat := notifySchemes[scheme]
+	body, err := json.Marshal(map[string]string{chat.field: chat.message(changes)})
+	if err != nil {
+		return err
+	}
+	response, err := httpClient.Post("https://"+address, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", response.Status)
+	}
+	return nil
+}
+
+// message writes the changes as a summary line followed by a list of the
+// tasks under each kind of change, cut to the chat's limit.
+func (chat notifyChat) message(changes notifyChanges) string {
+	lines := []string{fmt.Sprintf("%sTasks:%s %d added, %d completed, %d newly overdue", chat.bold, chat.bold, len(changes.added), len(changes.completed), len(changes.overdue))}
+	for _, section := range []struct {
+		title string
+		tasks []tasks.Task
+	}{
+		{"Added", changes.added},
+		{"Completed", changes.completed},
+		{"Overdue", changes.overdue},
+	} {
+		if len(section.tasks) == 0 {
+			continue
+		}
+		lines = append(lines, "", chat.bold+section.title+chat.bold)
+		for i, task := range section.tasks {
+			if i == notifyListLength {
+				lines = append(lines, fmt.Sprintf("…and %d more", len(section.tasks)-i))
+				break
+			}
+			line := fmt.Sprintf("• %s (%s)", task.Text, task.FilePath)
+			if task.Due != nil && section.title == "Overdue" {
+				line = fmt.Sprintf("• %s, due %s (%s)", task.Text, task.Due.Format("2006-01-02"), task.FilePath)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	message := strings.Join(lines, "\n")
+	if runes := []rune(message); chat.limit > 0 && len(runes) > chat.limit {
+		message = string(runes[:chat.limit-1]) + "…"
+	}
+	return message
+}
+
+// changeKey recognizes a task across scans by its file and its text without
+// any completion date, so it's the same task after being moved within its
+// note or checked off.
+func changeKey(task tasks.Task) string {
+	return task.FilePath + "\x00" + tasks.CompletionKey(task.Text)
+}
+
+// redactWebhook returns the URL without the secret path of the webhook, for
+// logging.
+func redactWebhook(url string) string {
+	scheme, address, _ := strings.Cut(url, "://")
+	host, _, _ := strings.Cut(address, "/")
+	return scheme + "://" + host
+}
+
+// loadNotifyState returns the tasks seen by the last run, or nil when there
+// was none.
+func loadNotifyState(filename string) (map[string]notifySeen, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]notifySeen{}
+	if err := json.Unmarshal(data, &seen); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return seen, nil
+}
+
+func saveNotifyState(filename string, seen map[string]notifySeen) {
+	data, err := json.Marshal(seen)
+	if err != nil {
+		slog.Warn("can't save notify state", "error", err)
+		return
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		slog.Warn("can't save notify state", "error", err)
+	}
+}
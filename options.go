@@ -0,0 +1,620 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+// Options are the flag values shared by the commands that scan for tasks.
+type Options struct {
+	AnchorStyle           string
+	Assignees             Strings
+	Breadcrumbs           bool
+	Chart                 string
+	CollapseCompleted     bool
+	Columns               string
+	CompletedOnly         bool
+	CompletedSince        string
+	Context               int
+	DateFormat            string
+	Days                  int
+	DateFrom              string
+	DateSource            string
+	Dedupe                bool
+	DryRun                bool
+	Editor                string
+	Exclude               Strings
+	ExcludeOutputPatterns Strings
+	ExcludeTags           Strings
+	FailIfOverdue         bool
+	FailOnIncomplete      bool
+	FilenameDateFormats   Strings
+	Flavor                string
+	FollowSymlinks        bool
+	Format                string
+	GroupBy               string
+	History               bool
+	Horizon               string
+	ICSComponent          string
+	IncludeCodeBlocks     bool
+	IncludeExt            string
+	IncludeSource         bool
+	IncludeTodos          bool
+	IncompleteOnly        bool
+	Jobs                  int
+	Layout                string
+	Lenient               bool
+	Limit                 int
+	LinkStyle             string
+	MaxDepth              int
+	MaxEstimate           string
+	MaxFileSize           string
+	MaxFiles              int
+	MaxLineLength         string
+	MinEstimate           string
+	Mode                  string
+	NoCache               bool
+	Org                   bool
+	Notify                Strings
+	OutputCompleted       bool
+	OutputFilename        string
+	Order                 string
+	Outputs               Strings
+	PerDirectory          bool
+	PerDirectoryOnly      bool
+	Progress              bool
+	Plugins               Strings
+	RelativeLinks         bool
+	ReportPatterns        []string
+	Reports               []string
+	Roots                 Strings
+	Rollup                bool
+	ShowCancelled         bool
+	ShowOmitted           bool
+	ShowProblems          bool
+	SourceExt             string
+	TableColumns          string
+	Since                 string
+	SplitByAssignee       bool
+	StaleAfter            int
+	StdinName             string
+	Sort                  string
+	Statuses              Strings
+	Store                 string
+	Strict                bool
+	Template              string
+	Tags                  Strings
+	Timezone              string
+	TodoKeywords          Strings
+	Undated               string
+	Until                 string
+	Watch                 bool
+	Where                 Strings
+}
+
+const (
+	defaultRootPath    = "."
+	yearMonthDayLayout = "2006-01-02"
+)
+
+// markupParsers read the other markups -include-ext adds, by extension.
+var markupParsers = map[string]tasks.Parser{
+	"adoc":     tasks.AsciiDocParser{},
+	"asciidoc": tasks.AsciiDocParser{},
+	"rst":      tasks.RSTParser{},
+}
+
+// defineScanFlags defines the flags selecting which files are scanned and
+// which of the tasks found are kept, and in what order.
+func defineScanFlags(flags *flag.FlagSet, options *Options) {
+	flags.Var(&options.Assignees, "assignee", "only output tasks assigned to this person with an @mention, may be repeated")
+	flags.BoolVar(&options.CompletedOnly, "completed-only", false, "true to output only completed tasks (default=false)")
+	flags.StringVar(&options.CompletedSince, "completed-since", "", "only output tasks completed on or after this YYYY-MM-DD date")
+	flags.IntVar(&options.Context, "context", 0, "number of lines around each task, or the list item it's nested under, to keep and show with it (default=0)")
+	flags.StringVar(&options.DateFrom, "date-from", tasks.DateFromFile, fmt.Sprintf("how to date tasks that no header, front matter, or file name dates, one of %s, where git dates them by the commit adding their line (default=%s)", strings.Join(tasks.DateFromOptions, ", "), tasks.DateFromFile))
+	flags.StringVar(&options.DateSource, "date-source", "", fmt.Sprintf("comma-separated sources to date each task by the first of that gives it a date, of %s, in place of -date-from, as in header,filename,frontmatter,git,birthtime,mtime (default=header, frontmatter, filename, then -date-from)", strings.Join(tasks.DateSourceOptions, ", ")))
+	flags.BoolVar(&options.Dedupe, "dedupe", false, "true to collapse tasks with identical text into one, noting where and when they were seen (default=false)")
+	flags.Var(&options.Exclude, "exclude", "glob pattern of paths to skip, in .gitignore syntax relative to each root, may be repeated")
+	flags.Var(&options.ExcludeOutputPatterns, "exclude-output-pattern", fmt.Sprintf("file name pattern, such as weekly-*.md, of generated reports to skip wherever they're found, in addition to the files being written, may be repeated (default=%s)", tasks.DefaultOutputFilename))
+	flags.Var(&options.FilenameDateFormats, "filename-date-format", "format of the dates file names start with besides YYYY-MM-DD, written like %d-%m-%Y, YYYYMMDD, YYYY_MM_DD, or the Go layout January 2, 2006, may be repeated")
+	flags.StringVar(&options.Flavor, "flavor", tasks.FlavorMarkdown, fmt.Sprintf("kind of markdown the notes are, one of %s, where logseq reads TODO, DOING, DONE, LATER, and NOW blocks as tasks and dates journals by name, and notion reads a Notion export: page IDs are left out of file titles and page properties are read like front matter (default=%s)", strings.Join(tasks.FlavorOptions, ", "), tasks.FlavorMarkdown))
+	flags.BoolVar(&options.FollowSymlinks, "follow-symlinks", false, "true to descend into symlinked directories and read symlinked files (default=false)")
+	flags.Var(&options.ExcludeTags, "exclude-tag", "skip tasks with this #tag or @tag, may be repeated")
+	flags.BoolVar(&options.History, "history", false, "true to date completed tasks without a completion date by the commit that checked them off, from git history (default=false)")
+	flags.StringVar(&options.Horizon, "horizon", "", "expand recurring tasks into instances from today through this span ahead, such as 30d, 2w, 3m, or 1y (default=none)")
+	flags.BoolVar(&options.IncludeCodeBlocks, "include-code-blocks", false, "true to read tasks inside fenced code blocks, which are skipped otherwise (default=false)")
+	flags.StringVar(&options.IncludeExt, "include-ext", "", "comma-separated extensions of other markups to read checkbox tasks from, of adoc, asciidoc, and rst, as in adoc,rst")
+	flags.BoolVar(&options.IncludeSource, "include-source", false, "true to also read TODO and FIXME comments, or those starting with each -todo-keyword, in source files with the -source-ext extensions as incomplete tasks (default=false)")
+	flags.BoolVar(&options.IncludeTodos, "include-todos", false, fmt.Sprintf("true to also read lines like \"TODO: call bob\" as incomplete tasks tagged with the keyword, by default %s (default=false)", strings.Join(tasks.DefaultTodoKeywords, " and ")))
+	flags.BoolVar(&options.IncompleteOnly, "incomplete-only", false, "true to output only incomplete tasks, overriding -c (default=false)")
+	flags.IntVar(&options.Jobs, "jobs", runtime.NumCPU(), "number of files to parse concurrently (default=number of CPUs)")
+	flags.BoolVar(&options.Lenient, "lenient", false, "true to read near-miss checkboxes, such as - [], - [ x ], or - [X ], as tasks, which lint -fix rewrites in their canonical form (default=false)")
+	flags.IntVar(&options.MaxDepth, "max-depth", 0, "deepest level of directories to scan, where 1 is only the roots themselves (default=0, no limit)")
+	flags.StringVar(&options.MaxEstimate, "max-estimate", "", "only output tasks estimated to take at most this long, such as 30m, 2h, or 1d of 8 hours")
+	flags.StringVar(&options.MaxFileSize, "max-file-size", "", "skip markdown files larger than this, in bytes or with a KB, MB, or GB suffix, such as 5MB (default=no limit)")
+	flags.IntVar(&options.MaxFiles, "max-files", 0, "stop scanning each root after this many markdown files (default=0, no limit)")
+	flags.StringVar(&options.MaxLineLength, "max-line-length", "1MB", "skip lines longer than this, in bytes or with a KB, MB, or GB suffix, logging where they are (default=1MB)")
+	flags.StringVar(&options.MinEstimate, "min-estimate", "", "only output tasks estimated to take at least this long, such as 30m, 2h, or 1d of 8 hours")
+	flags.BoolVar(&options.NoCache, "no-cache", false, "true to re-scan every file instead of using the cache of unchanged files (default=false)")
+	flags.BoolVar(&options.Org, "org", false, "true to read tasks from org-mode .org files too: TODO and DONE headlines, with their SCHEDULED and DEADLINE dates and [#A] priorities, and checkboxes (default=false)")
+	flags.Var(&options.Plugins, "plugin", fmt.Sprintf("name of a plugin, the %s<name> program on the PATH, to read notes of other formats or write other output formats with, may be repeated", pluginPrefix))
+	flags.Var(&options.Roots, "root", fmt.Sprintf("directory to scan, may be repeated or given as arguments (default=%s)", defaultRootPath))
+	flags.StringVar(&options.Since, "since", "", "only output tasks dated on or after this YYYY-MM-DD date")
+	flags.StringVar(&options.Sort, "sort", tasks.SortDate, fmt.Sprintf("comma-separated keys to order tasks by, in turn, of %s, each optionally followed by :%s or :%s, as in priority,due:%s, where priority orders from highest to lowest unless given :%s, and ties are ordered by file and line (default=%s)", strings.Join(tasks.SortOptions, ", "), tasks.SortAscending, tasks.SortDescending, tasks.SortDescending, tasks.SortAscending, tasks.SortDate))
+	flags.Var(&options.Statuses, "status", fmt.Sprintf("only output tasks with this status, one of %s, may be repeated", strings.Join(tasks.StatusOptions, ", ")))
+	flags.StringVar(&options.SourceExt, "source-ext", strings.Join(tasks.DefaultSourceExtensions, ","), fmt.Sprintf("comma-separated extensions of the source files -include-source reads TODO comments from (default=%s)", strings.Join(tasks.DefaultSourceExtensions, ",")))
+	flags.StringVar(&options.StdinName, "stdin-name", "stdin.md", "file name to give markdown read from standard input when - is given as a root (default=stdin.md)")
+	flags.StringVar(&options.Store, "store", "", "sqlite:<file> database to record the tasks found and their status changes in on each run, dating completed tasks by when they were first seen complete (default=none)")
+	flags.BoolVar(&options.Strict, "strict", false, fmt.Sprintf("true to exit with %d, before writing anything, when any root, directory, or file can't be fully read, instead of skipping it with a warning (default=false)", exitProblems))
+	flags.Var(&options.Tags, "tag", "only output tasks with this #tag or @tag, may be repeated")
+	flags.StringVar(&options.Timezone, "timezone", "", "IANA time zone, such as Europe/Berlin, to read file creation times and today's date in (default=the system's)")
+	flags.Var(&options.TodoKeywords, "todo-keyword", "keyword read as a task with -include-todos and -include-source in place of the defaults, may be repeated")
+	flags.StringVar(&options.Until, "until", "", "only output tasks dated on or before this YYYY-MM-DD date")
+	flags.Var(&options.Where, "where", "keep only tasks in notes whose front matter sets key=value, or with an inline field such as [key:: value], may be repeated")
+}
+
+// defineRenderFlags defines the flags controlling how reports are rendered.
+func defineRenderFlags(flags *flag.FlagSet, options *Options) {
+	flags.StringVar(&options.AnchorStyle, "anchor-style", tasks.AnchorGitHub, fmt.Sprintf("how links point to the header above each task, matching the renderer the report is viewed in, one of %s (default=%s)", strings.Join(tasks.AnchorStyleOptions, ", "), tasks.AnchorGitHub))
+	flags.BoolVar(&options.Breadcrumbs, "breadcrumbs", false, "true to show the headers each task is under after it, as in Project > Sprint 12 > Backlog (default=false)")
+	flags.BoolVar(&options.OutputCompleted, "c", false, "true to output completed tasks (default=false)")
+	flags.BoolVar(&options.CollapseCompleted, "collapse-completed", false, "true to output completed tasks folded into a \"n completed\" <details> block after the open tasks of each section of markdown and html output (default=false)")
+	flags.StringVar(&options.DateFormat, "date-format", "2006-01-02", "Go time layout of the dates titling date and due sections, such as \"Monday, Jan 2 2006\" (default=2006-01-02)")
+	flags.IntVar(&options.Days, "days", 0, "only output tasks dated in the last this many days, counting today (default=0, no limit)")
+	flags.StringVar(&options.Editor, "editor", tasks.EditorVSCode, fmt.Sprintf("app -link-style editor links open tasks in, one of %s (default=%s)", strings.Join(tasks.EditorOptions, ", "), tasks.EditorVSCode))
+	flags.StringVar(&options.GroupBy, "group-by", tasks.GroupByDate, fmt.Sprintf("how to section markdown and html output, one of %s (default=%s)", strings.Join(tasks.GroupByOptions, ", "), tasks.GroupByDate))
+	flags.StringVar(&options.Layout, "layout", tasks.LayoutList, fmt.Sprintf("how markdown output writes tasks, one of %s, where table writes a table of the -table-columns in each section (default=%s)", strings.Join(tasks.LayoutOptions, ", "), tasks.LayoutList))
+	flags.IntVar(&options.Limit, "limit", 0, "only output the this many most recently dated tasks, counting top-level tasks (default=0, no limit)")
+	flags.StringVar(&options.LinkStyle, "link-style", tasks.LinkStyleMarkdown, fmt.Sprintf("how markdown output links tasks to their notes, one of %s, where editor links html output too (default=%s)", strings.Join(tasks.LinkStyleOptions, ", "), tasks.LinkStyleMarkdown))
+	flags.StringVar(&options.Order, "order", "", fmt.Sprintf("order of the sections by date, week, month, quarter, or due date, one of %s, where %s puts the newest at the top (default=%s, or %s when -sort starts with date:%s)", strings.Join(tasks.OrderOptions, ", "), tasks.SortDescending, tasks.SortAscending, tasks.SortDescending, tasks.SortDescending))
+	flags.BoolVar(&options.Progress, "progress", false, "true to show how many of each section's tasks are complete, with a progress bar, under its header, and of all tasks at the top of markdown output (default=false)")
+	flags.BoolVar(&options.Rollup, "rollup", false, "true to show the completion progress of each task's subtasks (default=false)")
+	flags.BoolVar(&options.ShowCancelled, "show-cancelled", false, "true to output cancelled tasks, marked [-], in a section of their own (default=false)")
+	flags.BoolVar(&options.ShowOmitted, "show-omitted", false, "true to end markdown and html output with a note of how many older tasks -days and -limit left out (default=false)")
+	flags.BoolVar(&options.ShowProblems, "show-problems", false, "true to end markdown and html output with a section listing the paths that couldn't be fully read (default=false)")
+	flags.IntVar(&options.StaleAfter, "stale-after", 0, "mark incomplete tasks dated more than this many days ago with ⚠️ and their age, or 0 not to (default=0)")
+	flags.StringVar(&options.TableColumns, "table-columns", strings.Join(tasks.DefaultTableColumns, ","), fmt.Sprintf("comma-separated columns of -layout table, of %s (default=%s)", strings.Join(tasks.TableColumnOptions, ", "), strings.Join(tasks.DefaultTableColumns, ",")))
+	flags.StringVar(&options.Template, "template", "", "template file to render output with instead of the built-in report, a text/template for markdown or an html/template for html")
+	flags.StringVar(&options.Undated, "undated", tasks.UndatedLast, fmt.Sprintf("where sections by date, week, month, or quarter put tasks that nothing dates, in a \"No date\" section, one of %s, where skip leaves them out of the output (default=%s)", strings.Join(tasks.UndatedOptions, ", "), tasks.UndatedLast))
+}
+
+// prepare checks the scan and render flag values and sets the roots to scan
+// from the -root flags and directory arguments.
+func (options *Options) prepare(args []string) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+	if options.DateFrom != "" && !contains(tasks.DateFromOptions, options.DateFrom) {
+		return fmt.Errorf("unknown date-from '%s'", options.DateFrom)
+	}
+	if err := tasks.CheckDateSources(options.dateSources()); err != nil {
+		return err
+	}
+	if err := tasks.CheckFilenameDateFormats(options.FilenameDateFormats); err != nil {
+		return err
+	}
+	if options.Flavor != "" && !contains(tasks.FlavorOptions, options.Flavor) {
+		return fmt.Errorf("unknown flavor '%s'", options.Flavor)
+	}
+	for _, pattern := range options.ExcludeOutputPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid exclude-output-pattern '%s': %w", pattern, err)
+		}
+	}
+	if options.IncludeExt != "" {
+		for _, extension := range strings.Split(options.IncludeExt, ",") {
+			extension = strings.TrimPrefix(strings.TrimSpace(extension), ".")
+			parser, ok := markupParsers[extension]
+			if !ok {
+				return fmt.Errorf("unknown include-ext '%s'", extension)
+			}
+			tasks.RegisterParser("."+extension, parser)
+		}
+	}
+	if options.IncludeSource {
+		for _, extension := range strings.Split(options.SourceExt, ",") {
+			extension = strings.TrimPrefix(strings.TrimSpace(extension), ".")
+			if extension == "" || tasks.IsMarkdownFile("."+extension) {
+				return fmt.Errorf("invalid source-ext '%s'", extension)
+			}
+			tasks.RegisterParser("."+extension, tasks.SourceParser{Keywords: options.TodoKeywords})
+		}
+	}
+	if options.Org {
+		tasks.RegisterParser(".org", tasks.OrgParser{})
+	}
+	if err := loadPlugins(options.Plugins); err != nil {
+		return err
+	}
+	// the zone is set for the whole program, so every date read, and today,
+	// falls on the day it does there
+	if options.Timezone != "" {
+		time.Local, _ = time.LoadLocation(options.Timezone)
+	}
+
+	options.Roots = append(options.Roots, args...)
+	if len(options.Roots) == 0 {
+		options.Roots = append(options.Roots, defaultRootPath)
+	}
+	for _, root := range options.Roots {
+		if root == stdinPath {
+			continue
+		}
+		if _, err := os.Stat(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the scan and render flag values.
+func (options Options) validate() error {
+	if options.GroupBy != "" && !contains(tasks.GroupByOptions, options.GroupBy) {
+		return fmt.Errorf("unknown group-by '%s'", options.GroupBy)
+	}
+	if options.Days < 0 || options.Limit < 0 {
+		return fmt.Errorf("-days and -limit can't be negative")
+	}
+	if options.StaleAfter < 0 {
+		return fmt.Errorf("-stale-after can't be negative")
+	}
+	if options.Order != "" && !contains(tasks.OrderOptions, options.Order) {
+		return fmt.Errorf("unknown order '%s'", options.Order)
+	}
+	if options.Undated != "" && !contains(tasks.UndatedOptions, options.Undated) {
+		return fmt.Errorf("unknown undated '%s'", options.Undated)
+	}
+	if options.AnchorStyle != "" && !contains(tasks.AnchorStyleOptions, options.AnchorStyle) {
+		return fmt.Errorf("unknown anchor-style '%s'", options.AnchorStyle)
+	}
+	if options.Layout != "" && !contains(tasks.LayoutOptions, options.Layout) {
+		return fmt.Errorf("unknown layout '%s'", options.Layout)
+	}
+	if err := tasks.CheckTableColumns(options.tableColumns()); err != nil {
+		return err
+	}
+	if options.LinkStyle != "" && !contains(tasks.LinkStyleOptions, options.LinkStyle) {
+		return fmt.Errorf("unknown link-style '%s'", options.LinkStyle)
+	}
+	if options.Store != "" && !strings.HasPrefix(options.Store, storeSchemeSQLite) {
+		return fmt.Errorf("-store '%s' must start with %s", options.Store, storeSchemeSQLite)
+	}
+	if options.Timezone != "" {
+		if _, err := time.LoadLocation(options.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone '%s'", options.Timezone)
+		}
+	}
+	if options.Editor != "" && !contains(tasks.EditorOptions, options.Editor) {
+		return fmt.Errorf("unknown editor '%s'", options.Editor)
+	}
+	if options.Chart != "" && !contains(tasks.ChartOptions, options.Chart) {
+		return fmt.Errorf("unknown chart '%s'", options.Chart)
+	}
+	if _, err := tasks.ParseSort(options.Sort); err != nil {
+		return err
+	}
+	if _, err := options.filter(); err != nil {
+		return err
+	}
+	if options.Context < 0 {
+		return fmt.Errorf("-context must not be negative")
+	}
+	if options.MaxDepth < 0 || options.MaxFiles < 0 {
+		return fmt.Errorf("-max-depth and -max-files must not be negative")
+	}
+	if _, err := parseSize(options.MaxFileSize); err != nil {
+		return err
+	}
+	if _, err := parseSize(options.MaxLineLength); err != nil {
+		return err
+	}
+	if options.Horizon != "" {
+		if _, err := tasks.ParseHorizon(options.Horizon, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collect scans the roots, returning the filtered and sorted tasks found.
+func collect(options Options) (tasks.Tasks, error) {
+	scanned, err := scan(options)
+	if err != nil {
+		return tasks.Tasks{}, err
+	}
+	return options.arrange(scanned), nil
+}
+
+// scan reads the tasks in every file under the roots, recording them in any
+// -store, and adds upcoming instances of recurring tasks through -horizon.
+// With -strict it fails, with exitProblems, when any path couldn't be read,
+// leaving it to the caller whether that ends the run.
+func scan(options Options) (tasks.Tasks, error) {
+	cache := newCache(options.parseOptions())
+	if !options.NoCache {
+		cache = loadCache(defaultCacheFilename, options.parseOptions())
+	}
+	nextCache := newCache(options.parseOptions())
+
+	start := time.Now()
+	found, problems := scanRoots(options, cache, nextCache)
+	for _, problem := range problems {
+		slog.Warn("couldn't read path", "path", problem.Path, "error", problem.Message)
+	}
+	if len(problems) > 0 {
+		slog.Warn("scanned with problems, skipping what couldn't be read", "problems", len(problems))
+		if options.Strict {
+			return tasks.Tasks{}, exitCodeError{code: exitProblems, message: fmt.Sprintf("%d paths couldn't be read, failing with -strict", len(problems))}
+		}
+	}
+	slog.Debug("scanned roots", "roots", options.Roots, "files", len(nextCache.Files), "tasks", len(tasks.Flatten(found)), "duration", time.Since(start))
+	if !options.NoCache {
+		nextCache.save(defaultCacheFilename)
+	}
+
+	scanned := tasks.Tasks{Problems: problems, Tasks: found}
+	if options.History {
+		scanned = scanned.WithHistory(options.gitHistory())
+	}
+	if options.Store != "" {
+		if err := updateStore(options.storePath(), tasks.Flatten(found), time.Now()); err != nil {
+			slog.Warn("can't update store", "store", options.Store, "error", err)
+		} else if history, err := storeHistory(options.storePath()); err == nil {
+			scanned = scanned.WithHistory(history)
+		}
+	}
+	if options.Horizon != "" {
+		until, _ := tasks.ParseHorizon(options.Horizon, time.Now())
+		scanned = scanned.ExpandRecurring(time.Now(), until)
+	}
+	return scanned, nil
+}
+
+// arrange filters and sorts the scanned tasks and sets how they're rendered.
+// The scanned tasks are left as they were, so they can be arranged again for
+// other outputs.
+func (options Options) arrange(scanned tasks.Tasks) tasks.Tasks {
+	filter, _ := options.filter()
+	aggregated := scanned.Filter(filter)
+	if options.Dedupe {
+		aggregated = aggregated.Dedupe()
+	}
+	aggregated.Sort(options.Sort)
+	aggregated.NewestFirst = options.Order == tasks.SortDescending
+	if keys, err := tasks.ParseSort(options.Sort); err == nil && options.Order == "" {
+		aggregated.NewestFirst = keys[0] == tasks.SortKey{Descending: true, Key: tasks.SortDate}
+	}
+
+	aggregated.AnchorStyle = options.AnchorStyle
+	aggregated.Breadcrumbs = options.Breadcrumbs
+	aggregated.Chart = options.Chart
+	aggregated.DateFormat = options.DateFormat
+	aggregated.Editor = options.Editor
+	aggregated.GroupBy = options.GroupBy
+	aggregated.Layout = options.Layout
+	aggregated.LinkStyle = options.LinkStyle
+	aggregated.CollapseCompleted = options.CollapseCompleted
+	aggregated.OutputCompleted = (options.OutputCompleted || options.CollapseCompleted || options.CompletedOnly || options.CompletedSince != "") && !options.IncompleteOnly
+	aggregated.Progress = options.Progress
+	aggregated.Rollup = options.Rollup
+	aggregated.ShowCancelled = options.ShowCancelled
+	if !options.ShowProblems {
+		aggregated.Problems = nil
+	}
+	aggregated.Undated = options.Undated
+	aggregated.SourceBase, _ = options.sourceBase()
+	aggregated.StaleAfter = options.StaleAfter
+	aggregated.TableColumns = options.tableColumns()
+
+	var since *time.Time
+	if options.Days > 0 {
+		now := time.Now()
+		first := time.Date(now.Year(), now.Month(), now.Day()-options.Days+1, 0, 0, 0, 0, time.UTC)
+		since = &first
+	}
+	aggregated = aggregated.Recent(since, options.Limit)
+	if !options.ShowOmitted {
+		aggregated.Omitted = 0
+	}
+	return aggregated
+}
+
+// linkBase is the path from the output file's directory to the directory task
+// paths are written relative to, when -relative-links is set.
+func (options Options) linkBase() (string, error) {
+	if !options.RelativeLinks {
+		return "", nil
+	}
+
+	base, err := options.sourceBase()
+	if err != nil {
+		return "", fmt.Errorf("-relative-links %w", err)
+	}
+	outputDir, err := filepath.Abs(filepath.Dir(options.OutputFilename))
+	if err != nil {
+		return "", err
+	}
+	relPath, err := filepath.Rel(outputDir, base)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+// sourceBase is the absolute directory task paths are written relative to.
+// With several roots that is their shared parent directory.
+func (options Options) sourceBase() (string, error) {
+	base, err := filepath.Abs(options.Roots[0])
+	if err != nil {
+		return "", err
+	}
+	if len(options.Roots) > 1 {
+		base = filepath.Dir(base)
+		for _, root := range options.Roots[1:] {
+			rootPath, err := filepath.Abs(root)
+			if err != nil {
+				return "", err
+			}
+			if filepath.Dir(rootPath) != base {
+				return "", fmt.Errorf("needs every root to be in the same directory")
+			}
+		}
+	}
+	return base, nil
+}
+
+// parseOptions builds the options for parsing each file from the scan flags.
+func (options Options) parseOptions() tasks.ParseOptions {
+	// the length was checked by validate
+	maxLineLength, _ := parseSize(options.MaxLineLength)
+	parseOptions := tasks.ParseOptions{Context: options.Context, DateFrom: options.DateFrom, DateSources: options.dateSources(), FilenameDateFormats: options.FilenameDateFormats, Flavor: options.Flavor, IncludeCodeBlocks: options.IncludeCodeBlocks, Lenient: options.Lenient, MaxLineLength: int(maxLineLength)}
+	if options.IncludeTodos {
+		parseOptions.TodoKeywords = tasks.DefaultTodoKeywords
+		if len(options.TodoKeywords) > 0 {
+			parseOptions.TodoKeywords = options.TodoKeywords
+		}
+	}
+	return parseOptions
+}
+
+// dateSources returns the -date-source sources, or none when it isn't set.
+func (options Options) dateSources() []string {
+	if options.DateSource == "" {
+		return nil
+	}
+	sources := []string{}
+	for _, source := range strings.Split(options.DateSource, ",") {
+		sources = append(sources, strings.TrimSpace(source))
+	}
+	return sources
+}
+
+// walkOptions builds the options for walking each root from the scan flags.
+func (options Options) walkOptions() tasks.WalkOptions {
+	// the size was checked by validate
+	maxFileSize, _ := parseSize(options.MaxFileSize)
+	reportPatterns := append([]string{}, options.ExcludeOutputPatterns...)
+	if len(reportPatterns) == 0 {
+		reportPatterns = []string{tasks.DefaultOutputFilename}
+	}
+	reports := append([]string{}, options.Reports...)
+	if options.OutputFilename != "" && options.OutputFilename != stdoutFilename {
+		reports = append(reports, options.OutputFilename)
+	}
+	return tasks.WalkOptions{
+		Exclude:        options.Exclude,
+		FollowSymlinks: options.FollowSymlinks,
+		MaxDepth:       options.MaxDepth,
+		MaxFiles:       options.MaxFiles,
+		MaxFileSize:    maxFileSize,
+		Reports:        reports,
+		ReportPatterns: append(reportPatterns, options.ReportPatterns...),
+	}
+}
+
+// sizeUnits are the suffixes sizes can be written with.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// parseSize reads a number of bytes written plainly or with a suffix from
+// sizeUnits, such as 5MB, returning zero for an empty size.
+func parseSize(text string) (int64, error) {
+	if text == "" {
+		return 0, nil
+	}
+	number, unit := strings.ToUpper(strings.TrimSpace(text)), int64(1)
+	for _, size := range sizeUnits {
+		if strings.HasSuffix(number, size.suffix) {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, size.suffix)), size.bytes
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size '%s', expected bytes or an amount such as 500KB or 5MB", text)
+	}
+	return int64(value * float64(unit)), nil
+}
+
+// filter builds the task filter from the filter flags.
+func (options Options) filter() (tasks.Filter, error) {
+	filter := tasks.Filter{
+		Assignees:      options.Assignees,
+		CompletedOnly:  options.CompletedOnly,
+		DatedOnly:      options.Undated == tasks.UndatedSkip,
+		ExcludeTags:    options.ExcludeTags,
+		IncompleteOnly: options.IncompleteOnly,
+		Tags:           options.Tags,
+	}
+	if options.CompletedOnly && options.IncompleteOnly {
+		return filter, fmt.Errorf("-completed-only and -incomplete-only can't be used together")
+	}
+
+	var err error
+	if filter.CompletedSince, err = parseDateFlag(options.CompletedSince); err != nil {
+		return filter, err
+	}
+	if filter.Since, err = parseDateFlag(options.Since); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseDateFlag(options.Until); err != nil {
+		return filter, err
+	}
+	if options.MaxEstimate != "" {
+		if filter.MaxEstimate, err = tasks.ParseEstimate(options.MaxEstimate); err != nil {
+			return filter, fmt.Errorf("-max-estimate: %w", err)
+		}
+	}
+	if options.MinEstimate != "" {
+		if filter.MinEstimate, err = tasks.ParseEstimate(options.MinEstimate); err != nil {
+			return filter, fmt.Errorf("-min-estimate: %w", err)
+		}
+	}
+	for _, name := range options.Statuses {
+		status, err := tasks.ParseStatus(name)
+		if err != nil {
+			return filter, err
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+	for _, where := range options.Where {
+		key, value, ok := strings.Cut(where, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return filter, fmt.Errorf("invalid -where '%s', expected key=value", where)
+		}
+		if filter.Where == nil {
+			filter.Where = map[string]string{}
+		}
+		filter.Where[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return filter, nil
+}
+
+// parseDateFlag parses a YYYY-MM-DD flag value, returning nil when empty.
+func parseDateFlag(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(yearMonthDayLayout, value)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+// extensionFormats maps output file extensions to the format written when no
+// -format is given.
+var extensionFormats = map[string]string{
+	".csv":       formatCSV,
+	".htm":       formatHTML,
+	".html":      formatHTML,
+	".ics":       formatICS,
+	".json":      formatJSON,
+	".markdown":  formatMarkdown,
+	".md":        formatMarkdown,
+	".taskpaper": formatTaskPaper,
+	".tsv":       formatTSV,
+	".txt":       formatTodoTxt,
+}
+
+// scanFlags are the flags deciding which files are read and how, which every
+// output shares since the roots are only scanned once.
+var scanFlags = []string{"config", "context", "exclude", "exclude-output-pattern", "follow-symlinks", "horizon", "include-code-blocks", "include-ext", "include-source", "include-todos", "jobs", "lenient", "no-cache", "o", "org", "plugin", "root", "source-ext", "stdin-name", "strict", "timezone", "todo-keyword", "watch"}
+
+// outputs returns the options for writing each file given with -o, or the
+// default output file if none is. Settings for one output follow its file
+// name as a query, as in OPEN.md?incomplete-only&group-by=tag, and are applied
+// over the shared flags with the flag set, which options must be bound to.
+func (options *Options) outputs(flags *flag.FlagSet) ([]Options, error) {
+	shared := *options
+	defer func() {
+		*options = shared
+	}()
+	formatGiven := false
+	flags.Visit(func(f *flag.Flag) {
+		formatGiven = formatGiven || f.Name == "format"
+	})
+
+	specs := shared.Outputs
+	if len(specs) == 0 {
+		specs = Strings{""}
+	}
+	outputs := []Options{}
+	for _, spec := range specs {
+		*options = shared
+		filename, query, _ := strings.Cut(spec, "?")
+		settings, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("-o %s: %w", spec, err)
+		}
+		if err := applySettings(flags, settings); err != nil {
+			return nil, fmt.Errorf("-o %s: %w", spec, err)
+		}
+
+		output := *options
+		output.OutputFilename = filename
+		if _, ok := settings["format"]; !ok && !formatGiven {
+			if format, ok := extensionFormats[strings.ToLower(filepath.Ext(filename))]; ok {
+				output.Format = format
+			}
+		}
+		if err := output.checkOutput(); err != nil {
+			if spec == "" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("-o %s: %w", spec, err)
+		}
+		outputs = append(outputs, output)
+	}
+
+	// the roots are scanned once for all outputs, so each skips every file
+	// they write
+	reports, reportPatterns := []string{}, []string{}
+	for _, output := range outputs {
+		if output.OutputFilename == stdoutFilename {
+			continue
+		}
+		reports = append(reports, output.OutputFilename)
+		name := filepath.Base(output.OutputFilename)
+		if output.PerDirectory {
+			reportPatterns = append(reportPatterns, name)
+		}
+		if output.SplitByAssignee {
+			ext := filepath.Ext(name)
+			reportPatterns = append(reportPatterns, strings.TrimSuffix(name, ext)+"-*"+ext)
+		}
+	}
+	for i := range outputs {
+		outputs[i].Reports = reports
+		outputs[i].ReportPatterns = reportPatterns
+	}
+	return outputs, nil
+}
+
+// applySettings sets the flags named by an output's settings, in name order.
+// A setting with no value turns a boolean flag on, and settings for
+// repeatable flags replace the shared values rather than adding to them.
+func applySettings(flags *flag.FlagSet, settings url.Values) error {
+	names := []string{}
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := flags.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("unknown setting '%s'", name)
+		}
+		if contains(scanFlags, name) {
+			return fmt.Errorf("-%s can't be set for one output", name)
+		}
+		if list, ok := f.Value.(*Strings); ok {
+			*list = nil
+		}
+		for _, value := range settings[name] {
+			if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && boolFlag.IsBoolFlag() && value == "" {
+				value = "true"
+			}
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("-%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkOutput checks the settings of one output, filling in its default file
+// name.
+func (options *Options) checkOutput() error {
+	defaultOutputFilename, ok := defaultOutputFilenames[options.Format]
+	if pluginFilename, isPlugin := pluginFormats[options.Format]; isPlugin {
+		defaultOutputFilename, ok = pluginFilename, true
+	}
+	if !ok {
+		return fmt.Errorf("unknown format '%s'", options.Format)
+	}
+	if options.OutputFilename == "" {
+		options.OutputFilename = defaultOutputFilename
+	}
+	if !contains(modes, options.Mode) {
+		return fmt.Errorf("unknown mode '%s'", options.Mode)
+	}
+	if options.Mode == modeAppendSnapshot && options.Format != formatMarkdown {
+		return fmt.Errorf("-mode %s can only be used with %s format", modeAppendSnapshot, formatMarkdown)
+	}
+	if options.Template != "" && options.Format != formatMarkdown && options.Format != formatHTML {
+		return fmt.Errorf("-template can only be used with %s or %s format", formatMarkdown, formatHTML)
+	}
+	if !contains(tasks.ICSComponentOptions, options.ICSComponent) {
+		return fmt.Errorf("unknown ics-component '%s'", options.ICSComponent)
+	}
+	if err := tasks.CheckColumns(options.columns()); err != nil {
+		return err
+	}
+	if err := options.validate(); err != nil {
+		return err
+	}
+	if _, err := options.linkBase(); err != nil {
+		return err
+	}
+	if options.LinkStyle == tasks.LinkStyleEditor {
+		if _, err := options.sourceBase(); err != nil {
+			return fmt.Errorf("-link-style %s %w", tasks.LinkStyleEditor, err)
+		}
+	}
+	options.PerDirectory = options.PerDirectory || options.PerDirectoryOnly
+	if options.PerDirectory && options.OutputFilename == stdoutFilename {
+		return fmt.Errorf("-per-directory can't be used when writing to stdout")
+	}
+	if options.SplitByAssignee && options.OutputFilename == stdoutFilename {
+		return fmt.Errorf("-split-by-assignee can't be used when writing to stdout")
+	}
+	return nil
+}
+
+// outputFilenames returns the file each output is written to.
+func outputFilenames(outputs []Options) []string {
+	filenames := []string{}
+	for _, output := range outputs {
+		filenames = append(filenames, output.OutputFilename)
+	}
+	return filenames
+}
@@ -0,0 +1,74 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ageBuckets are the ages Aging sorts open tasks into, from the oldest.
+var ageBuckets = []struct {
+	title string
+	days  int
+}{
+	{"3 months+", 90},
+	{"1 month+", 30},
+	{"1 week+", 7},
+}
+
+// AgeBucket holds the open tasks of at least an age.
+type AgeBucket struct {
+	// MinDays is the age, in days, of the bucket's youngest tasks.
+	MinDays int    `json:"minDays"`
+	Tasks   []Task `json:"tasks"`
+	Title   string `json:"title"`
+}
+
+// AgeDays returns how many days before now the task is dated, counting
+// calendar days.
+func (task Task) AgeDays(now time.Time) int {
+	today, _ := time.Parse(yearMonthDayLayout, now.Format(yearMonthDayLayout))
+	date, _ := time.Parse(yearMonthDayLayout, task.Date.Format(yearMonthDayLayout))
+	return int(today.Sub(date).Hours() / 24)
+}
+
+// Aging sorts the dated incomplete tasks, including subtasks, that are at
+// least a week old into buckets of 1 week, 1 month, and 3 months or more,
+// oldest first within each. Cancelled tasks and empty buckets are left out.
+func (tasks Tasks) Aging(now time.Time) []AgeBucket {
+	open := []Task{}
+	for _, task := range Flatten(tasks.Tasks) {
+		if !task.Complete && !task.Cancelled() && !task.Undated() {
+			open = append(open, task)
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		return open[i].Date.Before(open[j].Date)
+	})
+
+	buckets := []AgeBucket{}
+	for _, bucket := range ageBuckets {
+		aged := AgeBucket{MinDays: bucket.days, Tasks: []Task{}, Title: bucket.title}
+		for len(open) > 0 && open[0].AgeDays(now) >= bucket.days {
+			aged.Tasks = append(aged.Tasks, open[0])
+			open = open[1:]
+		}
+		if len(aged.Tasks) > 0 {
+			buckets = append(buckets, aged)
+		}
+	}
+	return buckets
+}
+
+// staleMarker returns the warning marking the task as older than StaleAfter
+// days, as in ⚠️ 45d, or an empty string when it isn't or StaleAfter is
+// zero.
+func (tasks Tasks) staleMarker(task Task) string {
+	if tasks.StaleAfter <= 0 || task.Complete || task.Cancelled() || task.Undated() {
+		return ""
+	}
+	if age := task.AgeDays(time.Now()); age > tasks.StaleAfter {
+		return fmt.Sprintf("⚠️ %dd", age)
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package tasks
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+)
+
+const (
+	// AnchorGitHub links to headers the way GitHub renders them: lowercased,
+	// punctuation removed, spaces as hyphens, and -1, -2, ... added to repeated
+	// headers.
+	AnchorGitHub = "github"
+	// AnchorGitLab is like AnchorGitHub but also collapses runs of hyphens.
+	AnchorGitLab = "gitlab"
+	// AnchorObsidian links to the header text itself, as Obsidian does.
+	AnchorObsidian = "obsidian"
+	// AnchorNone links to the note without a header anchor.
+	AnchorNone = "none"
+)
+
+// AnchorStyleOptions lists the supported ways of linking to a task's header.
+var AnchorStyleOptions = []string{AnchorGitHub, AnchorGitLab, AnchorObsidian, AnchorNone}
+
+// taskPath is the percent-encoded path to the task's file, under LinkBase,
+// followed by the anchor of the header it was found under in the tasks'
+// anchor style, or of its line in source files. With LinkStyleEditor it is
+// the editor URI opening the task.
+func (tasks Tasks) taskPath(task Task) string {
+	if tasks.LinkStyle == LinkStyleEditor {
+		return tasks.editorURI(task)
+	}
+	filePath := escapePath(path.Join(tasks.LinkBase, task.FilePath))
+	anchor := headerAnchor(tasks.AnchorStyle, task.PreviousHeader, task.HeaderOccurrence)
+	if isSourceFile(task.FilePath) {
+		// source files have no headers, so tasks link to their lines
+		anchor = fmt.Sprintf("L%d", task.Line)
+	}
+	if anchor == "" {
+		return filePath
+	}
+	return fmt.Sprintf("%s#%s", filePath, anchor)
+}
+
+// escapePath percent-encodes each segment of the slash separated path, so
+// spaces, parentheses, and other characters don't end or break a markdown
+// link.
+func escapePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// headerAnchor returns the anchor of the header in the style, where occurrence
+// counts the earlier headers in the file with the same GitHub slug. The zero
+// style is AnchorGitHub.
+func headerAnchor(style, header string, occurrence int) string {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return ""
+	}
+
+	anchor := ""
+	switch style {
+	case AnchorNone:
+		return ""
+	case AnchorObsidian:
+		return strings.ReplaceAll(url.PathEscape(strings.TrimSpace(strings.Map(func(c rune) rune {
+			if strings.ContainsRune(wikilinkReserved, c) {
+				return -1
+			}
+			return c
+		}, header))), "%2F", "/")
+	case AnchorGitLab:
+		anchor = gitlabSlug(header)
+	default:
+		anchor = githubSlug(header)
+	}
+
+	if occurrence > 0 {
+		anchor = fmt.Sprintf("%s-%d", anchor, occurrence)
+	}
+	return anchor
+}
+
+// githubSlug lowercases the header, drops everything but letters, numbers,
+// spaces, hyphens, and underscores, then turns spaces into hyphens.
+func githubSlug(header string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c == ' ':
+			return '-'
+		case c == '-' || c == '_' || unicode.IsLetter(c) || unicode.IsNumber(c) || unicode.Is(unicode.Mn, c):
+			return unicode.ToLower(c)
+		}
+		return -1
+	}, strings.TrimSpace(header))
+}
+
+// gitlabSlug is githubSlug with runs of hyphens collapsed to one.
+func gitlabSlug(header string) string {
+	slug := githubSlug(header)
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	return slug
+}
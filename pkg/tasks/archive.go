@@ -0,0 +1,140 @@
+package tasks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Archivable returns the completed tasks, at any depth, completed before the
+// cutoff by their completion date or else their date. A task is only
+// archivable when none of its subtasks are still open, and the subtasks of an
+// archivable task are moved with it rather than returned themselves.
+func Archivable(all []Task, cutoff time.Time) []Task {
+	archivable := []Task{}
+	for _, task := range all {
+		if task.Complete && task.completionDate().Format(yearMonthDayLayout) < cutoff.Format(yearMonthDayLayout) && allClosed(task.Subtasks) {
+			archivable = append(archivable, task)
+			continue
+		}
+		archivable = append(archivable, Archivable(task.Subtasks, cutoff)...)
+	}
+	return archivable
+}
+
+// allClosed reports whether every task, at any depth, is complete or
+// cancelled.
+func allClosed(all []Task) bool {
+	for _, task := range Flatten(all) {
+		if !task.Complete && !task.Cancelled() {
+			return false
+		}
+	}
+	return true
+}
+
+// CutTasks removes the tasks on the 1-based lines of the file, checkbox or
+// Logseq tasks, each with the lines indented under it, passing save the
+// removed blocks of lines in the order given, outdented to the task's
+// indentation. The file is only rewritten once save succeeds, so tasks aren't
+// lost when they can't be kept elsewhere.
+func CutTasks(filePath string, lines []int, save func(blocks []string) error) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	fileLines := strings.SplitAfter(string(data), "\n")
+	cut := make([]bool, len(fileLines))
+	blocks := []string{}
+	for _, line := range lines {
+		if line < 1 || line > len(fileLines) {
+			return fmt.Errorf("%s:%d: no such line", filePath, line)
+		}
+		// near-miss checkboxes read with ParseOptions.Lenient are saved in
+		// their canonical form
+		fileLines[line-1], _ = NormalizeCheckbox(fileLines[line-1])
+		_, isTask := parseTask(time.Time{}, "", filePath, fileLines[line-1])
+		if !isTask && !logseqTaskPattern.MatchString(strings.TrimRight(fileLines[line-1], "\r\n")) {
+			return fmt.Errorf("%s:%d: not a task", filePath, line)
+		}
+
+		start := line - 1
+		end := blockEnd(fileLines, start)
+		indent := fileLines[start][:len(fileLines[start])-len(strings.TrimLeftFunc(fileLines[start], unicode.IsSpace))]
+		var block strings.Builder
+		for i := start; i < end; i++ {
+			cut[i] = true
+			block.WriteString(strings.TrimPrefix(fileLines[i], indent))
+		}
+		blocks = append(blocks, strings.TrimRight(block.String(), "\n")+"\n")
+	}
+
+	if err := save(blocks); err != nil {
+		return err
+	}
+
+	var kept strings.Builder
+	for i, line := range fileLines {
+		if !cut[i] {
+			kept.WriteString(line)
+		}
+	}
+	_, err = WriteFileAtomic(filePath, []byte(kept.String()))
+	return err
+}
+
+// blockEnd returns the index after the last line of the list item starting at
+// the index: the lines after it indented further, including blank lines
+// between them.
+func blockEnd(lines []string, start int) int {
+	indent := indentation(lines[start])
+	end := start + 1
+	for i := start + 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if indentation(lines[i]) <= indent {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// WriteArchive appends the archived tasks, given with the block of lines each
+// was cut from, under headers of the tasks' dates, so they keep their dates
+// when the archive is read again, with undated tasks last under a header of
+// their own. Each task is followed by a link to the note, and header, it was
+// archived from.
+func (tasks Tasks) WriteArchive(w io.Writer, blocks []string) error {
+	byDate := map[string][]int{}
+	for i, task := range tasks.Tasks {
+		date := noDateTitle
+		if !task.Undated() {
+			date = task.Date.Format(yearMonthDayLayout)
+		}
+		byDate[date] = append(byDate[date], i)
+	}
+	dates := []string{}
+	for date := range byDate {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	out := bufio.NewWriter(w)
+	for _, date := range dates {
+		fmt.Fprintf(out, "\n## %s\n\n", date)
+		for _, i := range byDate[date] {
+			task := tasks.Tasks[i]
+			first, rest, _ := strings.Cut(blocks[i], "\n")
+			fmt.Fprintf(out, "%s\n  _from [%s](%s)_\n%s", first, task.FilePath, tasks.taskPath(task), rest)
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,16 @@
+package tasks
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileBirthTime returns the file's birth time, or the zero time when it
+// isn't recorded.
+func fileBirthTime(filePath string, file fs.FileInfo) time.Time {
+	if stat, ok := file.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Birthtimespec.Sec, stat.Birthtimespec.Nsec)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,19 @@
+package tasks
+
+import (
+	"io/fs"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileBirthTime returns the file's birth time from statx, or the zero time
+// on kernels or filesystems that don't record it.
+func fileBirthTime(filePath string, file fs.FileInfo) time.Time {
+	var stat unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, filePath, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &stat)
+	if err != nil || stat.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}
+	}
+	return time.Unix(stat.Btime.Sec, int64(stat.Btime.Nsec))
+}
@@ -0,0 +1,14 @@
+//go:build !darwin && !linux && !windows
+
+package tasks
+
+import (
+	"io/fs"
+	"time"
+)
+
+// fileBirthTime returns the zero time on platforms without a supported way
+// to read when a file was created.
+func fileBirthTime(filePath string, file fs.FileInfo) time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,16 @@
+package tasks
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// fileBirthTime returns the file's creation time, which Windows reports
+// through GetFileAttributesEx, or the zero time when it isn't available.
+func fileBirthTime(filePath string, file fs.FileInfo) time.Time {
+	if data, ok := file.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, data.CreationTime.Nanoseconds())
+	}
+	return time.Time{}
+}
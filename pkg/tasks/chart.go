@@ -0,0 +1,82 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const ChartBurndown = "burndown"
+
+// ChartOptions are the charts that can be added to the top of markdown
+// reports.
+var ChartOptions = []string{ChartBurndown}
+
+// BurndownPoint is the number of open and completed tasks as of a date.
+type BurndownPoint struct {
+	Date      string `json:"date"`
+	Completed int    `json:"completed"`
+	Open      int    `json:"open"`
+}
+
+// Burndown returns the open and completed task counts, including subtasks,
+// at each date a task was created or completed, in date order.
+func (tasks Tasks) Burndown() []BurndownPoint {
+	created := map[string]int{}
+	completed := map[string]int{}
+	for _, task := range Flatten(tasks.Tasks) {
+		if task.Cancelled() {
+			continue
+		}
+		if !task.Undated() {
+			created[task.Date.Format(yearMonthDayLayout)]++
+		}
+		if task.Complete {
+			completed[task.completionDate().Format(yearMonthDayLayout)]++
+		}
+	}
+
+	dates := []string{}
+	for date := range created {
+		dates = append(dates, date)
+	}
+	for date := range completed {
+		if _, ok := created[date]; !ok {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+
+	points := []BurndownPoint{}
+	totalCreated, totalCompleted := 0, 0
+	for _, date := range dates {
+		totalCreated += created[date]
+		totalCompleted += completed[date]
+		points = append(points, BurndownPoint{Date: date, Completed: totalCompleted, Open: totalCreated - totalCompleted})
+	}
+	return points
+}
+
+// writeBurndown writes the burndown as a mermaid line chart, which GitHub and
+// Obsidian render in markdown previews.
+func (tasks Tasks) writeBurndown(out *strings.Builder) {
+	points := tasks.Burndown()
+	if len(points) == 0 {
+		return
+	}
+
+	dates, open, completed := []string{}, []string{}, []string{}
+	for _, point := range points {
+		dates = append(dates, fmt.Sprintf("%q", point.Date))
+		open = append(open, fmt.Sprint(point.Open))
+		completed = append(completed, fmt.Sprint(point.Completed))
+	}
+
+	out.WriteString("```mermaid\nxychart-beta\n")
+	out.WriteString("    title \"Open and completed tasks\"\n")
+	out.WriteString(fmt.Sprintf("    x-axis [%s]\n", strings.Join(dates, ", ")))
+	out.WriteString("    y-axis \"Tasks\"\n")
+	out.WriteString(fmt.Sprintf("    line [%s]\n", strings.Join(open, ", ")))
+	out.WriteString(fmt.Sprintf("    line [%s]\n", strings.Join(completed, ", ")))
+	out.WriteString("```\n\n")
+}
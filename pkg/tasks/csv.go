@@ -0,0 +1,103 @@
+package tasks
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// CSVColumns are the columns that can be selected for CSV output, in their
+// default order.
+var CSVColumns = []string{"date", "due", "complete", "completed_at", "text", "file", "header", "line", "id", "priority", "status", "tags"}
+
+// CheckColumns returns an error naming the first column that isn't one of
+// CSVColumns.
+func CheckColumns(columns []string) error {
+	for _, column := range columns {
+		if csvValue(Task{}, column) == nil {
+			return fmt.Errorf("unknown column '%s'", column)
+		}
+	}
+	return nil
+}
+
+// WriteCSV renders the tasks, including subtasks, as rows of the selected
+// columns under a header row, separated by comma or another separator such
+// as tab.
+func (tasks Tasks) WriteCSV(w io.Writer, columns []string, separator rune) error {
+	if err := CheckColumns(columns); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Comma = separator
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	var writeRows func(all []Task) error
+	writeRows = func(all []Task) error {
+		for _, task := range all {
+			row := []string{}
+			for _, column := range columns {
+				row = append(row, *csvValue(task, column))
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+			if err := writeRows(task.Subtasks); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := writeRows(tasks.Visible()); err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvValue returns the task's value for the column, or nil for an unknown
+// column.
+func csvValue(task Task, column string) *string {
+	var value string
+	switch column {
+	case "complete":
+		value = strconv.FormatBool(task.Complete)
+	case "completed_at":
+		if task.CompletedAt != nil {
+			value = task.CompletedAt.Format(yearMonthDayLayout)
+		}
+	case "date":
+		if !task.Undated() {
+			value = task.Date.Format(yearMonthDayLayout)
+		}
+	case "due":
+		if task.Due != nil {
+			value = task.Due.Format(yearMonthDayLayout)
+		}
+	case "file":
+		value = task.FilePath
+	case "header":
+		value = task.PreviousHeader
+	case "id":
+		value = task.ID
+	case "line":
+		value = strconv.Itoa(task.Line)
+	case "priority":
+		value = task.Priority.String()
+	case "status":
+		value = task.Status.String()
+	case "tags":
+		value = strings.Join(task.Tags, " ")
+	case "text":
+		value = task.Text
+	default:
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,295 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// dateFormat is a way of writing dates that headers and file names are read
+// in. The pattern captures the date, which is parsed with the first layout
+// that fits, or as an ISO week such as 2024-W07 when there are no layouts.
+type dateFormat struct {
+	pattern *regexp.Regexp
+	layouts []string
+}
+
+var (
+	// headerDateFormats are the dates a header can start with to date the
+	// tasks under it: 2024-03-05, 2024-W10 for the Monday of that week,
+	// 05/03/2024 with the day first, and Mar 5, 2024 or March 5, 2024.
+	headerDateFormats = []dateFormat{
+		{pattern: regexp.MustCompile(`^` + headerMarks + `(\d{4}-\d{2}-\d{2})`), layouts: []string{yearMonthDayLayout}},
+		{pattern: regexp.MustCompile(`^` + headerMarks + `(\d{4}-W\d{2})\b`)},
+		{pattern: regexp.MustCompile(`^` + headerMarks + `(\d{2}/\d{2}/\d{4})`), layouts: []string{"02/01/2006"}},
+		{pattern: regexp.MustCompile(`^` + headerMarks + `(\p{Lu}\p{Ll}+ \d{1,2}, \d{4})`), layouts: []string{"Jan 2, 2006", "January 2, 2006"}},
+	}
+	// fileDateFormats are the dates a file name can start with to date the
+	// file, as daily and weekly notes are named, after any of
+	// ParseOptions.FilenameDateFormats.
+	fileDateFormats = []dateFormat{
+		{pattern: regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})`), layouts: []string{yearMonthDayLayout}},
+		{pattern: regexp.MustCompile(`^(\d{4}-W\d{2})\b`)},
+	}
+)
+
+// layoutToken is a part of a file name date format standing for a part of
+// the date, with the Go layout and the pattern it stands for.
+type layoutToken struct {
+	token, layout, pattern string
+}
+
+var (
+	// strftimeTokens are the parts of formats written like %d-%m-%Y.
+	strftimeTokens = []layoutToken{
+		{"%Y", "2006", `\d{4}`}, {"%y", "06", `\d{2}`},
+		{"%B", "January", `\p{L}+`}, {"%b", "Jan", `\p{L}{3}`}, {"%m", "01", `\d{2}`}, {"%-m", "1", `\d{1,2}`},
+		{"%d", "02", `\d{2}`}, {"%-d", "2", `\d{1,2}`},
+	}
+	// wordTokens are the parts of formats written like YYYY_MM_DD.
+	wordTokens = []layoutToken{
+		{"YYYY", "2006", `\d{4}`}, {"YY", "06", `\d{2}`},
+		{"MMMM", "January", `\p{L}+`}, {"MMM", "Jan", `\p{L}{3}`}, {"MM", "01", `\d{2}`}, {"M", "1", `\d{1,2}`},
+		{"DD", "02", `\d{2}`}, {"D", "2", `\d{1,2}`},
+	}
+	// goLayoutTokens are the parts of formats written as Go layouts, like
+	// January 2, 2006.
+	goLayoutTokens = []layoutToken{
+		{"2006", "2006", `\d{4}`}, {"January", "January", `\p{L}+`}, {"Jan", "Jan", `\p{L}{3}`},
+		{"01", "01", `\d{2}`}, {"02", "02", `\d{2}`}, {"06", "06", `\d{2}`}, {"1", "1", `\d{1,2}`}, {"2", "2", `\d{1,2}`},
+	}
+)
+
+// parsedFilenameFormat is a file name date format as parseFilenameDateFormat
+// read it, or the error it failed with.
+type parsedFilenameFormat struct {
+	format dateFormat
+	err    error
+}
+
+var (
+	// filenameFormatsMutex guards filenameFormats, which holds the file name
+	// date formats read so far by their spec, so the formats are only read
+	// once rather than for every file, by concurrent scans.
+	filenameFormatsMutex sync.RWMutex
+	filenameFormats      = map[string]parsedFilenameFormat{}
+)
+
+// filenameDateFormat returns the format file names can start with, as
+// parseFilenameDateFormat reads it, reading each spec only once.
+func filenameDateFormat(spec string) (dateFormat, error) {
+	filenameFormatsMutex.RLock()
+	parsed, ok := filenameFormats[spec]
+	filenameFormatsMutex.RUnlock()
+	if !ok {
+		parsed.format, parsed.err = parseFilenameDateFormat(spec)
+		filenameFormatsMutex.Lock()
+		filenameFormats[spec] = parsed
+		filenameFormatsMutex.Unlock()
+	}
+	return parsed.format, parsed.err
+}
+
+// parseFilenameDateFormat reads a format file names can start with, written
+// with % directives as in %d-%m-%Y, with letters as in YYYYMMDD or
+// YYYY_MM_DD, or as a Go layout as in January 2, 2006. Anything else in the
+// format is matched as it is.
+func parseFilenameDateFormat(spec string) (dateFormat, error) {
+	tokens := goLayoutTokens
+	if strings.Contains(spec, "%") {
+		tokens = strftimeTokens
+	} else if strings.Contains(spec, "YY") {
+		tokens = wordTokens
+	}
+
+	layout, pattern := "", ""
+	hasYear := false
+	// letters that are part of a word, as in Daily, are matched as they are
+	inWord := false
+	for rest := spec; rest != ""; {
+		matched := false
+		for _, token := range tokens {
+			if !strings.HasPrefix(rest, token.token) {
+				continue
+			}
+			next, _ := utf8.DecodeRuneInString(rest[len(token.token):])
+			if unicode.IsLetter(rune(token.token[0])) && (inWord || unicode.IsLower(next)) {
+				break
+			}
+			layout += token.layout
+			pattern += token.pattern
+			hasYear = hasYear || token.layout == "2006" || token.layout == "06"
+			rest = rest[len(token.token):]
+			inWord = false
+			matched = true
+			break
+		}
+		if !matched {
+			r, size := utf8.DecodeRuneInString(rest)
+			inWord = unicode.IsLetter(r)
+			layout += string(r)
+			pattern += regexp.QuoteMeta(string(r))
+			rest = rest[size:]
+		}
+	}
+	if !hasYear {
+		return dateFormat{}, fmt.Errorf("file name date format '%s' has no year", spec)
+	}
+	return dateFormat{pattern: regexp.MustCompile(`(?i)^(` + pattern + `)`), layouts: []string{layout}}, nil
+}
+
+// CheckFilenameDateFormats returns an error naming the first of the formats
+// that can't be read.
+func CheckFilenameDateFormats(specs []string) error {
+	for _, spec := range specs {
+		if _, err := filenameDateFormat(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileNameDate returns the date the file name starts with, in one of the
+// FilenameDateFormats or else the default formats, or nil when it doesn't
+// start with one.
+func (options ParseOptions) fileNameDate(name string) *time.Time {
+	for _, spec := range options.FilenameDateFormats {
+		if format, err := filenameDateFormat(spec); err == nil {
+			if date := parseDateFormats([]dateFormat{format}, name, nil); date != nil {
+				return date
+			}
+		}
+	}
+	return parseDateFormats(fileDateFormats, name, nil)
+}
+
+// parseDateFormats returns the date the text starts with in the first of the
+// formats it matches, or lastDate if it doesn't start with one.
+func parseDateFormats(formats []dateFormat, text string, lastDate *time.Time) *time.Time {
+	for _, format := range formats {
+		match := format.pattern.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		if len(format.layouts) == 0 {
+			if date, ok := parseISOWeek(match[1]); ok {
+				return &date
+			}
+		}
+		for _, layout := range format.layouts {
+			if date, err := time.Parse(layout, match[1]); err == nil {
+				return &date
+			}
+		}
+	}
+	return lastDate
+}
+
+// parseISOWeek returns the Monday of an ISO week written as 2024-W07. Week 1
+// is the week holding the year's first Thursday.
+func parseISOWeek(text string) (time.Time, bool) {
+	yearText, weekText, ok := strings.Cut(text, "-W")
+	year, yearErr := strconv.Atoi(yearText)
+	week, weekErr := strconv.Atoi(weekText)
+	if !ok || yearErr != nil || weekErr != nil || week < 1 || week > 53 {
+		return time.Time{}, false
+	}
+
+	// January 4th is always in week 1
+	january4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := january4.AddDate(0, 0, -((int(january4.Weekday()) + 6) % 7))
+	date := monday.AddDate(0, 0, (week-1)*7)
+	if _, dateWeek := date.ISOWeek(); dateWeek != week {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+const (
+	// DateSourceBirthtime dates tasks by when their file was created, where
+	// the platform and filesystem record it.
+	DateSourceBirthtime = "birthtime"
+	// DateSourceFilename dates tasks by the date their file's name, or Logseq
+	// journal's name, begins with.
+	DateSourceFilename = "filename"
+	// DateSourceFrontMatter dates tasks by the date: or created: field of
+	// their note's front matter, or its Notion properties.
+	DateSourceFrontMatter = "frontmatter"
+	// DateSourceGit dates tasks by when the commit adding their line was
+	// written, from git blame.
+	DateSourceGit = "git"
+	// DateSourceHeader dates tasks by the nearest header above them starting
+	// with a date.
+	DateSourceHeader = "header"
+	// DateSourceMtime dates tasks by when their file was last modified.
+	DateSourceMtime = "mtime"
+)
+
+// DateSourceOptions lists the sources ParseOptions.DateSources can date tasks
+// by.
+var DateSourceOptions = []string{DateSourceHeader, DateSourceFilename, DateSourceFrontMatter, DateSourceGit, DateSourceBirthtime, DateSourceMtime}
+
+// CheckDateSources returns an error naming the first source that isn't one of
+// DateSourceOptions.
+func CheckDateSources(sources []string) error {
+	for _, source := range sources {
+		if !contains(DateSourceOptions, source) {
+			return fmt.Errorf("unknown date source '%s'", source)
+		}
+	}
+	return nil
+}
+
+// dateCandidates are the dates each source gives a task, nil where it gives
+// none.
+type dateCandidates struct {
+	birthTime, fileName, frontMatter, git, header, modTime *time.Time
+}
+
+// fileDateCandidates returns the dates the file itself gives its tasks.
+func fileDateCandidates(meta FileMeta, options ParseOptions) dateCandidates {
+	candidates := dateCandidates{fileName: options.fileNameDate(meta.Name)}
+	if journalDate := logseqJournalDate(meta.Name); options.Flavor == FlavorLogseq && journalDate != nil {
+		candidates.fileName = journalDate
+	}
+	if !meta.BirthTime.IsZero() {
+		birthTime := meta.BirthTime.In(time.Local)
+		candidates.birthTime = &birthTime
+	}
+	if !meta.ModTime.IsZero() {
+		modTime := meta.ModTime.In(time.Local)
+		candidates.modTime = &modTime
+	}
+	return candidates
+}
+
+// first returns the date of the first of the sources giving one, or the zero
+// time when none does.
+func (candidates dateCandidates) first(sources []string) time.Time {
+	for _, source := range sources {
+		var date *time.Time
+		switch source {
+		case DateSourceBirthtime:
+			date = candidates.birthTime
+		case DateSourceFilename:
+			date = candidates.fileName
+		case DateSourceFrontMatter:
+			date = candidates.frontMatter
+		case DateSourceGit:
+			date = candidates.git
+		case DateSourceHeader:
+			date = candidates.header
+		case DateSourceMtime:
+			date = candidates.modTime
+		}
+		if date != nil {
+			return *date
+		}
+	}
+	return time.Time{}
+}
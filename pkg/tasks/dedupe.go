@@ -0,0 +1,60 @@
+package tasks
+
+import "strings"
+
+// Dedupe collapses top-level tasks with identical text, such as unfinished
+// tasks carried forward from one daily note to the next, into the most
+// recently dated copy. Collapsed tasks record the first and last dates they
+// were seen and the files they were found in.
+func (tasks Tasks) Dedupe() Tasks {
+	byText := map[string][]Task{}
+	order := []string{}
+	for _, task := range tasks.Tasks {
+		text := strings.TrimSpace(task.Text)
+		if _, seen := byText[text]; !seen {
+			order = append(order, text)
+		}
+		byText[text] = append(byText[text], task)
+	}
+
+	deduped := []Task{}
+	for _, text := range order {
+		copies := byText[text]
+		if len(copies) == 1 {
+			deduped = append(deduped, copies[0])
+			continue
+		}
+
+		latest := copies[0]
+		first := copies[0].Date
+		sources := []string{}
+		for _, task := range copies {
+			if !task.Date.Before(latest.Date) {
+				latest = task
+			}
+			if task.Date.Before(first) {
+				first = task.Date
+			}
+			if !contains(sources, task.FilePath) {
+				sources = append(sources, task.FilePath)
+			}
+		}
+		last := latest.Date
+		latest.FirstSeen = &first
+		latest.LastSeen = &last
+		latest.Sources = sources
+		deduped = append(deduped, latest)
+	}
+
+	tasks.Tasks = deduped
+	return tasks
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
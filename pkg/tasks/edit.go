@@ -0,0 +1,115 @@
+package tasks
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SetComplete checks or unchecks the task on the 1-based line of the file,
+// returning an error if there is no task on that line.
+func SetComplete(filePath string, line int, complete bool) error {
+	if complete {
+		return SetStatus(filePath, line, StatusDone)
+	}
+	return SetStatus(filePath, line, StatusOpen)
+}
+
+// SetStatus rewrites the checkbox of the task on the 1-based line of the file
+// with the status's symbol, or the keyword of a Logseq task with the status's
+// keyword, returning an error if there is no task on that line.
+func SetStatus(filePath string, line int, status Status) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if line < 1 || line > len(lines) {
+		return fmt.Errorf("%s:%d: no such line", filePath, line)
+	}
+	// near-miss checkboxes read with ParseOptions.Lenient are rewritten in
+	// their canonical form along the way
+	text, _ := NormalizeCheckbox(lines[line-1])
+	if _, isTask := parseTask(time.Time{}, "", filePath, text); isTask {
+		// the submatch is the mark between the checkbox's brackets
+		checkbox := taskPattern.FindStringSubmatchIndex(text)
+		lines[line-1] = text[:checkbox[2]] + status.Symbol() + text[checkbox[3]:]
+	} else if keyword := logseqTaskPattern.FindStringSubmatchIndex(strings.TrimRight(text, "\r\n")); keyword != nil {
+		replacement, ok := logseqKeywords[status]
+		if !ok {
+			return fmt.Errorf("%s:%d: Logseq tasks can't be %s", filePath, line, status)
+		}
+		lines[line-1] = text[:keyword[2]] + replacement + text[keyword[3]:]
+	} else {
+		return fmt.Errorf("%s:%d: not a task", filePath, line)
+	}
+
+	_, err = WriteFileAtomic(filePath, []byte(strings.Join(lines, "")))
+	return err
+}
+
+// WriteFileAtomic replaces the file's contents by writing a temporary file
+// beside it, flushing it to disk, and renaming it into place, so a crash
+// never leaves a partly written file. A file already holding the data is left
+// untouched, keeping its modification time, and false is returned. Files that
+// aren't regular, such as devices and named pipes, are written to directly.
+func WriteFileAtomic(filename string, data []byte) (bool, error) {
+	// a symlinked file is kept a symlink by replacing its target
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = target
+	}
+	mode := fs.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		// devices and pipes, such as /dev/null, are written through rather
+		// than replaced
+		if !info.Mode().IsRegular() {
+			return true, writeThrough(filename, data)
+		}
+		mode = info.Mode().Perm()
+	}
+	if existing, err := os.ReadFile(filename); err == nil && bytes.Equal(existing, data) {
+		return false, nil
+	}
+
+	// the temporary name isn't a markdown file, so scans and -watch ignore it
+	temp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return false, err
+	}
+	if err := temp.Chmod(mode); err != nil {
+		temp.Close()
+		return false, err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return false, err
+	}
+	if err := temp.Close(); err != nil {
+		return false, err
+	}
+	return true, os.Rename(temp.Name(), filename)
+}
+
+// writeThrough writes the data to the existing file without truncating or
+// replacing it, as for a device or named pipe.
+func writeThrough(filename string, data []byte) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
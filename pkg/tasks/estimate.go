@@ -0,0 +1,82 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EstimateDay is how long a day of estimated effort is, as in est: 1d.
+const EstimateDay = 8 * time.Hour
+
+var (
+	// estimatePattern matches effort estimates written as ⏱ 2h, est: 30m, or
+	// [estimate:: 1d], capturing the amount.
+	estimatePattern = regexp.MustCompile(`(?i)(?:⏱\x{FE0F}?\s*|\best:\s*|\[estimate::\s*)(\d+(?:\.\d+)?\s*[dhm](?:\s*\d+(?:\.\d+)?\s*[dhm])*)\b`)
+	// estimatePartPattern matches each number and unit of an estimate.
+	estimatePartPattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*([dhm])`)
+)
+
+// estimateUnits are the lengths of the units estimates are written in.
+var estimateUnits = map[string]time.Duration{
+	"d": EstimateDay,
+	"h": time.Hour,
+	"m": time.Minute,
+}
+
+func parseEstimate(text string) time.Duration {
+	match := estimatePattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0
+	}
+	estimate, _ := ParseEstimate(match[1])
+	return estimate
+}
+
+// ParseEstimate reads an amount of effort written in days of EstimateDay,
+// hours, and minutes, such as 1d, 2.5h, or 1h30m.
+func ParseEstimate(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+	parts := estimatePartPattern.FindAllStringSubmatch(text, -1)
+	if len(parts) == 0 || strings.TrimSpace(estimatePartPattern.ReplaceAllString(text, "")) != "" {
+		return 0, fmt.Errorf("invalid estimate '%s', expected an amount such as 30m, 2h, 1d, or 1h30m", text)
+	}
+
+	var estimate time.Duration
+	for _, part := range parts {
+		amount, err := strconv.ParseFloat(part[1], 64)
+		if err != nil {
+			return 0, err
+		}
+		estimate += time.Duration(amount * float64(estimateUnits[strings.ToLower(part[2])]))
+	}
+	return estimate, nil
+}
+
+// FormatEstimate writes the effort in hours and minutes, such as 3h30m.
+func FormatEstimate(estimate time.Duration) string {
+	estimate = estimate.Round(time.Minute)
+	hours := estimate / time.Hour
+	minutes := estimate % time.Hour / time.Minute
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
+
+// remainingEstimate sums the estimates of the open tasks, including their
+// subtasks.
+func remainingEstimate(all []Task) time.Duration {
+	var estimate time.Duration
+	for _, task := range Flatten(all) {
+		if !task.Complete && !task.Cancelled() {
+			estimate += task.Estimate
+		}
+	}
+	return estimate
+}
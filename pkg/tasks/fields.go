@@ -0,0 +1,38 @@
+package tasks
+
+import (
+	"regexp"
+	"strings"
+)
+
+// fieldPattern matches the Dataview inline fields written on a task, as in
+// [owner:: bob] or (owner:: bob), capturing the key and the value.
+var fieldPattern = regexp.MustCompile(`[\[(]([\p{L}\p{N}_][\p{L}\p{N}_ -]*)::[\s\p{Zs}]*([^\])]*?)[\s\p{Zs}]*[\])]`)
+
+// parseFields returns the Dataview inline fields of the task text by key,
+// as written, or nil when there are none. A key written twice keeps its
+// first value.
+func parseFields(text string) map[string]string {
+	var fields map[string]string
+	for _, match := range fieldPattern.FindAllStringSubmatch(text, -1) {
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		key := strings.TrimSpace(match[1])
+		if _, ok := fields[key]; !ok {
+			fields[key] = match[2]
+		}
+	}
+	return fields
+}
+
+// HasField reports whether the task has an inline field of the key, ignoring
+// case, with the value, ignoring case.
+func (task Task) HasField(key, value string) bool {
+	for field, text := range task.Fields {
+		if strings.EqualFold(field, key) && strings.EqualFold(text, value) {
+			return true
+		}
+	}
+	return false
+}
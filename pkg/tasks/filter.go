@@ -0,0 +1,192 @@
+package tasks
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Filter selects tasks by completion and date. The zero value matches every
+// task.
+type Filter struct {
+	// Assignees keeps only tasks assigned to at least one of the people.
+	Assignees []string
+	// CompletedSince keeps only completed tasks finished on or after the date,
+	// using the task's date when it has no completion date.
+	CompletedSince *time.Time
+	CompletedOnly  bool
+	// DueBy keeps only tasks due on or before the date.
+	DueBy *time.Time
+	// ExcludeTags drops tasks having any of the tags.
+	ExcludeTags    []string
+	IncompleteOnly bool
+	// MaxEstimate and MinEstimate, when not zero, keep only tasks estimated
+	// to take at most or at least as long. Tasks without an estimate are
+	// dropped.
+	MaxEstimate time.Duration
+	MinEstimate time.Duration
+	// DatedOnly leaves out undated tasks.
+	DatedOnly bool
+	// Since and Until bound task dates, inclusive of the whole Until day.
+	// Undated tasks are outside any bound.
+	Since *time.Time
+	// Statuses keeps only tasks having one of the statuses.
+	Statuses []Status
+	// Tags keeps only tasks having at least one of the tags.
+	Tags  []string
+	Until *time.Time
+	// Where keeps only tasks whose note's front matter, or whose own inline
+	// fields, set each key to its value.
+	Where map[string]string
+}
+
+// Match reports whether the task itself, ignoring its subtasks, passes the
+// filter.
+func (filter Filter) Match(task Task) bool {
+	if filter.CompletedOnly && !task.Complete {
+		return false
+	}
+	if filter.IncompleteOnly && task.Complete {
+		return false
+	}
+	// dates are compared by day, since file dates are times in the local time
+	// zone while dates read from text are midnight UTC
+	if filter.CompletedSince != nil && (!task.Complete || task.completionDate().Format(yearMonthDayLayout) < filter.CompletedSince.Format(yearMonthDayLayout)) {
+		return false
+	}
+	if filter.DueBy != nil && (task.Due == nil || task.Due.Format(yearMonthDayLayout) > filter.DueBy.Format(yearMonthDayLayout)) {
+		return false
+	}
+	if (filter.DatedOnly || filter.Since != nil || filter.Until != nil) && task.Undated() {
+		return false
+	}
+	if filter.Since != nil && task.Date.Format(yearMonthDayLayout) < filter.Since.Format(yearMonthDayLayout) {
+		return false
+	}
+	if filter.Until != nil && task.Date.Format(yearMonthDayLayout) > filter.Until.Format(yearMonthDayLayout) {
+		return false
+	}
+	if filter.MaxEstimate > 0 && (task.Estimate == 0 || task.Estimate > filter.MaxEstimate) {
+		return false
+	}
+	if filter.MinEstimate > 0 && task.Estimate < filter.MinEstimate {
+		return false
+	}
+	if len(filter.Statuses) > 0 && !hasStatus(filter.Statuses, task.Status) {
+		return false
+	}
+	if len(filter.Assignees) > 0 && !task.HasAnyAssignee(filter.Assignees) {
+		return false
+	}
+	if len(filter.Tags) > 0 && !task.HasAnyTag(filter.Tags) {
+		return false
+	}
+	if task.HasAnyTag(filter.ExcludeTags) {
+		return false
+	}
+	for key, value := range filter.Where {
+		if !task.HasProperty(key, value) && !task.HasField(key, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAnyTag reports whether the task has any of the tags, ignoring case. Tags
+// given without a leading # or @ match either.
+func (task Task) HasAnyTag(tags []string) bool {
+	for _, want := range tags {
+		for _, tag := range task.Tags {
+			if strings.EqualFold(tag, want) || strings.EqualFold(tag[1:], want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasAnyAssignee reports whether the task is assigned to any of the people,
+// named with or without their @.
+func (task Task) HasAnyAssignee(assignees []string) bool {
+	for _, want := range assignees {
+		for _, assignee := range task.Assignees {
+			if strings.EqualFold(assignee, strings.TrimPrefix(want, "@")) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasStatus(statuses []Status, status Status) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the tasks passing the filter. Tasks that don't match are
+// still kept when one of their subtasks does, so matches keep their context.
+func (tasks Tasks) Filter(filter Filter) Tasks {
+	tasks.Tasks = filterTasks(tasks.Tasks, filter)
+	return tasks
+}
+
+func filterTasks(all []Task, filter Filter) []Task {
+	filtered := []Task{}
+	for _, task := range all {
+		task.Subtasks = filterTasks(task.Subtasks, filter)
+		if filter.Match(task) || len(task.Subtasks) > 0 {
+			filtered = append(filtered, task)
+		}
+	}
+	return filtered
+}
+
+// Recent keeps the tasks to output dated on or after since, unless it's nil,
+// and of those the limit most recent, unless it's 0, in their order. Undated
+// tasks count as older than any other. Tasks that aren't output are kept
+// as they are, so progress still counts them. Omitted is increased by the
+// number of top-level tasks to output left out.
+func (tasks Tasks) Recent(since *time.Time, limit int) Tasks {
+	if since == nil && limit <= 0 {
+		return tasks
+	}
+	kept := []Task{}
+	// shown holds the indexes in kept of the tasks to output
+	shown := []int{}
+	for _, task := range tasks.Tasks {
+		if tasks.hidden(task) {
+			kept = append(kept, task)
+			continue
+		}
+		if since != nil && (task.Undated() || task.Date.Format(yearMonthDayLayout) < since.Format(yearMonthDayLayout)) {
+			tasks.Omitted++
+			continue
+		}
+		shown = append(shown, len(kept))
+		kept = append(kept, task)
+	}
+
+	if limit > 0 && len(shown) > limit {
+		sort.SliceStable(shown, func(i, j int) bool {
+			return compareTasks(kept[shown[i]], kept[shown[j]], SortKey{Descending: true, Key: SortDate}) < 0
+		})
+		cut := make([]bool, len(kept))
+		for _, i := range shown[limit:] {
+			cut[i] = true
+		}
+		limited := []Task{}
+		for i, task := range kept {
+			if !cut[i] {
+				limited = append(limited, task)
+			}
+		}
+		tasks.Omitted += len(shown) - limit
+		kept = limited
+	}
+	tasks.Tasks = kept
+	return tasks
+}
@@ -0,0 +1,17 @@
+package tasks
+
+const (
+	// FlavorMarkdown reads notes as plain markdown.
+	FlavorMarkdown = "markdown"
+	// FlavorLogseq reads notes as a Logseq graph: blocks starting with a
+	// keyword such as TODO or DONE are tasks, and journals named like
+	// 2024_03_01.md are dated by their names.
+	FlavorLogseq = "logseq"
+	// FlavorNotion reads notes as exported from Notion: page names end with
+	// an ID that is left out of titles, and the "Key: Value" lines under a
+	// page's title are its properties, which can date it.
+	FlavorNotion = "notion"
+)
+
+// FlavorOptions lists the kinds of markdown notes can be read as.
+var FlavorOptions = []string{FlavorMarkdown, FlavorLogseq, FlavorNotion}
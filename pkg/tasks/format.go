@@ -0,0 +1,168 @@
+package tasks
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DateStyleDataview writes dates as Dataview fields, as in
+	// [due:: 2024-03-01].
+	DateStyleDataview = "dataview"
+	// DateStyleEmoji writes dates after the Tasks plugin's emoji, as in
+	// 📅 2024-03-01.
+	DateStyleEmoji = "emoji"
+	// DateStyleText writes dates after a word, as in due: 2024-03-01.
+	DateStyleText = "text"
+)
+
+// DateStyleOptions are the ways FormatLine can write the due, start, and
+// completion dates of tasks.
+var DateStyleOptions = []string{DateStyleDataview, DateStyleEmoji, DateStyleText}
+
+// BulletOptions are the list markers FormatLine can write tasks with.
+var BulletOptions = []string{"-", "*", "+"}
+
+// dateAnnotations are the dates written on tasks, with how each is written in
+// every date style.
+var dateAnnotations = []struct {
+	pattern               *regexp.Regexp
+	dataview, emoji, text string
+}{
+	{completedPattern, "completion", "✅", "done:"},
+	{duePattern, "due", "📅", "due:"},
+	{startPattern, "start", "🛫", "start:"},
+}
+
+// FormatStyle is how FormatLine writes task lines. Zero values keep what is
+// written.
+type FormatStyle struct {
+	// Bullet is the list marker tasks are written with, one of BulletOptions.
+	Bullet string
+	// Dates is how due, start, and completion dates are written, one of
+	// DateStyleOptions.
+	Dates string
+	// TagsLast moves the tags of each task to the end of its line.
+	TagsLast bool
+}
+
+// Check returns an error when the bullet or date style isn't one of the
+// options.
+func (style FormatStyle) Check() error {
+	if style.Bullet != "" && !contains(BulletOptions, style.Bullet) {
+		return fmt.Errorf("unknown bullet '%s', want one of %s", style.Bullet, strings.Join(BulletOptions, " "))
+	}
+	if style.Dates != "" && !contains(DateStyleOptions, style.Dates) {
+		return fmt.Errorf("unknown date style '%s'", style.Dates)
+	}
+	return nil
+}
+
+// FormatLine writes the task on the line in its canonical form: near-miss
+// checkboxes are rewritten as NormalizeCheckbox does, checked boxes are
+// marked with a lowercase x, the text follows the checkbox after one space
+// without trailing space, and the bullet, dates, and tags are written as the
+// style says. Lines that aren't tasks are returned as they are.
+func FormatLine(line string, style FormatStyle) string {
+	line, _ = NormalizeCheckbox(line)
+	match := taskPattern.FindStringSubmatchIndex(line)
+	if match == nil {
+		return line
+	}
+	prefix := line[:match[1]]
+	if line[match[2]:match[3]] == "X" {
+		prefix = line[:match[2]] + "x" + line[match[3]:match[1]]
+	}
+	if box := checkboxPattern.FindStringSubmatchIndex(prefix); box != nil && style.Bullet != "" {
+		prefix = prefix[:box[2]] + style.Bullet + " " + prefix[box[5]:]
+	}
+
+	text := strings.TrimSpace(line[match[1]:])
+	if style.Dates != "" {
+		text = formatDates(text, style.Dates)
+	}
+	if style.TagsLast {
+		text = moveTagsLast(text)
+	}
+	if text == "" {
+		return prefix
+	}
+	return prefix + " " + text
+}
+
+// formatDates rewrites the due, start, and completion dates of the task text
+// in the date style.
+func formatDates(text, style string) string {
+	for _, annotation := range dateAnnotations {
+		match := annotation.pattern.FindStringSubmatchIndex(text)
+		if match == nil {
+			continue
+		}
+		start, end := match[0], match[1]
+		// a Dataview field ends with a bracket after the date
+		if text[start] == '[' {
+			if rest := strings.TrimLeft(text[end:], " "); strings.HasPrefix(rest, "]") {
+				end = len(text) - len(rest) + 1
+			}
+		}
+		date := text[match[2]:match[3]]
+		written := annotation.text + " " + date
+		switch style {
+		case DateStyleDataview:
+			written = "[" + annotation.dataview + ":: " + date + "]"
+		case DateStyleEmoji:
+			written = annotation.emoji + " " + date
+		}
+		text = text[:start] + written + text[end:]
+	}
+	return text
+}
+
+// moveTagsLast moves the tags of the task text to its end, in the order they
+// were written. Text that is only tags is returned as it is.
+func moveTagsLast(text string) string {
+	tags := []string{}
+	rest := ""
+	last := 0
+	for _, match := range tagPattern.FindAllStringSubmatchIndex(text, -1) {
+		tags = append(tags, text[match[2]:match[3]])
+		rest += text[last:match[0]]
+		last = match[3]
+	}
+	rest = strings.TrimSpace(rest + text[last:])
+	if len(tags) == 0 || rest == "" {
+		return text
+	}
+	return rest + " " + strings.Join(tags, " ")
+}
+
+// FormatFile formats the task lines of the file outside fenced code blocks
+// with FormatLine, reporting whether any changed. The file is rewritten only
+// when write is set.
+func FormatFile(filePath string, style FormatStyle, write bool) (bool, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	changed := false
+	fence := ""
+	for i, line := range lines {
+		text := strings.TrimRight(line, "\r\n")
+		if skipFenced(&fence, text) {
+			continue
+		}
+		if formatted := FormatLine(text, style); formatted != text {
+			lines[i] = formatted + line[len(text):]
+			changed = true
+		}
+	}
+	if !changed || !write {
+		return changed, nil
+	}
+	_, err = WriteFileAtomic(filePath, []byte(strings.Join(lines, "")))
+	return true, err
+}
@@ -0,0 +1,114 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterDelimiter opens a YAML front matter block on a note's first
+// line and closes it on a later one.
+const frontMatterDelimiter = "---"
+
+// frontMatterDateKeys are the front matter fields, in order of preference,
+// that date a note.
+var frontMatterDateKeys = []string{"date", "created"}
+
+// frontMatter is the metadata parsed from a note's YAML front matter.
+type frontMatter struct {
+	Date       *time.Time
+	Properties map[string]string
+	Title      string
+}
+
+// parseFrontMatter decodes the lines of a front matter block, reporting
+// whether they are a YAML mapping. Scalar values are kept as strings and lists
+// are joined with ", ".
+func parseFrontMatter(lines []string) (frontMatter, bool) {
+	meta := frontMatter{}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(strings.Join(lines, "\n")), &values); err != nil {
+		return meta, false
+	}
+
+	for _, key := range frontMatterDateKeys {
+		if date := frontMatterDate(values[key]); date != nil {
+			meta.Date = date
+			break
+		}
+	}
+	if title, ok := values["title"].(string); ok {
+		meta.Title = strings.TrimSpace(title)
+	}
+
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := frontMatterValue(values[key])
+		if !ok {
+			continue
+		}
+		if meta.Properties == nil {
+			meta.Properties = map[string]string{}
+		}
+		meta.Properties[key] = value
+	}
+
+	return meta, true
+}
+
+func frontMatterDate(value interface{}) *time.Time {
+	switch value := value.(type) {
+	case time.Time:
+		date := time.Date(value.Year(), value.Month(), value.Day(), 0, 0, 0, 0, time.UTC)
+		return &date
+	case string:
+		if len(value) >= len(yearMonthDayLayout) {
+			if date, err := time.Parse(yearMonthDayLayout, value[:len(yearMonthDayLayout)]); err == nil {
+				return &date
+			}
+		}
+	}
+	return nil
+}
+
+func frontMatterValue(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case nil, map[string]interface{}:
+		return "", false
+	case time.Time:
+		return value.Format(yearMonthDayLayout), true
+	case []interface{}:
+		items := []string{}
+		for _, item := range value {
+			if text, ok := frontMatterValue(item); ok {
+				items = append(items, text)
+			}
+		}
+		return strings.Join(items, ", "), true
+	default:
+		return fmt.Sprint(value), true
+	}
+}
+
+// HasProperty reports whether the front matter of the task's note sets key to
+// value, or lists value under key, ignoring case.
+func (task Task) HasProperty(key, value string) bool {
+	for property, text := range task.Properties {
+		if !strings.EqualFold(property, key) {
+			continue
+		}
+		for _, item := range strings.Split(text, ", ") {
+			if strings.EqualFold(item, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
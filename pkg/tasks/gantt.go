@@ -0,0 +1,79 @@
+package tasks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// ganttStartPattern matches the start date marker in task text, which the
+	// chart shows as the start of the task's bar instead.
+	ganttStartPattern = regexp.MustCompile(`(?i)\s*(?:\[start::\s*\d{4}-\d{2}-\d{2}\]|(?:🛫|\bstart:)\s*\d{4}-\d{2}-\d{2})`)
+	// ganttEscaper removes the characters mermaid reads as the end of a task's
+	// name or the start of a comment.
+	ganttEscaper = strings.NewReplacer(":", " ", ";", " ", "#", "")
+)
+
+// WriteGantt renders the tasks, including subtasks, that have both a start and
+// a due date as a mermaid gantt chart in a fenced code block, which GitHub and
+// Obsidian draw as a timeline. Tasks are sectioned by tag with GroupByTag and
+// otherwise by file, and ordered by start date within each section. Completed
+// tasks are marked done, tasks in progress active, and overdue tasks critical.
+// Cancelled tasks are left out.
+func (tasks Tasks) WriteGantt(w io.Writer) error {
+	scheduled := []Task{}
+	for _, task := range Flatten(tasks.Visible()) {
+		if task.Start != nil && task.Due != nil && !task.Due.Before(*task.Start) && !task.Cancelled() {
+			scheduled = append(scheduled, task)
+		}
+	}
+
+	groups := groupByFile(scheduled)
+	if tasks.GroupBy == GroupByTag {
+		groups = groupByTag(scheduled)
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprint(out, "```mermaid\ngantt\n    title Tasks\n    dateFormat YYYY-MM-DD\n")
+	for _, group := range groups {
+		sort.SliceStable(group.Tasks, func(i, j int) bool {
+			return group.Tasks[i].Start.Before(*group.Tasks[j].Start)
+		})
+		fmt.Fprintf(out, "    section %s\n", ganttName(group.Title))
+		for _, task := range group.Tasks {
+			fields := []string{}
+			switch {
+			case task.Complete:
+				fields = append(fields, "done")
+			case task.Overdue():
+				fields = append(fields, "crit")
+			}
+			if task.Status == StatusInProgress {
+				fields = append(fields, "active")
+			}
+			// mermaid's end dates are exclusive, so the bar runs through the
+			// day the task is due
+			fields = append(fields, task.Start.Format(yearMonthDayLayout), task.Due.AddDate(0, 0, 1).Format(yearMonthDayLayout))
+			text := plainText(task.Text, func(tag string) string {
+				return tag
+			})
+			fmt.Fprintf(out, "    %s :%s\n", ganttName(ganttStartPattern.ReplaceAllString(text, " ")), strings.Join(fields, ", "))
+		}
+	}
+	fmt.Fprint(out, "```\n")
+	return out.Flush()
+}
+
+// ganttName returns the text with the characters mermaid would misread
+// removed, or "Untitled" when nothing is left.
+func ganttName(text string) string {
+	name := strings.Join(strings.Fields(ganttEscaper.Replace(text)), " ")
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
@@ -0,0 +1,68 @@
+package tasks
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DateFromFile dates tasks that nothing in their note dates by when the
+	// file was created.
+	DateFromFile = "file"
+	// DateFromGit dates tasks that nothing in their note dates by when the
+	// commit adding their line was written, from git blame.
+	DateFromGit = "git"
+)
+
+// DateFromOptions lists the ways of dating tasks that no header, front
+// matter, or file name dates.
+var DateFromOptions = []string{DateFromFile, DateFromGit}
+
+// uncommittedHash is the commit git blame gives lines not yet committed.
+const uncommittedHash = "0000000000000000000000000000000000000000"
+
+// gitLineDates returns when each 1-based line of the file was first
+// committed, in the local time zone, from git blame. Lines not yet committed
+// are left out, and an error is returned when git isn't installed or the file
+// isn't in a repository.
+func gitLineDates(filePath string) (map[int]time.Time, error) {
+	command := exec.Command("git", "blame", "--line-porcelain", "--", filepath.Base(filePath))
+	command.Dir = filepath.Dir(filePath)
+	output, err := command.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// each line is described by a header giving its commit and line number,
+	// then the commit's fields, and ends with the line itself after a tab
+	dates := map[int]time.Time{}
+	line := 0
+	committed := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "\t"):
+			line = 0
+		case line == 0:
+			fields := strings.Fields(text)
+			if len(fields) < 3 {
+				continue
+			}
+			line, _ = strconv.Atoi(fields[2])
+			committed = fields[0] != uncommittedHash
+		case committed && strings.HasPrefix(text, "author-time "):
+			seconds, err := strconv.ParseInt(strings.TrimPrefix(text, "author-time "), 10, 64)
+			if err == nil {
+				dates[line] = time.Unix(seconds, 0).In(time.Local)
+			}
+		}
+	}
+	return dates, scanner.Err()
+}
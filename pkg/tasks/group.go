@@ -0,0 +1,365 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	GroupByAssignee = "assignee"
+	GroupByDate     = "date"
+	GroupByDue      = "due"
+	GroupByFile     = "file"
+	GroupByHeader   = "header"
+	GroupByMonth    = "month"
+	GroupByQuarter  = "quarter"
+	GroupByTag      = "tag"
+	GroupByWeek     = "week"
+
+	// UndatedFirst, UndatedLast, and UndatedSkip put undated tasks in a
+	// section before or after those by date, or leave them out.
+	UndatedFirst = "first"
+	UndatedLast  = "last"
+	UndatedSkip  = "skip"
+
+	noDateTitle     = "No date"
+	noDueDateTitle  = "No due date"
+	unassignedTitle = "Unassigned"
+	noHeaderTitle   = "No header"
+	untaggedTitle   = "Untagged"
+	cancelledTitle  = "Cancelled"
+)
+
+// GroupByOptions are the supported ways of sectioning the report. Weeks are
+// ISO weeks, titled like 2024-W07, months like 2024-03, and quarters like
+// 2024-Q1.
+var GroupByOptions = []string{GroupByDate, GroupByWeek, GroupByMonth, GroupByQuarter, GroupByDue, GroupByFile, GroupByHeader, GroupByTag, GroupByAssignee}
+
+// UndatedOptions are the places undated tasks can be put in sections by date.
+var UndatedOptions = []string{UndatedFirst, UndatedLast, UndatedSkip}
+
+// OrderOptions are the orders sections by date can be in, from the oldest
+// with SortAscending or the newest with SortDescending.
+var OrderOptions = []string{SortAscending, SortDescending}
+
+// Group is a titled section of the report.
+type Group struct {
+	// Estimate is the sum of the estimates of the group's open tasks,
+	// including subtasks.
+	Estimate time.Duration
+	// Stats counts the completion of the group's tasks, including subtasks
+	// and the completed tasks that aren't output.
+	Stats Stats
+	Title string
+	Tasks []Task
+}
+
+// OpenTasks returns the group's tasks that aren't complete.
+func (group Group) OpenTasks() []Task {
+	open := []Task{}
+	for _, task := range group.Tasks {
+		if !task.Complete {
+			open = append(open, task)
+		}
+	}
+	return open
+}
+
+// CompletedTasks returns the group's completed tasks.
+func (group Group) CompletedTasks() []Task {
+	completed := []Task{}
+	for _, task := range group.Tasks {
+		if task.Complete {
+			completed = append(completed, task)
+		}
+	}
+	return completed
+}
+
+// Groups sections the top-level tasks to output according to GroupBy,
+// defaulting to one section per date in task order. Cancelled tasks shown
+// with ShowCancelled follow in a section of their own. Completed tasks that
+// aren't output still count toward their section's Stats.
+func (tasks Tasks) Groups() []Group {
+	shown := []Task{}
+	cancelled := []Task{}
+	for _, task := range tasks.Tasks {
+		if task.Cancelled() {
+			if !tasks.hidden(task) {
+				cancelled = append(cancelled, task)
+			}
+			continue
+		}
+		shown = append(shown, task)
+	}
+
+	var groups []Group
+	switch tasks.GroupBy {
+	case GroupByAssignee:
+		groups = groupByAssignee(shown)
+	case GroupByDue:
+		groups = groupByDue(shown, tasks.DateFormat, tasks.NewestFirst)
+	case GroupByFile:
+		groups = groupByFile(shown)
+	case GroupByHeader:
+		groups = groupByHeader(shown)
+	case GroupByMonth:
+		groups = groupByPeriod(shown, tasks.Undated, tasks.NewestFirst, func(date time.Time) string {
+			return date.Format("2006-01")
+		})
+	case GroupByQuarter:
+		groups = groupByPeriod(shown, tasks.Undated, tasks.NewestFirst, func(date time.Time) string {
+			return fmt.Sprintf("%d-Q%d", date.Year(), (int(date.Month())+2)/3)
+		})
+	case GroupByWeek:
+		groups = groupByPeriod(shown, tasks.Undated, tasks.NewestFirst, func(date time.Time) string {
+			year, week := date.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		})
+	case GroupByTag:
+		groups = groupByTag(shown)
+	default:
+		groups = groupByDate(shown, tasks.DateFormat, tasks.Undated, tasks.NewestFirst)
+	}
+	if len(cancelled) > 0 {
+		groups = append(groups, Group{Title: cancelledTitle, Tasks: cancelled})
+	}
+
+	// completed tasks are grouped to be counted, then left out of the output
+	output := []Group{}
+	for _, group := range groups {
+		completed, total := countTasks(group.Tasks)
+		group.Stats = Stats{Completed: completed, Incomplete: total - completed, Total: total}
+		visible := []Task{}
+		for _, task := range group.Tasks {
+			if !tasks.hidden(task) {
+				visible = append(visible, task)
+			}
+		}
+		if len(visible) == 0 {
+			continue
+		}
+		group.Tasks = visible
+		group.Estimate = remainingEstimate(group.Tasks)
+		output = append(output, group)
+	}
+	return output
+}
+
+// groupByDate sections tasks by date in date order, or from newest to oldest
+// with newestFirst, keeping the task order within each date, and titles the
+// sections with the date layout. Undated tasks are placed as given by undated.
+func groupByDate(all []Task, layout, undated string, newestFirst bool) []Group {
+	byDate := map[string][]Task{}
+	dated, without := splitUndated(all)
+	for _, task := range dated {
+		date := task.Date.Format(yearMonthDayLayout)
+		byDate[date] = append(byDate[date], task)
+	}
+	groups := sortedGroups(byDate)
+	for i, group := range groups {
+		groups[i].Title = formatDate(group.Tasks[0].Date, layout)
+	}
+	if newestFirst {
+		reverseGroups(groups)
+	}
+	return placeUndated(groups, without, undated)
+}
+
+// groupByPeriod sections tasks by the period their date falls in, named by
+// period so that they sort in date order, or from newest to oldest with
+// newestFirst, keeping the task order within each period. Undated tasks are
+// placed as given by undated.
+func groupByPeriod(all []Task, undated string, newestFirst bool, period func(date time.Time) string) []Group {
+	byPeriod := map[string][]Task{}
+	dated, without := splitUndated(all)
+	for _, task := range dated {
+		name := period(task.Date)
+		byPeriod[name] = append(byPeriod[name], task)
+	}
+	groups := sortedGroups(byPeriod)
+	if newestFirst {
+		reverseGroups(groups)
+	}
+	return placeUndated(groups, without, undated)
+}
+
+// reverseGroups reverses the order of the groups in place.
+func reverseGroups(groups []Group) {
+	for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+		groups[i], groups[j] = groups[j], groups[i]
+	}
+}
+
+// splitUndated separates the tasks with a date from those without, keeping
+// their order.
+func splitUndated(all []Task) (dated, undated []Task) {
+	for _, task := range all {
+		if task.Undated() {
+			undated = append(undated, task)
+		} else {
+			dated = append(dated, task)
+		}
+	}
+	return dated, undated
+}
+
+// placeUndated adds a section of the undated tasks before or after the
+// sections by date, one of UndatedOptions, or leaves them out with
+// UndatedSkip.
+func placeUndated(groups []Group, undated []Task, position string) []Group {
+	if len(undated) == 0 || position == UndatedSkip {
+		return groups
+	}
+	section := Group{Title: noDateTitle, Tasks: undated}
+	if position == UndatedFirst {
+		return append([]Group{section}, groups...)
+	}
+	return append(groups, section)
+}
+
+// groupByDue sections tasks by due date in date order, or from the latest to
+// the earliest with newestFirst, titled with the date layout, with tasks that
+// have no due date last.
+func groupByDue(all []Task, layout string, newestFirst bool) []Group {
+	byDue := map[string][]Task{}
+	undated := []Task{}
+	for _, task := range all {
+		if task.Due == nil {
+			undated = append(undated, task)
+			continue
+		}
+		due := task.Due.Format(yearMonthDayLayout)
+		byDue[due] = append(byDue[due], task)
+	}
+
+	groups := sortedGroups(byDue)
+	for i, group := range groups {
+		groups[i].Title = formatDate(*group.Tasks[0].Due, layout)
+	}
+	if newestFirst {
+		reverseGroups(groups)
+	}
+	if len(undated) > 0 {
+		groups = append(groups, Group{Title: noDueDateTitle, Tasks: undated})
+	}
+	return groups
+}
+
+// groupByFile sections tasks by source file in path order.
+func groupByFile(all []Task) []Group {
+	byFile := map[string][]Task{}
+	for _, task := range all {
+		byFile[task.FilePath] = append(byFile[task.FilePath], task)
+	}
+
+	// sections stay in path order but are titled by the note's front matter
+	// title when it has one
+	groups := sortedGroups(byFile)
+	for i, group := range groups {
+		if title := group.Tasks[0].FileTitle; title != "" {
+			groups[i].Title = title
+		}
+	}
+	return groups
+}
+
+// groupByHeader merges tasks under the same heading text across files into
+// one section, in alphabetical order, with tasks before any heading last.
+func groupByHeader(all []Task) []Group {
+	byHeader := map[string][]Task{}
+	noHeader := []Task{}
+	for _, task := range all {
+		header := strings.TrimSpace(task.PreviousHeader)
+		if header == "" {
+			noHeader = append(noHeader, task)
+			continue
+		}
+		byHeader[header] = append(byHeader[header], task)
+	}
+
+	groups := sortedGroups(byHeader)
+	if len(noHeader) > 0 {
+		groups = append(groups, Group{Title: noHeaderTitle, Tasks: noHeader})
+	}
+	return groups
+}
+
+// groupByTag lists each task under every one of its tags, with tags in
+// alphabetical order and untagged tasks last.
+func groupByTag(all []Task) []Group {
+	byTag := map[string][]Task{}
+	untagged := []Task{}
+	for _, task := range all {
+		if len(task.Tags) == 0 {
+			untagged = append(untagged, task)
+		}
+		seen := map[string]bool{}
+		for _, tag := range task.Tags {
+			tag = strings.ToLower(tag)
+			if !seen[tag] {
+				seen[tag] = true
+				byTag[tag] = append(byTag[tag], task)
+			}
+		}
+	}
+
+	groups := sortedGroups(byTag)
+	if len(untagged) > 0 {
+		groups = append(groups, Group{Title: untaggedTitle, Tasks: untagged})
+	}
+	return groups
+}
+
+// groupByAssignee sections tasks by the people they're assigned to, in name
+// order, with unassigned tasks last. Tasks assigned to several people are in
+// each of their sections.
+func groupByAssignee(all []Task) []Group {
+	byAssignee := map[string][]Task{}
+	unassigned := []Task{}
+	for _, task := range all {
+		if len(task.Assignees) == 0 {
+			unassigned = append(unassigned, task)
+		}
+		seen := map[string]bool{}
+		for _, assignee := range task.Assignees {
+			assignee = "@" + strings.ToLower(assignee)
+			if !seen[assignee] {
+				seen[assignee] = true
+				byAssignee[assignee] = append(byAssignee[assignee], task)
+			}
+		}
+	}
+
+	groups := sortedGroups(byAssignee)
+	if len(unassigned) > 0 {
+		groups = append(groups, Group{Title: unassignedTitle, Tasks: unassigned})
+	}
+	return groups
+}
+
+// formatDate writes the date with the layout, or as YYYY-MM-DD when it's
+// empty.
+func formatDate(date time.Time, layout string) string {
+	if layout == "" {
+		layout = yearMonthDayLayout
+	}
+	return date.Format(layout)
+}
+
+// sortedGroups returns a group for each title in alphabetical order.
+func sortedGroups(byTitle map[string][]Task) []Group {
+	titles := []string{}
+	for title := range byTitle {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+
+	groups := []Group{}
+	for _, title := range titles {
+		groups = append(groups, Group{Title: title, Tasks: byTitle[title]})
+	}
+	return groups
+}
@@ -0,0 +1,153 @@
+package tasks
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// completionMarkerPattern matches the completion date added to a task's text
+// as it's checked off, so the task reads the same before and after.
+var completionMarkerPattern = regexp.MustCompile(`(?i)\s*(?:\[completion::\s*\d{4}-\d{2}-\d{2}\]|(?:✅|\bdone:)\s*\d{4}-\d{2}-\d{2})`)
+
+// Completion is a task checked off, or reopened, in a commit.
+type Completion struct {
+	Commit string `json:"commit"`
+	// Complete is set when the task was checked off and unset when it was
+	// reopened.
+	Complete bool      `json:"complete"`
+	FilePath string    `json:"file"`
+	Text     string    `json:"text"`
+	Time     time.Time `json:"time"`
+}
+
+// GitHistory reads the git history of the markdown files under root and
+// returns when each task was checked off or reopened, oldest first, with
+// file paths relative to root. A task counts as checked off when a commit
+// removes it unchecked and adds it checked, with the same text apart from any
+// completion date.
+func GitHistory(root string) ([]Completion, error) {
+	command := exec.Command("git", "-c", "core.quotepath=off", "log", "--reverse", "--no-renames", "--no-color", "--no-ext-diff", "--relative", "--format=%x00%H %at", "-p", "--unified=0", "--", "*.md")
+	command.Dir = root
+	output, err := command.Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseGitLog(output)
+}
+
+// parseGitLog reads the commits and their patches written by GitHistory's
+// git log command.
+func parseGitLog(output []byte) ([]Completion, error) {
+	history := []Completion{}
+	commit := ""
+	var committed time.Time
+	filePath := ""
+	inHunk := false
+	// the tasks removed and added in the current file's patch, by their text
+	removed := map[string][]Status{}
+	added := []Task{}
+
+	// flush records the tasks the file's patch removed unchecked and added
+	// checked, or the other way round
+	flush := func() {
+		for _, task := range added {
+			key := CompletionKey(task.Text)
+			statuses := removed[key]
+			if len(statuses) == 0 {
+				continue
+			}
+			removed[key] = statuses[1:]
+			wasComplete := statuses[0] == StatusDone
+			if wasComplete == task.Complete {
+				continue
+			}
+			history = append(history, Completion{Commit: commit, Complete: task.Complete, FilePath: filePath, Text: task.Text, Time: committed})
+		}
+		removed = map[string][]Status{}
+		added = []Task{}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "\x00"):
+			flush()
+			fields := strings.Fields(strings.TrimPrefix(line, "\x00"))
+			if len(fields) != 2 {
+				continue
+			}
+			seconds, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			commit, committed = fields[0], time.Unix(seconds, 0).In(time.Local)
+			filePath = ""
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			inHunk = false
+		case !inHunk && strings.HasPrefix(line, "+++ "):
+			filePath = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case inHunk && strings.HasPrefix(line, "-"):
+			if task, ok := parseTask(time.Time{}, "", filePath, line[1:]); ok {
+				key := CompletionKey(task.Text)
+				removed[key] = append(removed[key], task.Status)
+			}
+		case inHunk && strings.HasPrefix(line, "+"):
+			if task, ok := parseTask(time.Time{}, "", filePath, line[1:]); ok {
+				added = append(added, *task)
+			}
+		}
+	}
+	flush()
+	return history, scanner.Err()
+}
+
+// CompletionKey is the task's text without its completion date, which stays
+// the same as the task is checked off.
+func CompletionKey(text string) string {
+	return strings.TrimSpace(completionMarkerPattern.ReplaceAllString(text, ""))
+}
+
+// WithHistory returns the tasks with each completed task that has no
+// completion date dated by the last time the history shows it checked off in
+// its file.
+func (tasks Tasks) WithHistory(history []Completion) Tasks {
+	completed := map[string]time.Time{}
+	for _, completion := range history {
+		key := completion.FilePath + "\x00" + CompletionKey(completion.Text)
+		if completion.Complete {
+			completed[key] = completion.Time
+		} else {
+			delete(completed, key)
+		}
+	}
+
+	var date func(all []Task) []Task
+	date = func(all []Task) []Task {
+		if all == nil {
+			return nil
+		}
+		dated := make([]Task, len(all))
+		for i, task := range all {
+			if task.Complete && task.CompletedAt == nil {
+				if at, ok := completed[task.FilePath+"\x00"+CompletionKey(task.Text)]; ok {
+					task.CompletedAt = &at
+				}
+			}
+			task.Subtasks = date(task.Subtasks)
+			dated[i] = task
+		}
+		return dated
+	}
+	tasks.Tasks = date(tasks.Tasks)
+	return tasks
+}
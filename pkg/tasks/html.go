@@ -0,0 +1,43 @@
+package tasks
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+// DefaultHTMLTemplate is the html/template used for HTML reports when no
+// other template is given. It is executed with a Report.
+//
+//go:embed templates/report.html.tmpl
+var DefaultHTMLTemplate string
+
+// WriteHTML renders the tasks as a self-contained HTML page using the
+// html/template text, or DefaultHTMLTemplate when empty. The template is
+// executed with a Report and can call link to get a task's source link,
+// breadcrumbs to get the headers it's under when Breadcrumbs is set, stale
+// to get its age when older than StaleAfter, and estimate to format an
+// estimate.
+func (tasks Tasks) WriteHTML(w io.Writer, templateText string) error {
+	if templateText == "" {
+		templateText = DefaultHTMLTemplate
+	}
+
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"breadcrumbs": tasks.breadcrumbs,
+		"estimate":    FormatEstimate,
+		"link": func(task Task) interface{} {
+			// editor URIs use schemes html/template would otherwise reject
+			if tasks.LinkStyle == LinkStyleEditor {
+				return template.URL(tasks.taskPath(task))
+			}
+			return tasks.taskPath(task)
+		},
+		"stale": tasks.staleMarker,
+	}).Parse(templateText)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, tasks.Report())
+}
@@ -0,0 +1,162 @@
+package tasks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ICS components tasks can be exported as.
+const (
+	// ICSEvent writes all-day events on each task's due date, or else its
+	// date, for calendars such as Google Calendar that don't show to-dos.
+	ICSEvent = "event"
+	// ICSTodo writes to-dos starting on each task's date and due on its due
+	// date.
+	ICSTodo = "todo"
+)
+
+// ICSComponentOptions lists the values accepted by WriteICS.
+var ICSComponentOptions = []string{ICSTodo, ICSEvent}
+
+const (
+	icsDateLayout     = "20060102"
+	icsDateTimeLayout = "20060102T150405Z"
+	// icsLineLength is the most octets allowed on a line before it's folded.
+	icsLineLength = 75
+	icsProductID  = "-//feckmore//markdown-task-aggregator//EN"
+	icsUIDDomain  = "markdown-task-aggregator"
+)
+
+var (
+	icsPriorities = map[Priority]int{
+		PriorityHighest: 1,
+		PriorityHigh:    3,
+		PriorityMedium:  5,
+		PriorityLow:     7,
+		PriorityLowest:  9,
+	}
+	icsTodoStatuses = map[Status]string{
+		StatusOpen:       "NEEDS-ACTION",
+		StatusDone:       "COMPLETED",
+		StatusInProgress: "IN-PROCESS",
+		StatusCancelled:  "CANCELLED",
+		StatusForwarded:  "NEEDS-ACTION",
+		StatusQuestion:   "NEEDS-ACTION",
+	}
+	icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+)
+
+// WriteICS renders the dated tasks, including subtasks, as an iCalendar file
+// of the component, one of ICSComponentOptions. Subtasks are related to their
+// parent task. The output only changes when the tasks do, so regenerating it
+// doesn't disturb calendars subscribed to it.
+func (tasks Tasks) WriteICS(w io.Writer, component string) error {
+	if component != ICSTodo && component != ICSEvent {
+		return fmt.Errorf("unknown ics component '%s'", component)
+	}
+
+	out := bufio.NewWriter(w)
+	writeICSLine(out, "BEGIN:VCALENDAR")
+	writeICSLine(out, "VERSION:2.0")
+	writeICSLine(out, "PRODID:"+icsProductID)
+	writeICSLine(out, "CALSCALE:GREGORIAN")
+
+	var writeTasks func(all []Task, parentID string)
+	writeTasks = func(all []Task, parentID string) {
+		for _, task := range all {
+			if !task.Undated() || task.Due != nil {
+				writeICSComponent(out, task, parentID, component)
+			}
+			writeTasks(task.Subtasks, task.ID)
+		}
+	}
+	writeTasks(tasks.Visible(), "")
+
+	writeICSLine(out, "END:VCALENDAR")
+	return out.Flush()
+}
+
+// writeICSComponent writes the task as a to-do or event.
+func writeICSComponent(out *bufio.Writer, task Task, parentID, component string) {
+	name := "VTODO"
+	if component == ICSEvent {
+		name = "VEVENT"
+	}
+	writeICSLine(out, "BEGIN:"+name)
+	writeICSLine(out, fmt.Sprintf("UID:%s@%s", task.ID, icsUIDDomain))
+	// the stamp must be stable for unchanged output, so it's the task's date,
+	// or due date when undated, rather than the time of export
+	stamp := task.Date
+	if task.Undated() {
+		stamp = *task.Due
+	}
+	writeICSLine(out, "DTSTAMP:"+stamp.UTC().Format(icsDateTimeLayout))
+	writeICSLine(out, "SUMMARY:"+icsEscaper.Replace(task.Text))
+	writeICSLine(out, "DESCRIPTION:"+icsEscaper.Replace(fmt.Sprintf("%s:%d", task.FilePath, task.Line)))
+
+	if component == ICSEvent {
+		day := task.Date
+		if task.Due != nil {
+			day = *task.Due
+		}
+		writeICSLine(out, "DTSTART;VALUE=DATE:"+day.Format(icsDateLayout))
+		writeICSLine(out, "DTEND;VALUE=DATE:"+day.AddDate(0, 0, 1).Format(icsDateLayout))
+		if task.Cancelled() {
+			writeICSLine(out, "STATUS:CANCELLED")
+		}
+	} else {
+		// a to-do must be due after it starts
+		if !task.Undated() && (task.Due == nil || task.Date.Before(*task.Due)) {
+			writeICSLine(out, "DTSTART;VALUE=DATE:"+task.Date.Format(icsDateLayout))
+		}
+		if task.Due != nil {
+			writeICSLine(out, "DUE;VALUE=DATE:"+task.Due.Format(icsDateLayout))
+		}
+		status := icsTodoStatuses[task.Status]
+		if task.Complete {
+			status = icsTodoStatuses[StatusDone]
+		}
+		writeICSLine(out, "STATUS:"+status)
+		if task.Complete && task.CompletedAt != nil {
+			writeICSLine(out, "COMPLETED:"+task.CompletedAt.UTC().Format(icsDateTimeLayout))
+		}
+	}
+
+	if priority, ok := icsPriorities[task.Priority]; ok {
+		writeICSLine(out, fmt.Sprintf("PRIORITY:%d", priority))
+	}
+	if len(task.Tags) > 0 {
+		categories := []string{}
+		for _, tag := range task.Tags {
+			categories = append(categories, icsEscaper.Replace(tag[1:]))
+		}
+		writeICSLine(out, "CATEGORIES:"+strings.Join(categories, ","))
+	}
+	if parentID != "" {
+		writeICSLine(out, fmt.Sprintf("RELATED-TO:%s@%s", parentID, icsUIDDomain))
+	}
+	writeICSLine(out, "END:"+name)
+}
+
+// writeICSLine writes a content line ended by CRLF, folding it onto
+// continuation lines starting with a space so no line is longer than
+// icsLineLength octets. Lines are only folded between characters.
+func writeICSLine(out *bufio.Writer, line string) {
+	limit := icsLineLength
+	for len(line) > limit {
+		end := limit
+		for end > 0 && !utf8.RuneStart(line[end]) {
+			end--
+		}
+		out.WriteString(line[:end])
+		out.WriteString("\r\n ")
+		line = line[end:]
+		// the leading space counts toward the continuation line's length
+		limit = icsLineLength - 1
+	}
+	out.WriteString(line)
+	out.WriteString("\r\n")
+}
@@ -0,0 +1,149 @@
+package tasks
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFilenames are the files read from each directory for patterns of
+// paths to skip, using .gitignore syntax.
+var ignoreFilenames = []string{".gitignore", ".ignore"}
+
+// ignoreRule is a single .gitignore style pattern, relative to the directory
+// it was declared in.
+type ignoreRule struct {
+	base    string
+	dirOnly bool
+	negate  bool
+	re      *regexp.Regexp
+}
+
+type ignoreRules []ignoreRule
+
+// newIgnoreRule parses a .gitignore style pattern, returning false for blank
+// lines and comments.
+func newIgnoreRule(base, pattern string) (ignoreRule, bool) {
+	rule := ignoreRule{base: base}
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(pattern, "!") {
+		rule.negate = true
+		pattern = pattern[1:]
+	}
+	pattern = strings.TrimPrefix(pattern, `\`)
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return rule, false
+	}
+
+	// patterns containing a slash are relative to the base, others match a
+	// name at any depth
+	prefix := "^(?:.*/)?"
+	if strings.Contains(pattern, "/") {
+		prefix = "^"
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+
+	re, err := regexp.Compile(prefix + globToRegexp(pattern) + "$")
+	if err != nil {
+		return rule, false
+	}
+	rule.re = re
+
+	return rule, true
+}
+
+// globToRegexp converts a glob pattern with .gitignore's `**` semantics to a
+// regular expression.
+func globToRegexp(glob string) string {
+	var out strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			out.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			out.WriteString(".*")
+			i++
+		case c == '*':
+			out.WriteString("[^/]*")
+		case c == '?':
+			out.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				out.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			out.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end
+		default:
+			out.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return out.String()
+}
+
+// withPatterns returns a copy of the rules with the patterns added, relative
+// to base.
+func (rules ignoreRules) withPatterns(base string, patterns []string) ignoreRules {
+	combined := append(ignoreRules{}, rules...)
+	for _, pattern := range patterns {
+		if rule, ok := newIgnoreRule(base, pattern); ok {
+			combined = append(combined, rule)
+		}
+	}
+	return combined
+}
+
+// withIgnoreFiles returns a copy of the rules with the patterns from any
+// ignore files in dirPath added.
+func (rules ignoreRules) withIgnoreFiles(dirPath string) ignoreRules {
+	for _, filename := range ignoreFilenames {
+		file, err := os.Open(filepath.Join(dirPath, filename))
+		if err != nil {
+			continue
+		}
+		patterns := []string{}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			patterns = append(patterns, scanner.Text())
+		}
+		file.Close()
+		rules = rules.withPatterns(dirPath, patterns)
+	}
+	return rules
+}
+
+// ignored reports whether the path should be skipped. The last matching rule
+// wins, so later negated patterns can re-include paths.
+func (rules ignoreRules) ignored(filePath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		relPath, err := filepath.Rel(rule.base, filePath)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			continue
+		}
+		if rule.re.MatchString(path.Clean(filepath.ToSlash(relPath))) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
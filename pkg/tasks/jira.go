@@ -0,0 +1,107 @@
+package tasks
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// jiraSummaryLength is the longest summary Jira accepts.
+const jiraSummaryLength = 255
+
+// jiraPriorities are the names of Jira's default priorities.
+var jiraPriorities = map[Priority]string{
+	PriorityHighest: "Highest",
+	PriorityHigh:    "High",
+	PriorityMedium:  "Medium",
+	PriorityLow:     "Low",
+	PriorityLowest:  "Lowest",
+}
+
+// WriteJiraCSV renders the incomplete tasks, including subtasks, as a CSV file
+// for Jira's CSV importer. Each task becomes a Task issue, or a Sub-task of the
+// issue for the task it's nested under, with its text as the summary and, in
+// the description, its context and a link back to its note. Due dates are
+// written as 2006-01-02, to be imported with the date format yyyy-MM-dd, and
+// tags become labels, one Labels column each.
+func (tasks Tasks) WriteJiraCSV(w io.Writer) error {
+	type jiraIssue struct {
+		parentID string
+		task     Task
+	}
+	issues := []jiraIssue{}
+	labels := 0
+	// subtasks of closed tasks are imported under the nearest open task
+	var collect func(all []Task, parentID string)
+	collect = func(all []Task, parentID string) {
+		for _, task := range all {
+			if task.Complete || task.Cancelled() {
+				collect(task.Subtasks, parentID)
+				continue
+			}
+			issues = append(issues, jiraIssue{parentID: parentID, task: task})
+			labels = max(labels, len(task.Tags))
+			collect(task.Subtasks, task.ID)
+		}
+	}
+	collect(tasks.Visible(), "")
+
+	writer := csv.NewWriter(w)
+	header := []string{"Summary", "Issue Type", "Issue Id", "Parent Id", "Description", "Priority", "Due Date"}
+	for i := 0; i < labels; i++ {
+		header = append(header, "Labels")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		task := issue.task
+		issueType := "Task"
+		if issue.parentID != "" {
+			issueType = "Sub-task"
+		}
+		due := ""
+		if task.Due != nil {
+			due = task.Due.Format(yearMonthDayLayout)
+		}
+		row := []string{jiraSummary(task), issueType, task.ID, issue.parentID, tasks.jiraDescription(task), jiraPriorities[task.Priority], due}
+		for i := 0; i < labels; i++ {
+			label := ""
+			if i < len(task.Tags) {
+				label = strings.TrimLeft(task.Tags[i], "#@")
+			}
+			row = append(row, label)
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// jiraSummary returns the task's text without its markers, and with tags
+// read as words, cut to the length Jira allows.
+func jiraSummary(task Task) string {
+	summary := plainText(task.Text, func(tag string) string {
+		return strings.TrimLeft(tag, "#@")
+	})
+	if runes := []rune(summary); len(runes) > jiraSummaryLength {
+		summary = string(runes[:jiraSummaryLength-1]) + "…"
+	}
+	return summary
+}
+
+// jiraDescription returns the task's text and context, followed by a link, in
+// Jira's markup, to the line of the note it came from.
+func (tasks Tasks) jiraDescription(task Task) string {
+	lines := []string{task.Text}
+	if len(task.Context) > 0 {
+		lines = append(lines, "", strings.Join(task.Context, "\n"))
+	}
+	lines = append(lines, "", fmt.Sprintf("From [%s:%d|%s]", task.FilePath, task.Line, tasks.taskPath(task)))
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,13 @@
+package tasks
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteJSON renders the tasks as an indented JSON array.
+func (tasks Tasks) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tasks.Visible())
+}
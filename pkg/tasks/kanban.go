@@ -0,0 +1,73 @@
+package tasks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	kanbanBacklog    = "Backlog"
+	kanbanInProgress = "In Progress"
+	kanbanDone       = "Done"
+	kanbanCancelled  = "Cancelled"
+)
+
+// kanbanInProgressTags put an open task in the In Progress column.
+var kanbanInProgressTags = []string{"#doing", "#in-progress", "#wip"}
+
+// WriteKanban renders the tasks, including subtasks, as a board for the
+// Obsidian Kanban plugin, with Backlog, In Progress, and Done columns and a
+// column for cancelled tasks with ShowCancelled. Tasks marked [/] or tagged
+// #doing, #in-progress, or #wip are in progress. Cards link to their source
+// in LinkStyle and keep their ID, so cards checked off on the board can be
+// written back to their notes with sync.
+func (tasks Tasks) WriteKanban(w io.Writer) error {
+	columns := map[string][]Task{}
+	for _, task := range Flatten(tasks.Visible()) {
+		column := kanbanColumn(task)
+		columns[column] = append(columns[column], task)
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprint(out, "---\n\nkanban-plugin: basic\n\n---\n")
+	for _, column := range []string{kanbanBacklog, kanbanInProgress, kanbanDone, kanbanCancelled} {
+		if column == kanbanCancelled && len(columns[column]) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n## %s\n\n", column)
+		// the plugin checks off cards moved into a column marked complete
+		if column == kanbanDone {
+			fmt.Fprint(out, "**Complete**\n")
+		}
+		for _, task := range columns[column] {
+			check := task.Status.Symbol()
+			if task.Complete {
+				check = StatusDone.Symbol()
+			}
+			fmt.Fprintf(out, "- [%s] %s <!-- id:%s -->\n", check, tasks.taskLink(task), task.ID)
+		}
+	}
+	fmt.Fprint(out, "\n%% kanban:settings\n```\n{\"kanban-plugin\":\"basic\"}\n```\n%%\n")
+	return out.Flush()
+}
+
+// kanbanColumn returns the column the task's status, or else its tags, put it
+// in.
+func kanbanColumn(task Task) string {
+	switch {
+	case task.Complete:
+		return kanbanDone
+	case task.Cancelled():
+		return kanbanCancelled
+	case task.Status == StatusInProgress:
+		return kanbanInProgress
+	}
+	for _, tag := range task.Tags {
+		if contains(kanbanInProgressTags, strings.ToLower(tag)) {
+			return kanbanInProgress
+		}
+	}
+	return kanbanBacklog
+}
@@ -0,0 +1,86 @@
+package tasks
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultMaxLineLength is the longest line, in bytes, read when ParseOptions
+// doesn't set MaxLineLength.
+const DefaultMaxLineLength = 1 << 20
+
+// LongLinesError lists the 1-based lines of a file that were longer than the
+// most ParseOptions allows. They are skipped and the rest of the file is
+// parsed, so the tasks found are returned with the error.
+type LongLinesError struct {
+	Lines []int
+}
+
+func (err *LongLinesError) Error() string {
+	lines := []string{}
+	for _, line := range err.Lines {
+		lines = append(lines, fmt.Sprint(line))
+	}
+	return fmt.Sprintf("skipped %d lines too long to read: %s", len(err.Lines), strings.Join(lines, ", "))
+}
+
+// lineReader reads a file a line at a time, without line endings, holding at
+// most limit bytes of a line in memory. The rest of a longer line is read
+// past and discarded.
+type lineReader struct {
+	err    error
+	limit  int
+	reader *bufio.Reader
+	// text is the line read, and tooLong is set instead when it was longer
+	// than limit
+	text    string
+	tooLong bool
+}
+
+func newLineReader(r io.Reader, limit int) *lineReader {
+	if limit <= 0 {
+		limit = DefaultMaxLineLength
+	}
+	return &lineReader{limit: limit, reader: bufio.NewReader(r)}
+}
+
+// scan reads the next line, returning false at the end of the file or on an
+// error, which is kept in err.
+func (r *lineReader) scan() bool {
+	var line []byte
+	r.tooLong = false
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		if !r.tooLong {
+			if len(line)+len(chunk) > r.limit+len("\r\n") {
+				r.tooLong = true
+				line = nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			r.err = err
+			return false
+		}
+		if err == io.EOF && len(chunk) == 0 && len(line) == 0 && !r.tooLong {
+			return false
+		}
+
+		text := strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r")
+		if len(text) > r.limit {
+			r.tooLong = true
+		}
+		r.text = text
+		if r.tooLong {
+			r.text = ""
+		}
+		return true
+	}
+}
@@ -0,0 +1,123 @@
+package tasks
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// LinkStyleMarkdown links tasks as [text](file.md#header).
+	LinkStyleMarkdown = "markdown"
+	// LinkStyleWikilink links tasks as [[file#Header|text]], for Obsidian and
+	// other wiki style note apps.
+	LinkStyleWikilink = "wikilink"
+	// LinkStyleEditor links tasks to their line in the Editor app, with
+	// [text](vscode://file/...) style links to the absolute path of their file.
+	LinkStyleEditor = "editor"
+)
+
+// LinkStyleOptions lists the supported ways of linking tasks to their source.
+var LinkStyleOptions = []string{LinkStyleMarkdown, LinkStyleWikilink, LinkStyleEditor}
+
+const (
+	// EditorVSCode opens tasks at their line in Visual Studio Code.
+	EditorVSCode = "vscode"
+	// EditorObsidian opens the notes tasks are in with Obsidian, which can't
+	// go to a line.
+	EditorObsidian = "obsidian"
+)
+
+// EditorOptions lists the apps LinkStyleEditor can link to.
+var EditorOptions = []string{EditorVSCode, EditorObsidian}
+
+// wikilinkReserved holds the characters that can't appear in a wikilink
+// target.
+const wikilinkReserved = "#|[]^"
+
+// taskLink links the task's text to where it was found, in the tasks' link
+// style.
+func (tasks Tasks) taskLink(task Task) string {
+	if tasks.LinkStyle == LinkStyleWikilink {
+		return wikilink(task.FilePath, task.PreviousHeader, task.Text)
+	}
+	return fmt.Sprintf("[%s](%s)", task.Text, tasks.taskPath(task))
+}
+
+// editorURI opens the task's file at its line in the tasks' editor, finding
+// the file under SourceBase.
+func (tasks Tasks) editorURI(task Task) string {
+	filePath := filepath.ToSlash(filepath.Join(tasks.SourceBase, filepath.FromSlash(task.FilePath)))
+	if tasks.Editor == EditorObsidian {
+		return "obsidian://open?path=" + strings.ReplaceAll(url.QueryEscape(filePath), "+", "%20")
+	}
+	// Windows paths start with a drive letter rather than a slash
+	if !strings.HasPrefix(filePath, "/") {
+		filePath = "/" + filePath
+	}
+	return fmt.Sprintf("vscode://file%s:%d", escapePath(filePath), task.Line)
+}
+
+// parseEditorURI reads the absolute file path from a link written by
+// editorURI.
+func parseEditorURI(target string) (string, bool) {
+	const obsidianPrefix, vscodePrefix = "obsidian://open?", "vscode://file"
+	if strings.HasPrefix(target, obsidianPrefix) {
+		values, err := url.ParseQuery(strings.TrimPrefix(target, obsidianPrefix))
+		if err != nil || values.Get("path") == "" {
+			return "", false
+		}
+		return values.Get("path"), true
+	}
+	if !strings.HasPrefix(target, vscodePrefix) {
+		return "", false
+	}
+	filePath := strings.TrimPrefix(target, vscodePrefix)
+	if line := strings.LastIndex(filePath, ":"); line >= 0 {
+		filePath = filePath[:line]
+	}
+	if unescaped, err := url.PathUnescape(filePath); err == nil {
+		filePath = unescaped
+	}
+	// drop the slash added before a Windows drive letter
+	if len(filePath) > 2 && filePath[2] == ':' {
+		filePath = filePath[1:]
+	}
+	return filePath, true
+}
+
+// wikilink links text to the note at filePath, named by its path without the
+// extension, and to its heading if there is one.
+func wikilink(filePath, lastHeader, text string) string {
+	target := strings.TrimSuffix(filePath, path.Ext(filePath))
+	header := strings.TrimSpace(strings.Map(func(c rune) rune {
+		if strings.ContainsRune(wikilinkReserved, c) {
+			return -1
+		}
+		return c
+	}, lastHeader))
+	if header != "" {
+		target += "#" + header
+	}
+	return fmt.Sprintf("[[%s|%s]]", target, text)
+}
+
+// parseWikilinkTask reads the source file path and text from a report line
+// linking its task with a wikilink.
+func parseWikilinkTask(line string) (Task, bool) {
+	start := strings.Index(line, "[[")
+	end := strings.LastIndex(line, "]]")
+	if start < 0 || end < start {
+		return Task{}, false
+	}
+	target, text, ok := strings.Cut(line[start+2:end], "|")
+	if !ok {
+		return Task{}, false
+	}
+	if anchor := strings.Index(target, "#"); anchor >= 0 {
+		target = target[:anchor]
+	}
+	return Task{FilePath: target + ".md", Text: text}, true
+}
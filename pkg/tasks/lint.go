@@ -0,0 +1,190 @@
+package tasks
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The checks Lint and LintCheckboxes make, named in each Finding.
+const (
+	LintDuplicate = "duplicate"
+	LintMalformed = "malformed-checkbox"
+	LintOpen      = "too-many-open"
+	LintStale     = "stale"
+)
+
+// checkboxPattern matches a list item opening with a checkbox, with the
+// bullet, the space after it, and the mark between the brackets as
+// submatches.
+var checkboxPattern = regexp.MustCompile(`^[\s\p{Zs}]*([-+*])([\s\p{Zs}]*)\[([^\]]*)\]`)
+
+// Finding is a suspicious pattern found in a note.
+type Finding struct {
+	Check    string `json:"check"`
+	FilePath string `json:"file"`
+	// Line is the 1-based line the finding is at, or 0 when it's about the
+	// whole file.
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// LintOptions sets the thresholds of Lint. Zero values turn their check off.
+type LintOptions struct {
+	// MaxAgeDays reports open tasks dated more than this many days before Now.
+	MaxAgeDays int
+	// MaxOpen reports files with more open tasks than this, including
+	// subtasks.
+	MaxOpen int
+	Now     time.Time
+}
+
+// Lint reports files with more open tasks than MaxOpen, open tasks older than
+// MaxAgeDays, and tasks repeating the text of an earlier task in their file,
+// in order of file and line. Cancelled tasks and upcoming instances of
+// recurring tasks are left out of every check.
+func (tasks Tasks) Lint(options LintOptions) []Finding {
+	findings := []Finding{}
+	open := map[string]int{}
+	seen := map[string]map[string]int{}
+	cutoff := options.Now.AddDate(0, 0, -options.MaxAgeDays).Format(yearMonthDayLayout)
+	for _, task := range Flatten(tasks.Tasks) {
+		if task.Cancelled() || task.RecurrenceOf != "" {
+			continue
+		}
+		if !task.Complete {
+			open[task.FilePath]++
+			if options.MaxAgeDays > 0 && !task.Undated() && task.Date.Format(yearMonthDayLayout) < cutoff {
+				days := int(options.Now.Sub(task.Date).Hours() / 24)
+				findings = append(findings, Finding{Check: LintStale, FilePath: task.FilePath, Line: task.Line, Message: fmt.Sprintf("open for %d days: %s", days, task.Text)})
+			}
+		}
+
+		text := strings.ToLower(strings.Join(strings.Fields(task.Text), " "))
+		if text == "" {
+			continue
+		}
+		if seen[task.FilePath] == nil {
+			seen[task.FilePath] = map[string]int{}
+		}
+		if first, ok := seen[task.FilePath][text]; ok {
+			findings = append(findings, Finding{Check: LintDuplicate, FilePath: task.FilePath, Line: task.Line, Message: fmt.Sprintf("same text as line %d: %s", first, task.Text)})
+			continue
+		}
+		seen[task.FilePath][text] = task.Line
+	}
+
+	if options.MaxOpen > 0 {
+		for filePath, count := range open {
+			if count > options.MaxOpen {
+				findings = append(findings, Finding{Check: LintOpen, FilePath: filePath, Message: fmt.Sprintf("%d open tasks, more than %d", count, options.MaxOpen)})
+			}
+		}
+	}
+	SortFindings(findings)
+	return findings
+}
+
+// LintCheckboxes reports the list items of the file read whose checkbox is
+// malformed, as in - [], - [ x], or *[x], so they aren't read as tasks or
+// aren't in their canonical form. Fenced code blocks are skipped.
+func LintCheckboxes(r io.Reader, filePath string) ([]Finding, error) {
+	findings := []Finding{}
+	lines := newLineReader(r, 0)
+	fence := ""
+	for lineNumber := 1; lines.scan(); lineNumber++ {
+		if skipFenced(&fence, lines.text) {
+			continue
+		}
+		if normalized, ok := NormalizeCheckbox(lines.text); ok {
+			box := checkboxPattern.FindString(lines.text)
+			fixed := checkboxPattern.FindString(normalized)
+			findings = append(findings, Finding{Check: LintMalformed, FilePath: filePath, Line: lineNumber, Message: fmt.Sprintf("checkbox %s should be %s: %s", strings.TrimSpace(box), strings.TrimSpace(fixed), strings.TrimSpace(lines.text))})
+		}
+	}
+	return findings, lines.err
+}
+
+// NormalizeCheckbox rewrites a near-miss checkbox opening the line, as in
+// - [], - [ x ], - [X ], or *[x], in its canonical form, as in - [ ] or
+// - [X], reporting whether it did. Other lines are returned as they are.
+func NormalizeCheckbox(line string) (string, bool) {
+	match := checkboxPattern.FindStringSubmatchIndex(line)
+	if match == nil {
+		return line, false
+	}
+	space, mark := line[match[4]:match[5]], line[match[6]:match[7]]
+	canonical := mark
+	if trimmed := strings.TrimSpace(mark); trimmed != "" {
+		canonical = trimmed
+	} else if mark == "" {
+		canonical = " "
+	}
+	if !taskPattern.MatchString("- ["+canonical+"]") || (canonical == mark && space != "") {
+		return line, false
+	}
+	if space == "" {
+		space = " "
+	}
+	return line[:match[3]] + space + "[" + canonical + "]" + line[match[1]:], true
+}
+
+// FixCheckboxes rewrites the near-miss checkboxes of the file in their
+// canonical form, as NormalizeCheckbox does, outside fenced code blocks,
+// returning how many it rewrote.
+func FixCheckboxes(filePath string) (int, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	fixed := 0
+	fence := ""
+	for i, line := range lines {
+		if skipFenced(&fence, strings.TrimRight(line, "\r\n")) {
+			continue
+		}
+		if normalized, ok := NormalizeCheckbox(line); ok {
+			lines[i] = normalized
+			fixed++
+		}
+	}
+	if fixed == 0 {
+		return 0, nil
+	}
+	_, err = WriteFileAtomic(filePath, []byte(strings.Join(lines, "")))
+	return fixed, err
+}
+
+// skipFenced reports whether the line opens, closes, or is inside a fenced
+// code block, tracking the marker of the block it's in with fence.
+func skipFenced(fence *string, line string) bool {
+	marker, info := codeFence(line)
+	if *fence == "" && marker != "" {
+		*fence = marker
+		return true
+	}
+	if *fence != "" {
+		if marker != "" && marker[0] == (*fence)[0] && len(marker) >= len(*fence) && info == "" {
+			*fence = ""
+		}
+		return true
+	}
+	return false
+}
+
+// SortFindings orders findings by file, then line, with those about the
+// whole file first.
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if order := comparePaths(findings[i].FilePath, findings[j].FilePath); order != 0 {
+			return order < 0
+		}
+		return findings[i].Line < findings[j].Line
+	})
+}
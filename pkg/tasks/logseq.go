@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// logseqTaskPattern matches a block starting with one of the keywords
+	// Logseq marks tasks with.
+	logseqTaskPattern = regexp.MustCompile(`^[\s\p{Zs}]*[-+*][\s\p{Zs}]+(TODO|LATER|WAITING|WAIT|DOING|NOW|IN-PROGRESS|DONE|CANCELED|CANCELLED)(?:[\s\p{Zs}]+(.*))?$`)
+	// logseqPriorityPattern matches a priority written as [#A].
+	logseqPriorityPattern = regexp.MustCompile(`\[#([A-C])\]`)
+	// logseqPlanningPattern matches the SCHEDULED: and DEADLINE: lines Logseq
+	// writes under a task.
+	logseqPlanningPattern = regexp.MustCompile(`^[\s\p{Zs}]*(SCHEDULED|DEADLINE):\s*<(\d{4}-\d{2}-\d{2})`)
+	// logseqPropertyPattern matches the key:: value lines Logseq writes under
+	// a block.
+	logseqPropertyPattern = regexp.MustCompile(`^[\s\p{Zs}]*[\w-]+::`)
+	// logseqJournalFormats are the dates journals are named by.
+	logseqJournalFormats = []dateFormat{
+		{pattern: regexp.MustCompile(`^(\d{4}_\d{2}_\d{2})`), layouts: []string{"2006_01_02"}},
+	}
+)
+
+// logseqStatuses are the statuses of Logseq's task keywords, where the
+// keywords of its NOW/LATER workflow are read like those of TODO/DOING.
+var logseqStatuses = map[string]Status{
+	"TODO":        StatusOpen,
+	"LATER":       StatusOpen,
+	"WAITING":     StatusOpen,
+	"WAIT":        StatusOpen,
+	"DOING":       StatusInProgress,
+	"NOW":         StatusInProgress,
+	"IN-PROGRESS": StatusInProgress,
+	"DONE":        StatusDone,
+	"CANCELED":    StatusCancelled,
+	"CANCELLED":   StatusCancelled,
+}
+
+// logseqKeywords are the keywords SetStatus writes for the statuses Logseq
+// has one for.
+var logseqKeywords = map[Status]string{
+	StatusOpen:       "TODO",
+	StatusInProgress: "DOING",
+	StatusDone:       "DONE",
+	StatusCancelled:  "CANCELED",
+}
+
+// logseqPriorities are the priorities Logseq writes as [#A], [#B], and [#C].
+var logseqPriorities = map[string]Priority{"A": PriorityHigh, "B": PriorityMedium, "C": PriorityLow}
+
+// logseqJournalDate returns the date of a Logseq journal named like
+// 2024_03_01.md, or nil if the name isn't one.
+func logseqJournalDate(name string) *time.Time {
+	return parseDateFormats(logseqJournalFormats, name, nil)
+}
+
+// parseLogseqTask reads a block starting with a task keyword as a task with
+// the keyword's status.
+func parseLogseqTask(date time.Time, lastHeader, filePath, line string) (*Task, bool) {
+	match := logseqTaskPattern.FindStringSubmatch(line)
+	if match == nil || strings.TrimSpace(match[2]) == "" {
+		return nil, false
+	}
+
+	status := logseqStatuses[match[1]]
+	text := strings.TrimSpace(match[2])
+	priority := parsePriority(text)
+	if priorityMatch := logseqPriorityPattern.FindStringSubmatch(text); priorityMatch != nil {
+		priority = logseqPriorities[priorityMatch[1]]
+	}
+	return &Task{
+		Complete:       status == StatusDone,
+		CompletedAt:    parseDate(completedPattern, text, nil),
+		Date:           date,
+		Due:            parseDate(duePattern, text, nil),
+		Estimate:       parseEstimate(text),
+		Fields:         parseFields(text),
+		FilePath:       filePath,
+		PreviousHeader: lastHeader,
+		Priority:       priority,
+		Recurrence:     parseRecurrence(text),
+		Start:          parseDate(startPattern, text, nil),
+		Status:         status,
+		Tags:           parseTags(text),
+		Text:           text,
+	}, true
+}
+
+// planLogseqTask sets the task's due date from a DEADLINE: line or its start
+// date from a SCHEDULED: line, reporting whether the line was either.
+func planLogseqTask(task *Task, line string) bool {
+	match := logseqPlanningPattern.FindStringSubmatch(line)
+	if match == nil {
+		return false
+	}
+	date, err := time.Parse(yearMonthDayLayout, match[2])
+	if err != nil {
+		return true
+	}
+	if match[1] == "DEADLINE" {
+		task.Due = &date
+	} else {
+		task.Start = &date
+	}
+	return true
+}
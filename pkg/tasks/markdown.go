@@ -0,0 +1,243 @@
+package tasks
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// reportTaskPattern matches a task line written by WriteMarkdown, capturing
+// the checkbox and everything after it.
+var reportTaskPattern = regexp.MustCompile(`^\s*- \[([ xX/>?-])\] (.*)$`)
+
+// reportIDPattern matches the comment holding a task's ID in reports.
+var reportIDPattern = regexp.MustCompile(` ?<!-- id:(\w+) -->`)
+
+// DefaultMarkdownTemplate is the text/template used for markdown reports when
+// no other template is given. It is executed with a Report.
+//
+//go:embed templates/report.md.tmpl
+var DefaultMarkdownTemplate string
+
+func (tasks Tasks) String() string {
+	var out strings.Builder
+	// the default template always parses, and writing to a builder can't fail
+	_ = tasks.WriteMarkdownTemplate(&out, "")
+	return out.String()
+}
+
+// WriteMarkdownTemplate renders the tasks using the text/template text, or
+// DefaultMarkdownTemplate when empty. The template is executed with a Report
+// and can call:
+//
+//	breadcrumbs TASK  the headers the task is under, with Breadcrumbs
+//	chart             the chart selected by Chart, or nothing
+//	estimate DURATION the estimate, such as a Group's, as 3h30m
+//	task TASK         the task as a list item, with its context and subtasks
+//	link TASK         the task's text linked to its source in LinkStyle
+//	path TASK         the path, with header anchor, of the task's source
+//	stale TASK        the ⚠️ age of a task older than StaleAfter, or nothing
+//	table TASKS       the tasks as a table in TableColumns, with subtasks
+//	tasks TASKS       the tasks as list items or a table, as Layout selects
+func (tasks Tasks) WriteMarkdownTemplate(w io.Writer, templateText string) error {
+	if templateText == "" {
+		templateText = DefaultMarkdownTemplate
+	}
+
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"breadcrumbs": tasks.breadcrumbs,
+		"chart": func() string {
+			var out strings.Builder
+			if tasks.Chart == ChartBurndown {
+				tasks.writeBurndown(&out)
+			}
+			return out.String()
+		},
+		"estimate": FormatEstimate,
+		"link":     tasks.taskLink,
+		"path":     tasks.taskPath,
+		"stale":    tasks.staleMarker,
+		"table": func(all []Task) string {
+			var out strings.Builder
+			tasks.writeTable(&out, all)
+			return out.String()
+		},
+		"tasks": func(all []Task) string {
+			var out strings.Builder
+			tasks.writeTasks(&out, all)
+			return out.String()
+		},
+		"task": func(task Task) string {
+			var out strings.Builder
+			tasks.writeTask(&out, task, 0)
+			return out.String()
+		},
+	}).Parse(templateText)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, tasks.Report())
+}
+
+// writeTasks writes the tasks as list items, or as a table with LayoutTable.
+func (tasks Tasks) writeTasks(out *strings.Builder, all []Task) {
+	if len(all) == 0 {
+		return
+	}
+	if tasks.Layout == LayoutTable {
+		tasks.writeTable(out, all)
+		return
+	}
+	for _, task := range all {
+		tasks.writeTask(out, task, 0)
+	}
+}
+
+// writeTask writes the task as a list item indented to its depth, followed by
+// its subtasks.
+func (tasks Tasks) writeTask(out *strings.Builder, task Task, depth int) {
+	// tasks built by hand may only set Complete
+	check := task.Status.Symbol()
+	if task.Complete {
+		check = StatusDone.Symbol()
+	}
+
+	badge := ""
+	if task.Priority != PriorityNone {
+		badge = task.Priority.Badge() + " "
+	}
+
+	overdue := ""
+	if task.Overdue() {
+		overdue = " **overdue**"
+	}
+	if marker := tasks.staleMarker(task); marker != "" {
+		overdue += " " + marker
+	}
+
+	seen := ""
+	if task.FirstSeen != nil && task.LastSeen != nil {
+		seen = fmt.Sprintf(" _(first seen %s, last seen %s in %s)_", task.FirstSeen.Format(yearMonthDayLayout), task.LastSeen.Format(yearMonthDayLayout), strings.Join(task.Sources, ", "))
+	}
+
+	progress := ""
+	if completed, total := task.Progress(); tasks.Rollup && total > 0 {
+		progress = fmt.Sprintf(" (%d/%d)", completed, total)
+	}
+
+	breadcrumbs := ""
+	if crumbs := tasks.breadcrumbs(task); crumbs != "" {
+		breadcrumbs = fmt.Sprintf(" _%s_", crumbs)
+	}
+
+	link := tasks.taskLink(task)
+	if task.Cancelled() {
+		link = fmt.Sprintf("~~%s~~", link)
+	}
+
+	indent := strings.Repeat("  ", depth)
+	out.WriteString(fmt.Sprintf("%s- [%s] %s%s%s%s%s%s <!-- id:%s -->\n", indent, check, badge, link, breadcrumbs, overdue, seen, progress, task.ID))
+	writeContext(out, task.Context, indent+"  ")
+	for _, subtask := range task.Subtasks {
+		if tasks.hidden(subtask) {
+			continue
+		}
+		tasks.writeTask(out, subtask, depth+1)
+	}
+}
+
+// breadcrumbs joins the headers the task is under with >, or returns an
+// empty string when Breadcrumbs isn't set.
+func (tasks Tasks) breadcrumbs(task Task) string {
+	if !tasks.Breadcrumbs {
+		return ""
+	}
+	return strings.Join(task.Headers, " > ")
+}
+
+// writeContext writes the task's context lines as a collapsed blockquote
+// indented under the task.
+func writeContext(out *strings.Builder, context []string, indent string) {
+	if len(context) == 0 {
+		return
+	}
+	out.WriteString(fmt.Sprintf("%s<details><summary>context</summary>\n\n", indent))
+	for _, line := range context {
+		out.WriteString(fmt.Sprintf("%s> %s\n", indent, line))
+	}
+	out.WriteString(fmt.Sprintf("\n%s</details>\n", indent))
+}
+
+// WriteMarkdown renders the tasks as markdown grouped under headers.
+func (tasks Tasks) WriteMarkdown(w io.Writer) error {
+	return tasks.WriteMarkdownTemplate(w, "")
+}
+
+// ParseMarkdown reads tasks back from a report written by WriteMarkdown,
+// returning each task's ID, status, text, and source file path, with any
+// header anchor removed and percent-encoding decoded. Wikilinks are read as
+// links to .md files, and editor links as the absolute path they open.
+// Hierarchy and dates aren't recovered.
+func ParseMarkdown(r io.Reader) ([]Task, error) {
+	found := []Task{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		match := reportTaskPattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+
+		id := ""
+		rest := match[2]
+		if idMatch := reportIDPattern.FindStringSubmatchIndex(rest); idMatch != nil {
+			id = rest[idMatch[2]:idMatch[3]]
+			rest = rest[:idMatch[0]] + rest[idMatch[1]:]
+		}
+
+		status := parseStatus(match[1])
+		if task, ok := parseWikilinkTask(rest); ok {
+			task.Complete = status == StatusDone
+			task.Status = status
+			task.ID = id
+			found = append(found, task)
+			continue
+		}
+
+		// the link is the last "[text](path)" on the line, and the text may
+		// itself contain brackets
+		linkEnd := strings.LastIndex(rest, "](")
+		textStart := strings.Index(rest, "[")
+		if linkEnd < 0 || textStart < 0 || textStart > linkEnd {
+			continue
+		}
+		target := rest[linkEnd+2:]
+		if end := strings.Index(target, ")"); end >= 0 {
+			target = target[:end]
+		}
+		if filePath, ok := parseEditorURI(target); ok {
+			target = filePath
+		} else {
+			if anchor := strings.Index(target, "#"); anchor >= 0 {
+				target = target[:anchor]
+			}
+			if unescaped, err := url.PathUnescape(target); err == nil {
+				target = unescaped
+			}
+		}
+
+		found = append(found, Task{
+			Complete: status == StatusDone,
+			FilePath: target,
+			ID:       id,
+			Status:   status,
+			Text:     rest[textStart+1 : linkEnd],
+		})
+	}
+	return found, scanner.Err()
+}
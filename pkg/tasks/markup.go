@@ -0,0 +1,173 @@
+package tasks
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// asciiDocTitlePattern matches a section title, capturing its = marks
+	// and text.
+	asciiDocTitlePattern = regexp.MustCompile(`^(=+)\s+(.*)$`)
+	// asciiDocDelimiterPattern matches the lines opening and closing listing,
+	// literal, passthrough, and comment blocks.
+	asciiDocDelimiterPattern = regexp.MustCompile(`^(?:-{4,}|\.{4,}|\+{4,}|/{4,}|` + "`{3,}" + `)\s*$`)
+	// asciiDocChecklistPattern matches a checklist item, capturing its
+	// indentation, its * or - marks, its check, and its text.
+	asciiDocChecklistPattern = regexp.MustCompile(`^(\s*)(\*+|-)\s+\[([ xX*])\]\s+(.*)$`)
+	// rstAdornmentCharacters are those section titles can be underlined with.
+	rstAdornmentCharacters = "=-`:'\"~^_*+#<>."
+	// rstCodeDirectivePattern matches the directives whose body is code.
+	rstCodeDirectivePattern = regexp.MustCompile(`^\.\.\s+(?:code|code-block|sourcecode)::`)
+	// markdownHeaderPattern matches a line markdown would read as a header,
+	// which the other markups don't.
+	markdownHeaderPattern = regexp.MustCompile(`^(\s*)([#＃])`)
+)
+
+// AsciiDocParser reads AsciiDoc files, registered for .adoc with
+// RegisterParser. Checklist items, as in * [ ] or ** [x], are tasks nested by
+// their marks, under the section titles before them, and listing and literal
+// blocks are skipped as code blocks are in markdown.
+type AsciiDocParser struct{}
+
+// Parse reads the tasks from the AsciiDoc file.
+func (AsciiDocParser) Parse(r io.Reader, meta FileMeta, options ParseOptions) (Tasks, error) {
+	return parseConverted(r, meta, options, asciiDocToMarkdown)
+}
+
+// RSTParser reads reStructuredText files, registered for .rst with
+// RegisterParser. List items with a checkbox, as in - [ ] or * [x], are tasks
+// nested by their indentation, under the section titles before them, and
+// literal blocks and code directives are skipped as code blocks are in
+// markdown.
+type RSTParser struct{}
+
+// Parse reads the tasks from the reStructuredText file.
+func (RSTParser) Parse(r io.Reader, meta FileMeta, options ParseOptions) (Tasks, error) {
+	return parseConverted(r, meta, options, rstToMarkdown)
+}
+
+// parseConverted reads the file's lines, converts them to markdown line for
+// line, so tasks keep their line numbers, and parses the markdown.
+func parseConverted(r io.Reader, meta FileMeta, options ParseOptions, convert func(lines []string) []string) (Tasks, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return Tasks{}, err
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	options.Flavor = FlavorMarkdown
+	return ParseFile(strings.NewReader(strings.Join(convert(lines), "\n")), meta, options)
+}
+
+// escapeHeader keeps a line that isn't a header in the markup from being read
+// as one in markdown.
+func escapeHeader(line string) string {
+	return markdownHeaderPattern.ReplaceAllString(line, `$1\$2`)
+}
+
+// asciiDocToMarkdown converts section titles to headers, delimited blocks to
+// fenced code blocks, and checklist items to markdown tasks.
+func asciiDocToMarkdown(lines []string) []string {
+	converted := make([]string, len(lines))
+	// delimiter is the line that closes the block being read, if any
+	delimiter := ""
+	for i, line := range lines {
+		switch {
+		case delimiter != "":
+			converted[i] = line
+			if strings.TrimSpace(line) == delimiter {
+				converted[i] = "```"
+				delimiter = ""
+			}
+		case asciiDocDelimiterPattern.MatchString(line):
+			delimiter = strings.TrimSpace(line)
+			converted[i] = "```"
+		default:
+			if match := asciiDocTitlePattern.FindStringSubmatch(line); match != nil {
+				converted[i] = strings.Repeat("#", len(match[1])) + " " + match[2]
+			} else if match := asciiDocChecklistPattern.FindStringSubmatch(line); match != nil {
+				depth := len(match[2]) - 1
+				check := match[3]
+				if check == "*" {
+					check = "x"
+				}
+				converted[i] = match[1] + strings.Repeat("  ", depth) + "- [" + check + "] " + match[4]
+			} else {
+				converted[i] = escapeHeader(line)
+			}
+		}
+	}
+	return converted
+}
+
+// rstToMarkdown converts section titles, underlined and perhaps overlined,
+// to headers, with levels in the order their adornments are first used, and
+// blanks literal blocks and code directives, whose text is indented past the
+// line opening them. List items with checkboxes are already markdown
+// tasks.
+func rstToMarkdown(lines []string) []string {
+	converted := make([]string, len(lines))
+	levels := map[string]int{}
+	// literalIndent is the indentation of the line opening the literal block
+	// being read, or -1
+	literalIndent := -1
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if literalIndent >= 0 {
+			if strings.TrimSpace(line) == "" || indentation(line) > literalIndent {
+				continue
+			}
+			literalIndent = -1
+		}
+
+		if overline, ok := rstAdornment(line); ok && i+2 < len(lines) && isRSTTitle(lines[i+1]) && strings.TrimSpace(lines[i+2]) == strings.TrimSpace(line) {
+			converted[i+1] = rstHeader(levels, "over"+overline, lines[i+1])
+			i += 2
+			continue
+		}
+		if i+1 < len(lines) && indentation(line) == 0 && isRSTTitle(line) {
+			if underline, ok := rstAdornment(lines[i+1]); ok {
+				converted[i] = rstHeader(levels, underline, line)
+				i++
+				continue
+			}
+		}
+
+		converted[i] = escapeHeader(line)
+		if trimmed := strings.TrimSpace(line); strings.HasSuffix(trimmed, "::") || rstCodeDirectivePattern.MatchString(trimmed) {
+			literalIndent = indentation(line)
+		}
+	}
+	return converted
+}
+
+// isRSTTitle reports whether the line could be a section title: text that
+// isn't a task or an adornment itself.
+func isRSTTitle(line string) bool {
+	_, adornment := rstAdornment(line)
+	return strings.TrimSpace(line) != "" && !adornment && !taskPattern.MatchString(line)
+}
+
+// rstAdornment returns the character of a line of punctuation over or under a
+// section title, reporting whether the line is one: at least three of the
+// same character.
+func rstAdornment(line string) (string, bool) {
+	line = strings.TrimRightFunc(line, unicode.IsSpace)
+	if len(line) < 3 || !strings.ContainsRune(rstAdornmentCharacters, rune(line[0])) || strings.Trim(line, line[:1]) != "" {
+		return "", false
+	}
+	return line[:1], true
+}
+
+// rstHeader returns the title as a header at the level of its adornment,
+// giving adornments not seen before the next level down.
+func rstHeader(levels map[string]int, adornment, title string) string {
+	level, ok := levels[adornment]
+	if !ok {
+		level = len(levels) + 1
+		levels[adornment] = level
+	}
+	return strings.Repeat("#", min(level, 6)) + " " + strings.TrimSpace(title)
+}
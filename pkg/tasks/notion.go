@@ -0,0 +1,93 @@
+package tasks
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// notionIDPattern matches the ID Notion adds to the end of exported page
+	// and folder names, with or without hyphens.
+	notionIDPattern = regexp.MustCompile(`\s+(?:[0-9a-f]{32}|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+	// notionPropertyPattern matches a property line under a page's title.
+	notionPropertyPattern = regexp.MustCompile(`^(\p{L}[^:]{0,50}):\s+(.+)$`)
+	// notionDatePattern matches the start of a date property in the formats
+	// Notion can be set to write, which may be followed by a time or the end
+	// of a range.
+	notionDatePattern = regexp.MustCompile(`^(\p{Lu}\p{Ll}+ \d{1,2}, \d{4}|\d{4}/\d{2}/\d{2}|\d{4}-\d{2}-\d{2})`)
+)
+
+// notionDateLayouts are the layouts of the dates notionDatePattern matches.
+var notionDateLayouts = []string{"January 2, 2006", "Jan 2, 2006", "2006/01/02", yearMonthDayLayout}
+
+// notionDateKeys are the properties, in order of preference and in any case,
+// that date a page.
+var notionDateKeys = []string{"date", "created", "created time", "date created"}
+
+// notionTitle returns the path of the exported page without the IDs in its
+// folder and file names or its extension, such as Projects/Launch.
+func notionTitle(filePath string) string {
+	segments := strings.Split(strings.TrimSuffix(filePath, path.Ext(filePath)), "/")
+	for i, segment := range segments {
+		segments[i] = notionIDPattern.ReplaceAllString(segment, "")
+	}
+	return strings.Join(segments, "/")
+}
+
+// notionProperties reads the properties Notion writes as "Key: Value" lines
+// between a page's title and its content.
+type notionProperties struct {
+	date   *time.Time
+	done   bool
+	titled bool
+	values map[string]string
+}
+
+// read reports whether the line is one of the page's properties, keeping its
+// value. The properties end at the first other line after the title.
+func (properties *notionProperties) read(line string) bool {
+	if properties.done || strings.TrimSpace(line) == "" {
+		return false
+	}
+	if !properties.titled {
+		properties.titled = headerPattern.MatchString(line)
+		properties.done = !properties.titled
+		return false
+	}
+
+	match := notionPropertyPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		properties.done = true
+		return false
+	}
+	if properties.values == nil {
+		properties.values = map[string]string{}
+	}
+	properties.values[match[1]] = match[2]
+	properties.date = properties.dateProperty()
+	return true
+}
+
+// dateProperty returns the date of the first of notionDateKeys the page has,
+// or nil.
+func (properties *notionProperties) dateProperty() *time.Time {
+	for _, key := range notionDateKeys {
+		for name, value := range properties.values {
+			if !strings.EqualFold(name, key) {
+				continue
+			}
+			match := notionDatePattern.FindStringSubmatch(value)
+			if match == nil {
+				continue
+			}
+			for _, layout := range notionDateLayouts {
+				if date, err := time.Parse(layout, match[1]); err == nil {
+					return &date
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package tasks
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// orgHeadlinePattern matches a headline, capturing its stars, keyword,
+	// priority cookie, title, and :tags:.
+	orgHeadlinePattern = regexp.MustCompile(`^(\*+)\s+(?:(TODO|NEXT|WAITING|HOLD|STARTED|DOING|DONE|CANCELED|CANCELLED)(?:\s+|$))?(?:\[#([A-Z])\]\s*)?(.*?)(?:\s+(:[\p{L}\p{N}_@#%:]+:))?\s*$`)
+	// orgPlanningPattern matches each SCHEDULED:, DEADLINE:, and CLOSED:
+	// timestamp on the line under a headline.
+	orgPlanningPattern = regexp.MustCompile(`(SCHEDULED|DEADLINE|CLOSED):\s*[<\[](\d{4}-\d{2}-\d{2})`)
+	// orgCheckboxPattern matches a plain list item with a checkbox, capturing
+	// its mark and text.
+	orgCheckboxPattern = regexp.MustCompile(`^\s*(?:[-+]|\s\*|\d+[.)])\s+\[([ xX-])\]\s+(.*)$`)
+	// orgTitlePattern matches the #+TITLE: keyword line.
+	orgTitlePattern = regexp.MustCompile(`(?i)^#\+title:\s*(.*?)\s*$`)
+	// orgBlockPattern matches the lines opening and closing blocks such as
+	// #+BEGIN_SRC, capturing whether it opens or closes.
+	orgBlockPattern = regexp.MustCompile(`(?i)^\s*#\+(begin|end)_`)
+)
+
+// orgStatuses are the statuses of the keywords headlines are marked with.
+var orgStatuses = map[string]Status{
+	"TODO":      StatusOpen,
+	"NEXT":      StatusOpen,
+	"WAITING":   StatusOpen,
+	"HOLD":      StatusOpen,
+	"STARTED":   StatusInProgress,
+	"DOING":     StatusInProgress,
+	"DONE":      StatusDone,
+	"CANCELED":  StatusCancelled,
+	"CANCELLED": StatusCancelled,
+}
+
+// orgPriorities are the priorities of the [#A], [#B], and [#C] cookies.
+var orgPriorities = map[string]Priority{"A": PriorityHigh, "B": PriorityMedium, "C": PriorityLow}
+
+// OrgParser reads org-mode files, registered for .org with RegisterParser.
+// Headlines marked with a keyword such as TODO or DONE are tasks, with the
+// dates of the SCHEDULED:, DEADLINE:, and CLOSED: line under them as their
+// start, due, and completion dates, [#A] cookies as their priorities, and
+// :tags: as #tags. Checkboxes are tasks too. Tasks under a task headline are
+// its subtasks, and the other headlines are the headers they're under.
+type OrgParser struct{}
+
+// orgOutline is a headline enclosing the lines after it, until a headline of
+// the same or a higher level, with the index of its task or -1.
+type orgOutline struct {
+	level int
+	task  int
+}
+
+// Parse reads the tasks from the org file.
+func (OrgParser) Parse(r io.Reader, meta FileMeta, options ParseOptions) (Tasks, error) {
+	filePath := meta.DisplayPath
+	if filePath == "" {
+		filePath = meta.Path
+	}
+
+	title := ""
+	outline := []orgOutline{}
+	headers := []heading{}
+	flat := []Task{}
+	parents := []int{}
+	// planned is the index of the task whose planning line may follow, or -1
+	planned := -1
+	inBlock := false
+	lines := newLineReader(r, options.MaxLineLength)
+	longLines := []int{}
+
+	lineNumber := 0
+	for lines.scan() {
+		lineNumber++
+		if lines.tooLong {
+			longLines = append(longLines, lineNumber)
+			continue
+		}
+		line := strings.TrimPrefix(lines.text, "\uFEFF")
+
+		if match := orgBlockPattern.FindStringSubmatch(line); match != nil && !options.IncludeCodeBlocks {
+			inBlock = strings.EqualFold(match[1], "begin")
+			continue
+		}
+		if inBlock {
+			continue
+		}
+		if planned >= 0 && planOrgTask(&flat[planned], line) {
+			planned = -1
+			continue
+		}
+		planned = -1
+		if match := orgTitlePattern.FindStringSubmatch(line); match != nil && title == "" {
+			title = match[1]
+			continue
+		}
+
+		var task *Task
+		level := 0
+		if match := orgHeadlinePattern.FindStringSubmatch(line); match != nil {
+			level = len(match[1])
+			for len(outline) > 0 && outline[len(outline)-1].level >= level {
+				outline = outline[:len(outline)-1]
+			}
+			for len(headers) > 0 && headers[len(headers)-1].level >= level {
+				headers = headers[:len(headers)-1]
+			}
+			if match[2] == "" || match[4] == "" {
+				headers = append(append([]heading{}, headers...), heading{level: level, text: match[4]})
+				outline = append(outline, orgOutline{level: level, task: -1})
+				continue
+			}
+			task = parseOrgHeadline(match)
+		} else if match := orgCheckboxPattern.FindStringSubmatch(line); match != nil {
+			task = parseOrgCheckbox(match)
+		} else {
+			continue
+		}
+
+		task.FilePath = filePath
+		task.FileTitle = title
+		for _, header := range headers {
+			task.Headers = append(task.Headers, header.text)
+		}
+		if len(headers) > 0 {
+			task.PreviousHeader = headers[len(headers)-1].text
+		}
+		task.Line = lineNumber
+		if date, ok := meta.LineDates[lineNumber]; ok && meta.Date == nil {
+			task.Date = date
+		}
+
+		parent := -1
+		for i := len(outline) - 1; i >= 0; i-- {
+			if outline[i].task >= 0 {
+				parent = outline[i].task
+				break
+			}
+		}
+		if level > 0 {
+			outline = append(outline, orgOutline{level: level, task: len(flat)})
+			planned = len(flat)
+		}
+		flat = append(flat, *task)
+		parents = append(parents, parent)
+	}
+
+	tasks := Tasks{Tasks: nestTasks(flat, parents)}
+	if lines.err != nil {
+		return tasks, lines.err
+	}
+	if len(longLines) > 0 {
+		return tasks, &LongLinesError{Lines: longLines}
+	}
+	return tasks, nil
+}
+
+// parseOrgHeadline reads a headline matched by orgHeadlinePattern as a task
+// with its keyword's status.
+func parseOrgHeadline(match []string) *Task {
+	text := match[4]
+	priority := parsePriority(text)
+	if match[3] != "" {
+		priority = orgPriorities[match[3]]
+	}
+	tags := parseTags(text)
+	for _, tag := range strings.Split(strings.Trim(match[5], ":"), ":") {
+		if tag == "" {
+			continue
+		}
+		if !strings.HasPrefix(tag, "@") && !strings.HasPrefix(tag, "#") {
+			tag = "#" + tag
+		}
+		tags = append(tags, tag)
+	}
+	status := orgStatuses[match[2]]
+	return &Task{
+		Complete:   status == StatusDone,
+		Estimate:   parseEstimate(text),
+		Fields:     parseFields(text),
+		Priority:   priority,
+		Recurrence: parseRecurrence(text),
+		Status:     status,
+		Tags:       tags,
+		Text:       text,
+	}
+}
+
+// parseOrgCheckbox reads a checkbox matched by orgCheckboxPattern as a task,
+// where org's [-] marks one partly done.
+func parseOrgCheckbox(match []string) *Task {
+	status := parseStatus(match[1])
+	if match[1] == "-" {
+		status = StatusInProgress
+	}
+	text := strings.TrimSpace(match[2])
+	return &Task{
+		Complete:    status == StatusDone,
+		CompletedAt: parseDate(completedPattern, text, nil),
+		Due:         parseDate(duePattern, text, nil),
+		Estimate:    parseEstimate(text),
+		Fields:      parseFields(text),
+		Priority:    parsePriority(text),
+		Recurrence:  parseRecurrence(text),
+		Start:       parseDate(startPattern, text, nil),
+		Status:      status,
+		Tags:        parseTags(text),
+		Text:        text,
+	}
+}
+
+// planOrgTask sets the task's start, due, and completion dates from the
+// timestamps of a planning line, reporting whether the line was one.
+func planOrgTask(task *Task, line string) bool {
+	matches := orgPlanningPattern.FindAllStringSubmatch(line, -1)
+	if matches == nil {
+		return false
+	}
+	for _, match := range matches {
+		date, err := time.Parse(yearMonthDayLayout, match[2])
+		if err != nil {
+			continue
+		}
+		switch match[1] {
+		case "SCHEDULED":
+			task.Start = &date
+		case "DEADLINE":
+			task.Due = &date
+		case "CLOSED":
+			task.CompletedAt = &date
+		}
+	}
+	return true
+}
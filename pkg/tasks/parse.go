@@ -0,0 +1,553 @@
+package tasks
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// headerMarks matches the #s starting a header and the space after them. Like
+// the patterns below, it accepts the non-breaking and full-width spaces and
+// full-width marks that notes exported from Word, Notion, and editors set up
+// for CJK text can hold.
+const headerMarks = `[#＃]+[\s\p{Zs}]+`
+
+// The patterns are compiled once, since they are matched against every line
+// of every file.
+var (
+	completedPattern = regexp.MustCompile(`(?i)(?:✅\s*|\bdone:\s*|\[completion::\s*)(\d{4}-\d{2}-\d{2})`)
+	duePattern       = regexp.MustCompile(`(?i)(?:📅\s*|\bdue:\s*|\[due::\s*)(\d{4}-\d{2}-\d{2})`)
+	headerPattern    = regexp.MustCompile(`^[\s\p{Zs}]*` + headerMarks)
+	listItemPattern  = regexp.MustCompile(`^[\s\p{Zs}]*(?:[-+*－＋＊]|\d+[.)])[\s\p{Zs}]`)
+	startPattern     = regexp.MustCompile(`(?i)(?:🛫\s*|\bstart:\s*|\[start::\s*)(\d{4}-\d{2}-\d{2})`)
+	taskPattern      = regexp.MustCompile(`^[\s\p{Zs}]*[-|+*－＋＊]?[\s\p{Zs}]*[\[［]([\s\p{Zs}]+|[xXｘＸ/>?-])[\]］]`)
+	tagPattern       = regexp.MustCompile(`(?:^|\s)([#@][\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*)`)
+)
+
+// ParseOptions controls what is kept about each task while parsing.
+type ParseOptions struct {
+	// IncludeCodeBlocks reads tasks and headers inside fenced code blocks,
+	// which are skipped otherwise.
+	IncludeCodeBlocks bool
+	// Context is the number of lines before and after each task to keep as its
+	// context, or zero for none. Nested tasks under a plain list item keep that
+	// item instead.
+	Context int
+	// DateFrom is how ParseFilePath dates tasks that no header, front matter,
+	// or file name dates, one of DateFromOptions. The zero value dates them by
+	// when the file was created.
+	DateFrom string
+	// FilenameDateFormats are the formats of the dates file names can start
+	// with besides 2024-03-01 and 2024-W10, written with % directives as in
+	// %d-%m-%Y, with letters as in YYYYMMDD, or as Go layouts as in
+	// January 2, 2006.
+	FilenameDateFormats []string
+	// DateSources, when set, date each task by the first of the sources,
+	// of DateSourceOptions, that gives it a date, in place of DateFrom and
+	// the file's own Date. Sources not listed are never used.
+	DateSources []string
+	// Flavor is the kind of markdown the notes are, one of FlavorOptions. The
+	// zero value reads them as FlavorMarkdown.
+	Flavor string
+	// Lenient reads near-miss checkboxes, as in - [], - [ x ], or - [X ], as
+	// tasks, as NormalizeCheckbox writes them.
+	Lenient bool
+	// MaxLineLength is the longest line, in bytes, that is read. Longer lines
+	// are skipped and listed in a *LongLinesError. Zero reads lines of up to
+	// DefaultMaxLineLength.
+	MaxLineLength int
+	// TodoKeywords are keywords, such as DefaultTodoKeywords, that make lines
+	// like "TODO: call bob" incomplete tasks tagged with the keyword. Lines
+	// aren't read this way when empty.
+	TodoKeywords []string
+}
+
+// ParseFile reads markdown from r and returns the tasks found in it, dated by
+// the most recent date header or else by the file's date. A YAML front matter
+// block opening the file can set the file's date, with a date: or created:
+// field, and title, and its fields are attached to each task as properties.
+// Tasks that neither dates are dated by their line's LineDates when it has one.
+// With FlavorLogseq, blocks starting with a task keyword are tasks too, and
+// journals are dated by their names. With FlavorNotion, the properties under
+// a Notion page's title take the place of front matter. Lines longer than the
+// options allow are skipped, and the tasks found on the others are returned
+// with a *LongLinesError listing them.
+func ParseFile(r io.Reader, meta FileMeta, options ParseOptions) (Tasks, error) {
+	tasks := Tasks{Tasks: []Task{}}
+
+	filePath := meta.DisplayPath
+	if filePath == "" {
+		filePath = meta.Path
+	}
+
+	date := meta.Date
+	if nameDate := options.fileNameDate(meta.Name); nameDate != nil && len(options.FilenameDateFormats) > 0 {
+		date = nameDate
+	}
+	// dated is set once a header or the front matter dates the tasks after it
+	dated := false
+	logseq := options.Flavor == FlavorLogseq
+	if journalDate := logseqJournalDate(meta.Name); logseq && journalDate != nil {
+		date = journalDate
+	}
+	// candidates are the dates each of DateSources gives the task on the
+	// line being parsed
+	candidates := fileDateCandidates(meta, options)
+	// logseqTask is the index of the Logseq task whose SCHEDULED: and
+	// DEADLINE: lines may follow, or -1
+	logseqTask := -1
+	lastHeader := ""
+	headers := []heading{}
+	headerOccurrence := 0
+	headerSlugs := map[string]int{}
+	lines := newLineReader(r, options.MaxLineLength)
+	longLines := []int{}
+
+	// Tasks are collected in file order along with the index of their parent
+	// task, then assembled into a hierarchy once the whole file is read.
+	flat := []Task{}
+	parents := []int{}
+	open := []openTask{}
+	fileMatter := frontMatter{}
+	var notion *notionProperties
+	if options.Flavor == FlavorNotion {
+		fileMatter.Title = notionTitle(filePath)
+		notion = &notionProperties{}
+	}
+
+	// context is gathered from the non-blank lines that aren't tasks: those
+	// already read are held in recent, while tasks in awaiting still need the
+	// lines after them
+	recent := []string{}
+	awaiting := []awaitingContext{}
+	bullets := []listItem{}
+	fence := ""
+	var todos *regexp.Regexp
+	if len(options.TodoKeywords) > 0 {
+		todos = todoPattern(options.TodoKeywords)
+	}
+
+	parseLine := func(lineNumber int, line string) {
+		if !options.IncludeCodeBlocks {
+			marker, info := codeFence(line)
+			if fence == "" && marker != "" {
+				fence = marker
+				return
+			}
+			if fence != "" {
+				if marker != "" && marker[0] == fence[0] && len(marker) >= len(fence) && info == "" {
+					fence = ""
+				}
+				return
+			}
+		}
+
+		if options.Lenient {
+			line, _ = NormalizeCheckbox(line)
+		}
+
+		if notion != nil && notion.read(line) {
+			fileMatter.Properties = notion.values
+			if notion.date != nil && fileMatter.Date == nil {
+				date = notion.date
+				dated = true
+				candidates.frontMatter = notion.date
+			}
+			return
+		}
+
+		if logseq {
+			if logseqTask >= 0 && planLogseqTask(&flat[logseqTask], line) {
+				return
+			}
+			if strings.TrimSpace(line) != "" && !logseqPropertyPattern.MatchString(line) {
+				logseqTask = -1
+			}
+		}
+
+		text := strings.TrimSpace(line)
+		isContext := options.Context > 0 && text != "" && !taskPattern.MatchString(line)
+		if isContext {
+			remaining := awaiting[:0]
+			for _, waiting := range awaiting {
+				flat[waiting.index].Context = append(flat[waiting.index].Context, text)
+				if waiting.lines > 1 {
+					remaining = append(remaining, awaitingContext{index: waiting.index, lines: waiting.lines - 1})
+				}
+			}
+			awaiting = remaining
+			recent = append(recent, text)
+			if len(recent) > options.Context {
+				recent = recent[1:]
+			}
+		}
+		if options.Context > 0 {
+			bullets = parseListItem(line, bullets)
+		}
+
+		if headerDate := parseDateFormats(headerDateFormats, line, nil); headerDate != nil {
+			date = headerDate
+			dated = true
+			candidates.header = headerDate
+		}
+		if header := parseLastHeader(line, ""); header != "" {
+			slug := githubSlug(header)
+			headerOccurrence = headerSlugs[slug]
+			headerSlugs[slug]++
+		}
+		lastHeader = parseLastHeader(line, lastHeader)
+		headers = parseHeadings(line, headers)
+
+		// a file with no date of its own leaves its tasks undated until a
+		// header dates them
+		taskDate := time.Time{}
+		if date != nil {
+			taskDate = *date
+		}
+		if lineDate, ok := meta.LineDates[lineNumber]; ok && !dated {
+			taskDate = lineDate
+		}
+		if len(options.DateSources) > 0 {
+			candidates.git = nil
+			if lineDate, ok := meta.LineDates[lineNumber]; ok {
+				candidates.git = &lineDate
+			}
+			taskDate = candidates.first(options.DateSources)
+		}
+		task, isTask := parseTask(taskDate, lastHeader, filePath, line)
+		if !isTask && logseq {
+			task, isTask = parseLogseqTask(taskDate, lastHeader, filePath, line)
+		}
+		if !isTask && todos != nil {
+			task, isTask = parseTodo(todos, taskDate, lastHeader, filePath, line)
+		}
+		if !isTask {
+			// a header or unindented text ends any list of nested tasks
+			isHeader := headerPattern.MatchString(line)
+			if isHeader || (strings.TrimSpace(line) != "" && indentation(line) == 0) {
+				open = open[:0]
+			}
+			return
+		}
+
+		task.Assignees = parseAssignees(task.Tags)
+		task.FileTitle = fileMatter.Title
+		for _, header := range headers {
+			task.Headers = append(task.Headers, header.text)
+		}
+		task.HeaderOccurrence = headerOccurrence
+		task.Line = lineNumber
+		task.ID = taskID(filePath, lineNumber, task.Text)
+		task.Properties = fileMatter.Properties
+		indent := indentation(line)
+		for len(open) > 0 && open[len(open)-1].indent >= indent {
+			open = open[:len(open)-1]
+		}
+		parent := -1
+		if len(open) > 0 {
+			parent = open[len(open)-1].index
+		}
+		if options.Context > 0 {
+			if bullet, ok := parentListItem(bullets, indent); ok && !bullet.task {
+				task.Context = []string{bullet.text}
+			} else {
+				task.Context = append([]string{}, recent...)
+				awaiting = append(awaiting, awaitingContext{index: len(flat), lines: options.Context})
+			}
+		}
+		if logseq {
+			logseqTask = len(flat)
+		}
+		open = append(open, openTask{index: len(flat), indent: indent})
+		flat = append(flat, *task)
+		parents = append(parents, parent)
+	}
+
+	// front matter lines are held until the block closes, and parsed as
+	// ordinary lines if it never does or isn't YAML
+	var frontMatterLines []string
+	lineNumber := 0
+	for lines.scan() {
+		lineNumber++
+		if lines.tooLong {
+			longLines = append(longLines, lineNumber)
+			continue
+		}
+		line := lines.text
+		if lineNumber == 1 {
+			// editors on Windows can start files with a byte order mark
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+		if lineNumber == 1 && strings.TrimSpace(line) == frontMatterDelimiter {
+			frontMatterLines = []string{}
+			continue
+		}
+		if frontMatterLines != nil {
+			if strings.TrimSpace(line) != frontMatterDelimiter {
+				frontMatterLines = append(frontMatterLines, line)
+				continue
+			}
+			if parsed, ok := parseFrontMatter(frontMatterLines); ok {
+				if parsed.Title == "" {
+					parsed.Title = fileMatter.Title
+				}
+				fileMatter = parsed
+				if fileMatter.Date != nil {
+					date = fileMatter.Date
+					dated = true
+					candidates.frontMatter = fileMatter.Date
+				}
+			} else {
+				replayLines(frontMatterLines, parseLine)
+				parseLine(lineNumber, line)
+			}
+			frontMatterLines = nil
+			continue
+		}
+		parseLine(lineNumber, line)
+	}
+	if frontMatterLines != nil {
+		replayLines(frontMatterLines, parseLine)
+	}
+
+	tasks.Tasks = nestTasks(flat, parents)
+
+	if lines.err != nil {
+		return tasks, lines.err
+	}
+	if len(longLines) > 0 {
+		return tasks, &LongLinesError{Lines: longLines}
+	}
+	return tasks, nil
+}
+
+// replayLines parses lines held back as possible front matter, which start on
+// the file's second line.
+func replayLines(lines []string, parseLine func(lineNumber int, line string)) {
+	parseLine(1, frontMatterDelimiter)
+	for i, line := range lines {
+		parseLine(i+2, line)
+	}
+}
+
+// codeFence returns the run of backticks or tildes opening or closing a fenced
+// code block on the line, and the text after it, or an empty marker if the
+// line isn't a fence.
+func codeFence(line string) (marker, info string) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return "", ""
+	}
+	end := 0
+	for end < len(trimmed) && trimmed[end] == trimmed[0] {
+		end++
+	}
+	info = strings.TrimSpace(trimmed[end:])
+	if end < 3 || (trimmed[0] == '`' && strings.Contains(info, "`")) {
+		return "", ""
+	}
+	return trimmed[:end], info
+}
+
+// awaitingContext is a task still collecting the lines after it as context.
+type awaitingContext struct {
+	index int
+	lines int
+}
+
+// listItem is a list item that may have more deeply indented items nested
+// under it.
+type listItem struct {
+	indent int
+	task   bool
+	text   string
+}
+
+// parseListItem updates the stack of list items enclosing the line. A header
+// or unindented text ends the list.
+func parseListItem(line string, items []listItem) []listItem {
+	if strings.TrimSpace(line) == "" {
+		return items
+	}
+	indent := indentation(line)
+	if !listItemPattern.MatchString(line) {
+		if indent == 0 || headerPattern.MatchString(line) {
+			return items[:0]
+		}
+		return items
+	}
+
+	for len(items) > 0 && items[len(items)-1].indent >= indent {
+		items = items[:len(items)-1]
+	}
+	isTask := taskPattern.MatchString(line)
+	return append(items, listItem{indent: indent, task: isTask, text: strings.TrimSpace(line)})
+}
+
+// parentListItem returns the item the list item at indent is nested under.
+// The stack already holds the item itself.
+func parentListItem(items []listItem, indent int) (listItem, bool) {
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].indent < indent {
+			return items[i], true
+		}
+	}
+	return listItem{}, false
+}
+
+// openTask is a task that may still receive more deeply indented subtasks.
+type openTask struct {
+	index  int
+	indent int
+}
+
+// indentation returns the width of the line's leading whitespace, counting
+// tabs as four spaces and other spaces, such as non-breaking ones, as one.
+func indentation(line string) int {
+	width := 0
+	for _, c := range line {
+		switch {
+		case c == '\t':
+			width += 4
+		case unicode.IsSpace(c):
+			width++
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// nestTasks attaches each task to its parent, returning the top-level tasks.
+// A parent index of -1 marks a top-level task.
+func nestTasks(flat []Task, parents []int) []Task {
+	children := make([][]int, len(flat))
+	topLevel := []int{}
+	for i, parent := range parents {
+		if parent < 0 {
+			topLevel = append(topLevel, i)
+		} else {
+			children[parent] = append(children[parent], i)
+		}
+	}
+
+	var build func(i int) Task
+	build = func(i int) Task {
+		task := flat[i]
+		for _, child := range children[i] {
+			task.Subtasks = append(task.Subtasks, build(child))
+		}
+		return task
+	}
+
+	nested := []Task{}
+	for _, i := range topLevel {
+		nested = append(nested, build(i))
+	}
+	return nested
+}
+
+func parseDate(pattern *regexp.Regexp, text string, lastDate *time.Time) *time.Time {
+	match := pattern.FindStringSubmatch(text)
+	if len(match) == 2 {
+		parsedDate, err := time.Parse(yearMonthDayLayout, match[1])
+		if err != nil {
+			return lastDate
+		}
+		return &parsedDate
+	}
+
+	return lastDate
+}
+
+func parseLastHeader(line, lastHeader string) string {
+	isHeader := headerPattern.MatchString(line)
+	if isHeader {
+		return strings.TrimLeftFunc(line, isHeaderMark)
+	}
+	return lastHeader
+
+}
+
+// isHeaderMark reports whether the character is one of the #s or spaces
+// before a header's text.
+func isHeaderMark(c rune) bool {
+	return c == '#' || c == '＃' || unicode.IsSpace(c)
+}
+
+// heading is a header enclosing the lines after it, until a header of the same
+// or a higher level.
+type heading struct {
+	level int
+	text  string
+}
+
+// parseHeadings updates the stack of headings enclosing the line when it is a
+// header, replacing those at its level or below.
+func parseHeadings(line string, headings []heading) []heading {
+	if !headerPattern.MatchString(line) {
+		return headings
+	}
+	level := 0
+	for _, c := range strings.TrimLeftFunc(line, unicode.IsSpace) {
+		if c != '#' && c != '＃' {
+			break
+		}
+		level++
+	}
+	for len(headings) > 0 && headings[len(headings)-1].level >= level {
+		headings = headings[:len(headings)-1]
+	}
+	// the stack is copied so tasks holding earlier headings keep them
+	return append(append([]heading{}, headings...), heading{level: level, text: parseLastHeader(line, "")})
+}
+
+// parseAssignees returns the names of the @tags, which mention the people a
+// task is assigned to.
+func parseAssignees(tags []string) []string {
+	var assignees []string
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, "@") {
+			assignees = append(assignees, tag[1:])
+		}
+	}
+	return assignees
+}
+
+// parseTags finds inline #tags and @tags, ignoring purely numeric ones such
+// as issue references.
+func parseTags(text string) []string {
+	tags := []string{}
+	for _, match := range tagPattern.FindAllStringSubmatch(text, -1) {
+		tags = append(tags, match[1])
+	}
+	return tags
+}
+
+func parseTask(date time.Time, lastHeader, filePath, line string) (*Task, bool) {
+	if match := taskPattern.FindStringSubmatchIndex(line); match != nil {
+		status := parseStatus(line[match[2]:match[3]])
+		text := strings.TrimSpace(line[match[1]:])
+		return &Task{
+			Complete:       status == StatusDone,
+			CompletedAt:    parseDate(completedPattern, text, nil),
+			Date:           date,
+			Due:            parseDate(duePattern, text, nil),
+			Estimate:       parseEstimate(text),
+			Fields:         parseFields(text),
+			FilePath:       filePath,
+			PreviousHeader: lastHeader,
+			Priority:       parsePriority(text),
+			Recurrence:     parseRecurrence(text),
+			Start:          parseDate(startPattern, text, nil),
+			Status:         status,
+			Tags:           parseTags(text),
+			Text:           text,
+		}, true
+
+	}
+
+	return nil, false
+}
@@ -0,0 +1,632 @@
+package tasks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchmarkNote is a daily note mixing the task syntax the parser recognizes
+// with ordinary prose.
+const benchmarkNote = `# 2024-03-01
+
+Some notes from the standup, nothing actionable here.
+
+## Work
+
+- [ ] call bob #work @phone 📅 2024-03-04
+- [x] ship the release ✅ 2024-03-01
+	- [ ] write the changelog !2
+	- [x] tag the build
+- [ ] (A) review budget due: 2024-03-15 🔁 every month
+- plain bullet that isn't a task
+
+## Home
+
+- [ ] buy milk @home
+- [ ] fix #123 bug ⏫
+`
+
+// TestParseFileExports checks that notes exported from other tools, with byte
+// order marks, CRLF line endings, non-breaking spaces, and full-width marks,
+// are read like any other note.
+func TestParseFileExports(t *testing.T) {
+	type parsedTask struct {
+		depth  int
+		header string
+		status Status
+		text   string
+	}
+	tests := []struct {
+		filename string
+		want     []parsedTask
+	}{
+		{"windows.md", []parsedTask{
+			{0, "2024-03-01", StatusOpen, "call bob"},
+			{0, "2024-03-01", StatusDone, "ship it"},
+			{1, "2024-03-01", StatusOpen, "write changelog"},
+		}},
+		{"word.md", []parsedTask{
+			{0, "Meeting notes", StatusOpen, "send minutes"},
+			{1, "Meeting notes", StatusDone, "draft agenda"},
+			{0, "Meeting notes", StatusDone, "book room"},
+		}},
+		{"fullwidth.md", []parsedTask{
+			{0, "買い物", StatusOpen, "牛乳を買う"},
+			{1, "買い物", StatusDone, "卵を買う"},
+			{0, "買い物", StatusDone, "パン"},
+		}},
+	}
+
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.filename, func(t *testing.T) {
+			meta := FileMeta{Date: &date, Name: test.filename, Path: filepath.Join("testdata", test.filename)}
+			parsed, err := ParseFilePath(meta, ParseOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []parsedTask{}
+			var walk func(all []Task, depth int)
+			walk = func(all []Task, depth int) {
+				for _, task := range all {
+					got = append(got, parsedTask{depth, task.PreviousHeader, task.Status, task.Text})
+					walk(task.Subtasks, depth+1)
+				}
+			}
+			walk(parsed.Tasks, 0)
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestParseFileUndated checks that a file with no date of its own leaves the
+// tasks before its first date header undated rather than failing, and that
+// they're sectioned where Undated puts them.
+func TestParseFileUndated(t *testing.T) {
+	note := "- [ ] call bob\n\n# 2024-03-01\n\n- [ ] ship it\n"
+	parsed, err := ParseFile(strings.NewReader(note), FileMeta{DisplayPath: "inbox.md"}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Tasks) != 2 || !parsed.Tasks[0].Undated() || parsed.Tasks[1].Undated() {
+		t.Fatalf("got %+v, want the first of two tasks undated", parsed.Tasks)
+	}
+
+	for _, test := range []struct {
+		undated string
+		want    []string
+	}{
+		{"", []string{"2024-03-01", noDateTitle}},
+		{UndatedFirst, []string{noDateTitle, "2024-03-01"}},
+		{UndatedSkip, []string{"2024-03-01"}},
+	} {
+		parsed.Undated = test.undated
+		got := []string{}
+		for _, group := range parsed.Groups() {
+			got = append(got, group.Title)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("undated %q: got sections %v, want %v", test.undated, got, test.want)
+		}
+	}
+}
+
+func TestOrgParser(t *testing.T) {
+	note := "* Work\n** TODO [#A] Ship it :release:\n   DEADLINE: <2024-03-08 Fri>\n   - [X] tag build\n* DONE Call bob\n"
+	parsed, err := OrgParser{}.Parse(strings.NewReader(note), FileMeta{DisplayPath: "plans.org"}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Tasks) != 2 {
+		t.Fatalf("got %+v, want 2 tasks", parsed.Tasks)
+	}
+	ship := parsed.Tasks[0]
+	if ship.Text != "Ship it" || ship.Priority != PriorityHigh || ship.Due == nil || ship.Due.Format(yearMonthDayLayout) != "2024-03-08" || ship.PreviousHeader != "Work" || fmt.Sprint(ship.Tags) != "[#release]" {
+		t.Errorf("got %+v, want Ship it under Work, high priority, due 2024-03-08, tagged #release", ship)
+	}
+	if len(ship.Subtasks) != 1 || !ship.Subtasks[0].Complete {
+		t.Errorf("got subtasks %+v, want the completed checkbox", ship.Subtasks)
+	}
+	if call := parsed.Tasks[1]; call.Status != StatusDone || call.PreviousHeader != "" {
+		t.Errorf("got %+v, want Call bob done outside Work", call)
+	}
+}
+
+func TestMarkupParsers(t *testing.T) {
+	for _, test := range []struct {
+		parser Parser
+		note   string
+		line   int
+	}{
+		{AsciiDocParser{}, "== 2024-03-01\n* [ ] ship it\n** [*] tag build\n----\n* [ ] in a listing\n----\n", 2},
+		{RSTParser{}, "2024-03-01\n==========\n\n- [ ] ship it\n  - [x] tag build\n\nExample::\n\n  - [ ] in a literal block\n", 4},
+	} {
+		parsed, err := test.parser.Parse(strings.NewReader(test.note), FileMeta{DisplayPath: "notes"}, ParseOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parsed.Tasks) != 1 || parsed.Tasks[0].Date.Format(yearMonthDayLayout) != "2024-03-01" || parsed.Tasks[0].Line != test.line {
+			t.Fatalf("%T: got %+v, want ship it on line %d, dated 2024-03-01", test.parser, parsed.Tasks, test.line)
+		}
+		if subtasks := parsed.Tasks[0].Subtasks; len(subtasks) != 1 || !subtasks[0].Complete {
+			t.Errorf("%T: got subtasks %+v, want the completed tag build", test.parser, subtasks)
+		}
+	}
+}
+
+func TestSourceParser(t *testing.T) {
+	source := "package main\n\n// TODO(ann): handle errors\nfunc main() {\n\tx := 1 // FIXME overflow\n\t_ = \"a#TODO b\"\n}\n"
+	parsed, err := SourceParser{}.Parse(strings.NewReader(source), FileMeta{DisplayPath: "main.go"}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, task := range parsed.Tasks {
+		got = append(got, fmt.Sprintf("%d %s %v", task.Line, task.Text, task.Tags))
+	}
+	want := []string{"3 handle errors [#TODO @ann]", "5 overflow [#FIXME]"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestGroupsProgress(t *testing.T) {
+	note := "# 2024-03-01\n- [x] a\n- [ ] b\n  - [x] b1\n# 2024-03-02\n- [x] c\n"
+	parsed, err := ParseFile(strings.NewReader(note), FileMeta{DisplayPath: "notes.md"}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := parsed.Groups()
+	if len(groups) != 1 || len(groups[0].Tasks) != 1 || groups[0].Stats.ProgressBar() != "**2/3 complete** ▓▓▓▓▓▓░░░░" {
+		t.Errorf("got %+v, want one section with b shown and 2/3 complete", groups)
+	}
+}
+
+func TestTableLayout(t *testing.T) {
+	parsed, err := ParseFile(strings.NewReader("# 2024-03-01\n- [ ] ship | deploy #ops\n"), FileMeta{DisplayPath: "notes.md"}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.Layout = LayoutTable
+	want := "| ⬜ | 2024-03-01 | [ship \\| deploy #ops](notes.md#2024-03-01) <!-- id:" + parsed.Tasks[0].ID + " --> | notes.md:2 | #ops |\n"
+	if got := parsed.String(); !strings.Contains(got, "| Status | Date | Task | Source | Tags |\n") || !strings.Contains(got, want) {
+		t.Errorf("got %q, want a table with the row %q", got, want)
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	high, low := Task{FilePath: "b.md", Line: 2, Priority: PriorityHigh, Text: "high"}, Task{FilePath: "b.md", Line: 1, Priority: PriorityLowest, Text: "low"}
+	plain, nested := Task{FilePath: "notes-old.md", Line: 1, Text: "plain"}, Task{FilePath: "notes/a.md", Line: 1, Text: "nested"}
+	for _, test := range []struct {
+		spec string
+		want []string
+	}{
+		{"priority", []string{"high", "nested", "plain", "low"}},
+		{"priority:asc", []string{"low", "nested", "plain", "high"}},
+		{"text:desc", []string{"plain", "nested", "low", "high"}},
+		{"date", []string{"low", "high", "nested", "plain"}},
+	} {
+		keys, err := ParseSort(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sorted := Tasks{Tasks: []Task{plain, high, nested, low}}
+		sorted.SortBy(keys)
+		got := []string{}
+		for _, task := range sorted.Tasks {
+			got = append(got, task.Text)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("sort %s: got %v, want %v", test.spec, got, test.want)
+		}
+	}
+	if _, err := ParseSort("due:up"); err == nil {
+		t.Error("got no error for the direction up")
+	}
+}
+
+func TestRecent(t *testing.T) {
+	note := "# 2024-03-01\n- [ ] old\n# 2024-03-03\n- [ ] new\n# 2024-03-02\n- [ ] middle\n- [x] done\n"
+	parsed, err := ParseFile(strings.NewReader(note), FileMeta{DisplayPath: "notes.md"}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		since   *time.Time
+		limit   int
+		want    []string
+		omitted int
+	}{
+		{nil, 2, []string{"new", "middle"}, 1},
+		{&since, 0, []string{"new", "middle"}, 1},
+		{&since, 1, []string{"new"}, 2},
+		{nil, 0, []string{"old", "new", "middle"}, 0},
+	} {
+		recent := parsed.Recent(test.since, test.limit)
+		got := []string{}
+		for _, task := range recent.Visible() {
+			got = append(got, task.Text)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) || recent.Omitted != test.omitted {
+			t.Errorf("since %v, limit %d: got %v with %d omitted, want %v with %d", test.since, test.limit, got, recent.Omitted, test.want, test.omitted)
+		}
+	}
+
+	// completed tasks that aren't output still count toward progress
+	recent := parsed.Recent(nil, 1)
+	if completed := recent.CompletedCount(); completed != 1 {
+		t.Errorf("limit 1: got %d completed, want the hidden task counted", completed)
+	}
+	var report strings.Builder
+	if err := recent.WriteMarkdown(&report); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(report.String(), "done") {
+		t.Errorf("limit 1 output a hidden task:\n%s", report.String())
+	}
+}
+
+func TestLint(t *testing.T) {
+	note := "# 2024-01-01\n- [ ] old\n- [ ] Old\n- [] empty\n- [ x] shifted\n```\n- [] code\n```\n# 2024-03-30\n- [ ] new\n- [-] old\n"
+	parsed, err := ParseFile(strings.NewReader(note), FileMeta{DisplayPath: "notes.md"}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := parsed.Lint(LintOptions{MaxAgeDays: 30, MaxOpen: 2, Now: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)})
+	malformed, err := LintCheckboxes(strings.NewReader(note), "notes.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings = append(findings, malformed...)
+	SortFindings(findings)
+
+	got := []string{}
+	for _, finding := range findings {
+		got = append(got, fmt.Sprintf("%d %s", finding.Line, finding.Check))
+	}
+	want := []string{"0 " + LintOpen, "2 " + LintStale, "3 " + LintStale, "3 " + LintDuplicate, "4 " + LintMalformed, "5 " + LintMalformed}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got findings %v, want %v", got, want)
+	}
+}
+
+func TestLenient(t *testing.T) {
+	for line, want := range map[string]string{
+		"- [] empty":        "- [ ] empty",
+		"- [ x ] spaced":    "- [x] spaced",
+		"  - [X ] trailing": "  - [X] trailing",
+		"*[x] tight":        "* [x] tight",
+		"- [ ] fine":        "- [ ] fine",
+		"- [link](url)":     "- [link](url)",
+	} {
+		if got, _ := NormalizeCheckbox(line); got != want {
+			t.Errorf("NormalizeCheckbox(%q) = %q, want %q", line, got, want)
+		}
+	}
+
+	note := "- [] empty\n- [ x ] spaced\n- [link](url)\n"
+	for _, lenient := range []bool{false, true} {
+		parsed, err := ParseFile(strings.NewReader(note), FileMeta{}, ParseOptions{Lenient: lenient})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, task := range parsed.Tasks {
+			got = append(got, fmt.Sprintf("%s %v", task.Text, task.Complete))
+		}
+		want := []string{}
+		if lenient {
+			want = []string{"empty false", "spaced true"}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("lenient %v: got tasks %v, want %v", lenient, got, want)
+		}
+	}
+}
+
+func TestFormatLine(t *testing.T) {
+	for _, test := range []struct {
+		line  string
+		style FormatStyle
+		want  string
+	}{
+		{"* [X]   call #bob  ", FormatStyle{Bullet: "-"}, "- [x] call #bob"},
+		{"  - [] ship 📅 2024-03-01 #work", FormatStyle{Dates: DateStyleDataview}, "  - [ ] ship [due:: 2024-03-01] #work"},
+		{"- [x] ship [completion:: 2024-03-02 ] [start:: 2024-03-01]", FormatStyle{Dates: DateStyleEmoji}, "- [x] ship ✅ 2024-03-02 🛫 2024-03-01"},
+		{"- [ ] #work call bob due: 2024-03-01", FormatStyle{TagsLast: true}, "- [ ] call bob due: 2024-03-01 #work"},
+		{"- [ ] #work", FormatStyle{TagsLast: true}, "- [ ] #work"},
+		{"- plain item  ", FormatStyle{Bullet: "*"}, "- plain item  "},
+	} {
+		if got := FormatLine(test.line, test.style); got != test.want {
+			t.Errorf("FormatLine(%q, %+v) = %q, want %q", test.line, test.style, got, test.want)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	note := "- [ ] call bob [owner:: Alice] (area:: home ops) [due:: 2024-03-01]\n- [ ] plain [link](url)\n"
+	parsed, err := ParseFile(strings.NewReader(note), FileMeta{}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"owner": "Alice", "area": "home ops", "due": "2024-03-01"}
+	if got := parsed.Tasks[0].Fields; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got fields %v, want %v", got, want)
+	}
+	if got := parsed.Tasks[1].Fields; got != nil {
+		t.Errorf("got fields %v for a task without any", got)
+	}
+
+	filtered := parsed.Filter(Filter{Where: map[string]string{"Owner": "alice"}})
+	if len(filtered.Tasks) != 1 || filtered.Tasks[0].Text != parsed.Tasks[0].Text {
+		t.Errorf("-where owner=alice kept %v", filtered.Tasks)
+	}
+}
+
+func TestDateSources(t *testing.T) {
+	note := "---\ndate: 2023-05-05\n---\n- [ ] first\n# 2023-06-01\n- [ ] second\n"
+	modTime := time.Date(2021, 2, 3, 12, 0, 0, 0, time.Local)
+	meta := FileMeta{Name: "2022-01-01.md", ModTime: modTime}
+	for _, test := range []struct {
+		sources []string
+		want    []string
+	}{
+		{[]string{DateSourceHeader, DateSourceFrontMatter}, []string{"2023-05-05", "2023-06-01"}},
+		{[]string{DateSourceFilename, DateSourceHeader}, []string{"2022-01-01", "2022-01-01"}},
+		{[]string{DateSourceHeader, DateSourceMtime}, []string{"2021-02-03", "2023-06-01"}},
+		{[]string{DateSourceBirthtime}, []string{"0001-01-01", "0001-01-01"}},
+	} {
+		parsed, err := ParseFile(strings.NewReader(note), meta, ParseOptions{DateSources: test.sources})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, task := range parsed.Tasks {
+			got = append(got, task.Date.Format(yearMonthDayLayout))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("sources %v: got dates %v, want %v", test.sources, got, test.want)
+		}
+	}
+	if err := CheckDateSources([]string{DateSourceGit, "ctime"}); err == nil {
+		t.Error("CheckDateSources accepted ctime")
+	}
+}
+
+func TestFilenameDateFormats(t *testing.T) {
+	options := ParseOptions{FilenameDateFormats: []string{"%d-%m-%Y", "YYYYMMDD", "YYYY_MM_DD", "January 2, 2006", "Daily DD.MM.YYYY"}}
+	for name, want := range map[string]string{
+		"01-03-2024.md":       "2024-03-01",
+		"20240302 standup.md": "2024-03-02",
+		"2024_03_03.md":       "2024-03-03",
+		"March 4, 2024.md":    "2024-03-04",
+		"Daily 05.03.2024.md": "2024-03-05",
+		"2024-03-06.md":       "2024-03-06",
+		"notes.md":            "",
+	} {
+		got := ""
+		if date := options.fileNameDate(name); date != nil {
+			got = date.Format(yearMonthDayLayout)
+		}
+		if got != want {
+			t.Errorf("%s: got date %q, want %q", name, got, want)
+		}
+	}
+	// the second check reads the format already read
+	for i := 0; i < 2; i++ {
+		if err := CheckFilenameDateFormats([]string{"MM-DD"}); err == nil {
+			t.Error("CheckFilenameDateFormats accepted a format without a year")
+		}
+	}
+}
+
+func TestAging(t *testing.T) {
+	note := "# 2024-01-01\n- [ ] ancient\n  - [ ] nested\n# 2024-05-01\n- [ ] monthish\n- [x] done\n- [-] dropped\n# 2024-05-25\n- [ ] weekish\n# 2024-05-30\n- [ ] fresh\n"
+	parsed, err := ParseFile(strings.NewReader(note), FileMeta{}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, bucket := range parsed.Aging(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)) {
+		for _, task := range bucket.Tasks {
+			got = append(got, bucket.Title+" "+task.Text)
+		}
+	}
+	want := []string{"3 months+ ancient", "3 months+ nested", "1 month+ monthish", "1 week+ weekish"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got buckets %v, want %v", got, want)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	note := "# 2024-05-01\n- [x] shipped\n- [x] filed\n- [ ] open\n"
+	parsed, err := ParseFile(strings.NewReader(note), FileMeta{}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first strings.Builder
+	if err := parsed.WriteSnapshot(&first, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(first.String(), "## 2024-06-01\n") || !strings.Contains(first.String(), "shipped") || !strings.Contains(first.String(), "filed") {
+		t.Errorf("first snapshot is missing the date or completed tasks:\n%s", first.String())
+	}
+
+	var second strings.Builder
+	if err := parsed.WriteSnapshot(&second, time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC), first.String()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(second.String(), "shipped") || !strings.Contains(second.String(), "No tasks completed") {
+		t.Errorf("second snapshot lists tasks already recorded:\n%s", second.String())
+	}
+}
+
+func TestReview(t *testing.T) {
+	for week, want := range map[string]string{"2024-W07": "2024-02-12", "2026-W01": "2025-12-29", "2020-W53": "2020-12-28"} {
+		monday, err := ParseWeek(week, time.UTC)
+		if err != nil || monday.Format("2006-01-02") != want {
+			t.Errorf("ParseWeek(%q) = %v, %v, want %s", week, monday, err, want)
+		}
+	}
+	for _, week := range []string{"2024-W54", "2024-W00", "2024-7"} {
+		if _, err := ParseWeek(week, time.UTC); err == nil {
+			t.Errorf("ParseWeek(%q) succeeded, want an error", week)
+		}
+	}
+
+	note := "# 2024-02-01\n- [ ] old #work\n- [x] shipped ✅ 2024-02-13 #work\n- [-] dropped\n# 2024-02-14\n- [ ] new #home\n# 2024-02-20\n- [ ] later\n"
+	parsed, err := ParseFile(strings.NewReader(note), FileMeta{}, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	monday, _ := ParseWeek("2024-W07", time.Local)
+	review := parsed.Review(monday)
+	got := []string{}
+	for _, part := range [][]Task{review.Completed, review.Created, review.CarriedOver} {
+		texts := []string{}
+		for _, task := range part {
+			texts = append(texts, task.Text)
+		}
+		got = append(got, strings.Join(texts, ","))
+	}
+	want := []string{"shipped ✅ 2024-02-13 #work", "new #home", "old #work"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got completed, created, and carried over %q, want %q", got, want)
+	}
+	if fmt.Sprint(review.ByTag) != "[{0 0 1 #home} {1 1 0 #work}]" {
+		t.Errorf("got tags %v", review.ByTag)
+	}
+}
+
+func TestCutTasksLogseq(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "journal.md")
+	note := "- DONE ship it\n  - notes on shipping\n- TODO next\n- [x] checkbox\n"
+	if err := os.WriteFile(filename, []byte(note), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cut := []string{}
+	err := CutTasks(filename, []int{1, 4}, func(blocks []string) error {
+		cut = blocks
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[- DONE ship it\n  - notes on shipping\n - [x] checkbox\n]"; fmt.Sprint(cut) != want {
+		t.Errorf("cut %q, want %q", fmt.Sprint(cut), want)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "- TODO next\n" {
+		t.Errorf("kept %q, want the open task", data)
+	}
+	if err := CutTasks(filename, []int{1}, func([]string) error { return nil }); err != nil {
+		t.Errorf("can't cut an open Logseq task: %v", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "note.md")
+	if err := os.WriteFile(filename, []byte("- [ ] ship it\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.md")
+	if err := os.Symlink(filename, link); err != nil {
+		t.Skip("can't create symlinks:", err)
+	}
+	if err := SetStatus(link, 1, StatusDone); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("the symlink was replaced: %v", err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filename); string(data) != "- [x] ship it\n" || info.Mode().Perm() != 0600 {
+		t.Errorf("got %q with mode %v, want the task checked off keeping mode 0600", data, info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("got %d files, want no temporary file left", len(entries))
+	}
+	if changed, err := WriteFileAtomic(filename, []byte("- [x] ship it\n")); changed || err != nil {
+		t.Errorf("rewrote a file already holding the data: %v", err)
+	}
+}
+
+func TestWalkDirectories(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{".git/objects", "notes/daily", "node_modules/pkg", "drafts"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("node_modules/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	err := WalkDirectories(root, WalkOptions{Exclude: []string{"drafts/"}}, func(dirPath string) {
+		relPath, _ := filepath.Rel(root, dirPath)
+		got = append(got, filepath.ToSlash(relPath))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".", "notes", "notes/daily"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got directories %v, want %v", got, want)
+	}
+}
+
+func TestWriteFileAtomicDevice(t *testing.T) {
+	if changed, err := WriteFileAtomic(os.DevNull, []byte("- [ ] ship it\n")); !changed || err != nil {
+		t.Fatalf("can't write to %s: %v", os.DevNull, err)
+	}
+	info, err := os.Stat(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().IsRegular() {
+		t.Errorf("%s was replaced with a regular file", os.DevNull)
+	}
+}
+
+func BenchmarkParseFile(b *testing.B) {
+	note := strings.Repeat(benchmarkNote, 10)
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	meta := FileMeta{Date: &date, DisplayPath: "2024-03-01.md"}
+	b.SetBytes(int64(len(note)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseFile(strings.NewReader(note), meta, ParseOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	root := b.TempDir()
+	for i := 0; i < 100; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("%02d", i%10))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		filename := filepath.Join(dir, fmt.Sprintf("2024-03-%02d-%d.md", i%28+1, i))
+		if err := os.WriteFile(filename, []byte(benchmarkNote), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Scan(root); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package tasks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// metadataPatterns match the due date, completion date, and priority markers
+// in task text, which the todo.txt and TaskPaper formats write in their own
+// syntax instead.
+var metadataPatterns = []*regexp.Regexp{
+	letterPriorityPattern,
+	regexp.MustCompile(`(?i)\s*\[(?:completion|due)::\s*\d{4}-\d{2}-\d{2}\]`),
+	regexp.MustCompile(`(?i)\s*(?:✅|📅|\bdone:|\bdue:)\s*\d{4}-\d{2}-\d{2}`),
+	regexp.MustCompile(`\s*(?:🔺|⏫|🔼|🔽|⏬)\x{FE0F}?`),
+	regexp.MustCompile(`(?:^|\s)![1-3]\b`),
+}
+
+// todoTxtPriorities maps priorities to todo.txt's letters, reading back as
+// the same priority where todo.txt has a letter for it.
+var todoTxtPriorities = map[Priority]string{
+	PriorityHighest: "A",
+	PriorityHigh:    "A",
+	PriorityMedium:  "B",
+	PriorityLow:     "C",
+	PriorityLowest:  "D",
+}
+
+// plainText returns the task's text without the markers for data the format
+// writes separately, and with each tag rewritten by retag.
+func plainText(text string, retag func(tag string) string) string {
+	for _, pattern := range metadataPatterns {
+		text = pattern.ReplaceAllString(text, " ")
+	}
+	text = tagPattern.ReplaceAllStringFunc(text, func(match string) string {
+		tag := strings.TrimLeft(match, " \t")
+		return match[:len(match)-len(tag)] + retag(tag)
+	})
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// WriteTodoTxt renders the tasks, including subtasks, in the todo.txt format:
+// completed tasks start with x and their completion date, then come the
+// priority as (A) to (D), the date, and the text, with #tags as +projects,
+// @tags as contexts, and the due date, ID, and any status other than done or
+// open as key:value pairs.
+func (tasks Tasks) WriteTodoTxt(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	for _, task := range Flatten(tasks.Visible()) {
+		fields := []string{}
+		priority := todoTxtPriorities[task.Priority]
+		closed := task.Complete || task.Cancelled()
+		if closed {
+			fields = append(fields, "x")
+			// a creation date can only be given after a completion date
+			if task.CompletedAt != nil && !task.Undated() {
+				fields = append(fields, task.CompletedAt.Format(yearMonthDayLayout), task.Date.Format(yearMonthDayLayout))
+			} else if task.CompletedAt != nil {
+				fields = append(fields, task.CompletedAt.Format(yearMonthDayLayout))
+			}
+		} else {
+			if priority != "" {
+				fields = append(fields, fmt.Sprintf("(%s)", priority))
+			}
+			if !task.Undated() {
+				fields = append(fields, task.Date.Format(yearMonthDayLayout))
+			}
+		}
+
+		fields = append(fields, plainText(task.Text, func(tag string) string {
+			return strings.Replace(tag, "#", "+", 1)
+		}))
+		if task.Due != nil {
+			fields = append(fields, "due:"+task.Due.Format(yearMonthDayLayout))
+		}
+		if closed && priority != "" {
+			fields = append(fields, "pri:"+priority)
+		}
+		if task.Status != StatusOpen && task.Status != StatusDone {
+			fields = append(fields, "status:"+task.Status.String())
+		}
+		fields = append(fields, "id:"+task.ID)
+		fmt.Fprintln(out, strings.Join(fields, " "))
+	}
+	return out.Flush()
+}
+
+// WriteTaskPaper renders the tasks in the TaskPaper format, with each section
+// of the report as a project and subtasks and context indented under their
+// task. Tags become @tags, and the due date, completion, priority, and any
+// status other than open are written as @due(...), @done(...),
+// @priority(...), and @in-progress style tags.
+func (tasks Tasks) WriteTaskPaper(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	for i, group := range tasks.Groups() {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s:\n", group.Title)
+		for _, task := range group.Tasks {
+			tasks.writeTaskPaperTask(out, task, 1)
+		}
+	}
+	return out.Flush()
+}
+
+func (tasks Tasks) writeTaskPaperTask(out *bufio.Writer, task Task, depth int) {
+	indent := strings.Repeat("\t", depth)
+	fields := []string{plainText(task.Text, func(tag string) string {
+		return "@" + strings.TrimLeft(tag, "#@")
+	})}
+	if task.Due != nil {
+		fields = append(fields, fmt.Sprintf("@due(%s)", task.Due.Format(yearMonthDayLayout)))
+	}
+	if task.Priority != PriorityNone {
+		fields = append(fields, fmt.Sprintf("@priority(%s)", task.Priority))
+	}
+	switch {
+	case task.Complete && task.CompletedAt != nil:
+		fields = append(fields, fmt.Sprintf("@done(%s)", task.CompletedAt.Format(yearMonthDayLayout)))
+	case task.Complete:
+		fields = append(fields, "@done")
+	case task.Status != StatusOpen:
+		fields = append(fields, "@"+task.Status.String())
+	}
+
+	fmt.Fprintf(out, "%s- %s\n", indent, strings.Join(fields, " "))
+	// context lines are notes, so they mustn't read as tasks or projects
+	for _, line := range task.Context {
+		fmt.Fprintf(out, "%s\t%s\n", indent, strings.TrimSuffix(strings.TrimPrefix(line, "- "), ":"))
+	}
+	for _, subtask := range task.Subtasks {
+		if tasks.hidden(subtask) {
+			continue
+		}
+		tasks.writeTaskPaperTask(out, subtask, depth+1)
+	}
+}
@@ -0,0 +1,116 @@
+package tasks
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Parser reads the tasks from notes in a format other than markdown, such as
+// org-mode, for the files registered with RegisterParser.
+type Parser interface {
+	// Parse reads the tasks from the file described by meta. Tasks left
+	// without a file path, date, ID, or assignees are given them as markdown
+	// tasks are.
+	Parse(r io.Reader, meta FileMeta, options ParseOptions) (Tasks, error)
+}
+
+// Renderer writes the tasks in an output format of its own, registered with
+// RegisterRenderer.
+type Renderer interface {
+	Render(w io.Writer, tasks Tasks) error
+}
+
+var (
+	// pluginsMutex guards parsers and renderers, which are read by concurrent
+	// scans.
+	pluginsMutex sync.RWMutex
+	parsers      = map[string]Parser{}
+	renderers    = map[string]Renderer{}
+)
+
+// RegisterParser makes scans read the files with the extension, such as
+// .org, with the parser. Extensions are matched in any case, and registering
+// one again replaces its parser.
+func RegisterParser(extension string, parser Parser) {
+	pluginsMutex.Lock()
+	defer pluginsMutex.Unlock()
+	parsers[strings.ToLower(extension)] = parser
+}
+
+// RegisterRenderer adds an output format written by the renderer, replacing
+// any renderer registered for it before.
+func RegisterRenderer(format string, renderer Renderer) {
+	pluginsMutex.Lock()
+	defer pluginsMutex.Unlock()
+	renderers[format] = renderer
+}
+
+// LookupRenderer returns the renderer registered for the format.
+func LookupRenderer(format string) (Renderer, bool) {
+	pluginsMutex.RLock()
+	defer pluginsMutex.RUnlock()
+	renderer, ok := renderers[format]
+	return renderer, ok
+}
+
+// Parsers returns the parsers registered with RegisterParser, by extension.
+func Parsers() map[string]Parser {
+	pluginsMutex.RLock()
+	defer pluginsMutex.RUnlock()
+	registered := map[string]Parser{}
+	for extension, parser := range parsers {
+		registered[extension] = parser
+	}
+	return registered
+}
+
+// lookupParser returns the parser registered for the filename's extension.
+func lookupParser(filename string) (Parser, bool) {
+	pluginsMutex.RLock()
+	defer pluginsMutex.RUnlock()
+	parser, ok := parsers[strings.ToLower(filepath.Ext(filename))]
+	return parser, ok
+}
+
+// IsNoteFile reports whether scans read the file, as markdown or with a
+// registered parser.
+func IsNoteFile(filename string) bool {
+	if IsMarkdownFile(filename) {
+		return true
+	}
+	_, ok := lookupParser(filename)
+	return ok
+}
+
+// completeParsed fills in what a Parser left out of its tasks and their
+// subtasks: the file's path and date, IDs, assignees from @tags, and the
+// status of tasks marked complete.
+func completeParsed(all []Task, meta FileMeta) []Task {
+	filePath := meta.DisplayPath
+	if filePath == "" {
+		filePath = meta.Path
+	}
+	for i := range all {
+		task := &all[i]
+		if task.FilePath == "" {
+			task.FilePath = filePath
+		}
+		if task.Undated() && meta.Date != nil {
+			task.Date = *meta.Date
+		}
+		if task.ID == "" {
+			task.ID = taskID(task.FilePath, task.Line, task.Text)
+		}
+		if task.Assignees == nil {
+			task.Assignees = parseAssignees(task.Tags)
+		}
+		if task.Complete && task.Status == StatusOpen {
+			task.Status = StatusDone
+		}
+		task.Complete = task.Status == StatusDone
+		task.Subtasks = completeParsed(task.Subtasks, meta)
+	}
+	return all
+}
@@ -0,0 +1,110 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Priority is a task's importance, parsed from Obsidian Tasks emoji, `!1`
+// through `!3`, or todo.txt style `(A)` markers.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLowest
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityHighest
+)
+
+var (
+	letterPriorityPattern = regexp.MustCompile(`^\(([A-Z])\)\s`)
+	numberPriorityPattern = regexp.MustCompile(`(?:^|\s)!([1-3])\b`)
+)
+
+var (
+	priorityBadges = map[Priority]string{
+		PriorityHighest: "🔴",
+		PriorityHigh:    "🟠",
+		PriorityMedium:  "🟡",
+		PriorityLow:     "🔵",
+		PriorityLowest:  "⚪",
+	}
+	priorityEmoji = map[string]Priority{
+		"🔺": PriorityHighest,
+		"⏫": PriorityHigh,
+		"🔼": PriorityMedium,
+		"🔽": PriorityLow,
+		"⏬": PriorityLowest,
+	}
+	priorityNames = map[Priority]string{
+		PriorityNone:    "",
+		PriorityHighest: "highest",
+		PriorityHigh:    "high",
+		PriorityMedium:  "medium",
+		PriorityLow:     "low",
+		PriorityLowest:  "lowest",
+	}
+)
+
+func parsePriority(text string) Priority {
+	for emoji, priority := range priorityEmoji {
+		if strings.Contains(text, emoji) {
+			return priority
+		}
+	}
+
+	if match := numberPriorityPattern.FindStringSubmatch(text); match != nil {
+		return map[string]Priority{"1": PriorityHigh, "2": PriorityMedium, "3": PriorityLow}[match[1]]
+	}
+
+	if match := letterPriorityPattern.FindStringSubmatch(text); match != nil {
+		switch match[1] {
+		case "A":
+			return PriorityHigh
+		case "B":
+			return PriorityMedium
+		case "C":
+			return PriorityLow
+		default:
+			return PriorityLowest
+		}
+	}
+
+	return PriorityNone
+}
+
+// Badge returns an emoji marking the priority in reports, or an empty string
+// for no priority.
+func (priority Priority) Badge() string {
+	return priorityBadges[priority]
+}
+
+// rank orders priorities from most to least important, placing tasks without
+// a priority between medium and low as Obsidian Tasks does.
+func (priority Priority) rank() int {
+	if priority == PriorityNone {
+		return int(PriorityMedium) - 1
+	}
+	return int(priority)
+}
+
+func (priority Priority) String() string {
+	return priorityNames[priority]
+}
+
+func (priority Priority) MarshalText() ([]byte, error) {
+	return []byte(priority.String()), nil
+}
+
+func (priority *Priority) UnmarshalText(text []byte) error {
+	for p, name := range priorityNames {
+		if name == string(text) {
+			*priority = p
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown priority '%s'", text)
+}
@@ -0,0 +1,150 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var recurrencePattern = regexp.MustCompile(`(?i)(?:🔁\s*|\brepeat:\s*|\[repeat::\s*)([a-z0-9 ]+)`)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// parseRecurrence returns the task's repeat rule, written after 🔁, repeat:,
+// or [repeat::, or an empty string if it has none that can be understood.
+func parseRecurrence(text string) string {
+	match := recurrencePattern.FindStringSubmatch(text)
+	if match == nil {
+		return ""
+	}
+	rule := strings.ToLower(strings.Join(strings.Fields(match[1]), " "))
+	if _, ok := recurrenceStep(rule); !ok {
+		return ""
+	}
+	return rule
+}
+
+// recurrenceStep returns a function giving the occurrence after a date for
+// rules like "daily", "every 2 weeks", "every monday", or "every weekday".
+func recurrenceStep(rule string) (func(time.Time) time.Time, bool) {
+	switch rule {
+	case "daily", "every day":
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, true
+	case "weekly", "every week":
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }, true
+	case "monthly", "every month":
+		return func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, true
+	case "yearly", "annually", "every year":
+		return func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }, true
+	case "every weekday":
+		return func(t time.Time) time.Time {
+			t = t.AddDate(0, 0, 1)
+			for t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+				t = t.AddDate(0, 0, 1)
+			}
+			return t
+		}, true
+	}
+
+	fields := strings.Fields(rule)
+	if len(fields) == 2 && fields[0] == "every" {
+		if weekday, ok := weekdays[strings.TrimSuffix(fields[1], "s")]; ok {
+			return func(t time.Time) time.Time {
+				days := (int(weekday)-int(t.Weekday())+6)%7 + 1
+				return t.AddDate(0, 0, days)
+			}, true
+		}
+	}
+	if len(fields) == 3 && fields[0] == "every" {
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return nil, false
+		}
+		switch strings.TrimSuffix(fields[2], "s") {
+		case "day":
+			return func(t time.Time) time.Time { return t.AddDate(0, 0, n) }, true
+		case "week":
+			return func(t time.Time) time.Time { return t.AddDate(0, 0, 7*n) }, true
+		case "month":
+			return func(t time.Time) time.Time { return t.AddDate(0, n, 0) }, true
+		case "year":
+			return func(t time.Time) time.Time { return t.AddDate(n, 0, 0) }, true
+		}
+	}
+
+	return nil, false
+}
+
+// ParseHorizon parses a span such as "30d", "2w", "3m", or "1y" into the date
+// that far after from.
+func ParseHorizon(horizon string, from time.Time) (time.Time, error) {
+	if len(horizon) < 2 {
+		return from, fmt.Errorf("invalid horizon '%s'", horizon)
+	}
+	n, err := strconv.Atoi(horizon[:len(horizon)-1])
+	if err != nil || n < 0 {
+		return from, fmt.Errorf("invalid horizon '%s'", horizon)
+	}
+	switch horizon[len(horizon)-1] {
+	case 'd':
+		return from.AddDate(0, 0, n), nil
+	case 'w':
+		return from.AddDate(0, 0, 7*n), nil
+	case 'm':
+		return from.AddDate(0, n, 0), nil
+	case 'y':
+		return from.AddDate(n, 0, 0), nil
+	}
+	return from, fmt.Errorf("invalid horizon '%s'", horizon)
+}
+
+// ExpandRecurring adds an instance of each incomplete recurring top-level task
+// for every occurrence from today through until, counting from the task's due
+// date or otherwise its date. Instances are dated, and due if the task has a
+// due date, on their occurrence.
+func (tasks Tasks) ExpandRecurring(today, until time.Time) Tasks {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	expanded := append([]Task{}, tasks.Tasks...)
+	for _, task := range tasks.Tasks {
+		if task.Complete || task.Recurrence == "" || (task.Undated() && task.Due == nil) {
+			continue
+		}
+		step, ok := recurrenceStep(task.Recurrence)
+		if !ok {
+			continue
+		}
+
+		anchor := task.Date
+		if task.Due != nil {
+			anchor = *task.Due
+		}
+		for occurrence := step(anchor); !occurrence.After(until); occurrence = step(occurrence) {
+			if occurrence.Before(today) {
+				continue
+			}
+			instance := task
+			instance.Date = occurrence
+			if task.Due != nil {
+				due := occurrence
+				instance.Due = &due
+			}
+			instance.ID = taskID(task.FilePath, task.Line, occurrence.Format(yearMonthDayLayout)+task.Text)
+			instance.RecurrenceOf = task.ID
+			instance.Subtasks = nil
+			expanded = append(expanded, instance)
+		}
+	}
+
+	tasks.Tasks = expanded
+	return tasks
+}
@@ -0,0 +1,87 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Report is the data passed to report templates.
+type Report struct {
+	// CollapseCompleted is set when the completed tasks of each group are to
+	// be folded away, as with Tasks.CollapseCompleted.
+	CollapseCompleted bool
+	Groups            []Group
+	// Omitted counts the tasks left out to keep the report short.
+	Omitted int
+	// Layout is how tasks are written, as with Tasks.Layout.
+	Layout string
+	// Problems are the paths the scan couldn't fully read.
+	Problems []Problem
+	// Progress is set when progress bars are to be shown, as with
+	// Tasks.Progress.
+	Progress bool
+	Stats    Stats
+	// Tasks are the top-level tasks to output, in sorted order.
+	Tasks []Task
+}
+
+// Stats summarizes task completion, counting subtasks.
+type Stats struct {
+	Completed  int `json:"completed"`
+	Incomplete int `json:"incomplete"`
+	Total      int `json:"total"`
+}
+
+// MarshalJSON includes the completion percentage with the counts.
+func (stats Stats) MarshalJSON() ([]byte, error) {
+	type counts Stats
+	return json.Marshal(struct {
+		counts
+		Percent int `json:"percent"`
+	}{counts(stats), stats.Percent()})
+}
+
+// Percent returns the percentage of tasks completed, rounded down.
+func (stats Stats) Percent() int {
+	if stats.Total == 0 {
+		return 0
+	}
+	return stats.Completed * 100 / stats.Total
+}
+
+// progressBarWidth is the number of cells in progress bars.
+const progressBarWidth = 10
+
+// ProgressBar returns the counts followed by a bar filled in proportion to the
+// tasks completed, as in **3/7 complete** ▓▓▓▓░░░░░░.
+func (stats Stats) ProgressBar() string {
+	filled := 0
+	if stats.Total > 0 {
+		filled = stats.Completed * progressBarWidth / stats.Total
+	}
+	return fmt.Sprintf("**%d/%d complete** %s%s", stats.Completed, stats.Total, strings.Repeat("▓", filled), strings.Repeat("░", progressBarWidth-filled))
+}
+
+// Report gathers the tasks to output into template data. Subtasks hidden by
+// OutputCompleted are left out.
+func (tasks Tasks) Report() Report {
+	report := Report{
+		CollapseCompleted: tasks.CollapseCompleted,
+		Stats: Stats{
+			Completed:  tasks.CompletedCount(),
+			Incomplete: tasks.IncompleteCount(),
+			Total:      tasks.TotalCount(),
+		},
+		Layout:   tasks.Layout,
+		Omitted:  tasks.Omitted,
+		Problems: tasks.Problems,
+		Progress: tasks.Progress,
+		Tasks:    tasks.Visible(),
+	}
+	for _, group := range tasks.Groups() {
+		group.Tasks = tasks.visible(group.Tasks)
+		report.Groups = append(report.Groups, group)
+	}
+	return report
+}
@@ -0,0 +1,153 @@
+package tasks
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// weekPattern matches an ISO week, as in 2024-W07, with the year and week as
+// submatches.
+var weekPattern = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+// Review is a summary of one week's work, for a weekly note.
+type Review struct {
+	ByTag []TagReview `json:"byTag"`
+	// CarriedOver are the open tasks dated before the week, oldest first.
+	CarriedOver []Task `json:"carriedOver"`
+	// Completed are the tasks completed during the week, by when. Tasks
+	// without a completion date aren't counted.
+	Completed []Task `json:"completed"`
+	// Created are the tasks dated during the week, by date.
+	Created []Task    `json:"created"`
+	End     time.Time `json:"end"`
+	Start   time.Time `json:"start"`
+	Week    string    `json:"week"`
+}
+
+// TagReview counts the tasks with a tag in each part of a Review.
+type TagReview struct {
+	CarriedOver int    `json:"carriedOver"`
+	Completed   int    `json:"completed"`
+	Created     int    `json:"created"`
+	Tag         string `json:"tag"`
+}
+
+// ParseWeek returns the Monday starting the ISO week, as in 2024-W07, in the
+// location.
+func ParseWeek(week string, location *time.Location) (time.Time, error) {
+	match := weekPattern.FindStringSubmatch(week)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("week '%s' isn't written as YYYY-Www, as in 2024-W07", week)
+	}
+	year, _ := strconv.Atoi(match[1])
+	number, _ := strconv.Atoi(match[2])
+	// January 4th is always in the first week of its year
+	fourth := time.Date(year, time.January, 4, 0, 0, 0, 0, location)
+	monday := fourth.AddDate(0, 0, -((int(fourth.Weekday())+6)%7)+7*(number-1))
+	if gotYear, gotWeek := monday.ISOWeek(); number < 1 || gotYear != year || gotWeek != number {
+		return time.Time{}, fmt.Errorf("%d has no week %d", year, number)
+	}
+	return monday, nil
+}
+
+// WeekName returns the ISO week the date is in, as in 2024-W07.
+func WeekName(date time.Time) string {
+	year, week := date.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// Review summarizes the week starting on the Monday: the tasks completed
+// during it, those dated during it, the open tasks dated before it, and how
+// many of each have every tag. Cancelled tasks and upcoming instances of
+// recurring tasks are left out.
+func (tasks Tasks) Review(monday time.Time) Review {
+	end := monday.AddDate(0, 0, 7)
+	review := Review{ByTag: []TagReview{}, CarriedOver: []Task{}, Completed: []Task{}, Created: []Task{}, End: end.AddDate(0, 0, -1), Start: monday, Week: WeekName(monday)}
+	byTag := map[string]*TagReview{}
+	count := func(task Task, part func(*TagReview)) {
+		for _, tag := range task.Tags {
+			tag = strings.ToLower(tag)
+			if byTag[tag] == nil {
+				byTag[tag] = &TagReview{Tag: tag}
+			}
+			part(byTag[tag])
+		}
+	}
+
+	for _, task := range Flatten(tasks.Tasks) {
+		if task.Cancelled() || task.RecurrenceOf != "" {
+			continue
+		}
+		// subtasks are listed on their own
+		task.Subtasks = nil
+		if task.Complete && task.CompletedAt != nil && !task.CompletedAt.Before(monday) && task.CompletedAt.Before(end) {
+			review.Completed = append(review.Completed, task)
+			count(task, func(tag *TagReview) { tag.Completed++ })
+		}
+		if task.Undated() || !task.Date.Before(end) {
+			continue
+		}
+		if !task.Date.Before(monday) {
+			review.Created = append(review.Created, task)
+			count(task, func(tag *TagReview) { tag.Created++ })
+		} else if !task.Complete {
+			review.CarriedOver = append(review.CarriedOver, task)
+			count(task, func(tag *TagReview) { tag.CarriedOver++ })
+		}
+	}
+
+	sort.SliceStable(review.Completed, func(i, j int) bool {
+		return review.Completed[i].CompletedAt.Before(*review.Completed[j].CompletedAt)
+	})
+	for _, list := range [][]Task{review.Created, review.CarriedOver} {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Date.Before(list[j].Date)
+		})
+	}
+	for _, tag := range byTag {
+		review.ByTag = append(review.ByTag, *tag)
+	}
+	sort.Slice(review.ByTag, func(i, j int) bool {
+		return review.ByTag[i].Tag < review.ByTag[j].Tag
+	})
+	return review
+}
+
+// WriteMarkdown writes the review as a section to paste into a weekly note:
+// a summary line, the tasks of each part with their file, and a table of the
+// counts by tag. Tasks are written as plain list items, not checkboxes, so
+// the note isn't scanned as holding them again.
+func (review Review) WriteMarkdown(w io.Writer) error {
+	var out strings.Builder
+	fmt.Fprintf(&out, "## Week %s (%s – %s)\n\n", review.Week, review.Start.Format(yearMonthDayLayout), review.End.Format(yearMonthDayLayout))
+	fmt.Fprintf(&out, "%d completed · %d created · %d carried over\n", len(review.Completed), len(review.Created), len(review.CarriedOver))
+	for _, part := range []struct {
+		title string
+		tasks []Task
+	}{
+		{"Completed", review.Completed},
+		{"Created", review.Created},
+		{"Carried over", review.CarriedOver},
+	} {
+		if len(part.tasks) == 0 {
+			continue
+		}
+		fmt.Fprintf(&out, "\n### %s\n\n", part.title)
+		for _, task := range part.tasks {
+			fmt.Fprintf(&out, "- %s · %s\n", task.Text, task.FilePath)
+		}
+	}
+	if len(review.ByTag) > 0 {
+		out.WriteString("\n### By tag\n\n| Tag | Completed | Created | Carried over |\n| --- | ---: | ---: | ---: |\n")
+		for _, tag := range review.ByTag {
+			fmt.Fprintf(&out, "| %s | %d | %d | %d |\n", tag.Tag, tag.Completed, tag.Created, tag.CarriedOver)
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,384 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var markdownFilenamePattern = regexp.MustCompile(`(?i).md$`)
+
+// WalkOptions controls which files are found when walking a directory tree.
+type WalkOptions struct {
+	// Exclude holds .gitignore style patterns, relative to the root, of paths
+	// to skip in addition to those in .gitignore and .ignore files.
+	Exclude []string
+	// FollowSymlinks descends into symlinked directories and includes
+	// symlinked files. Each directory is walked at most once, so symlink
+	// cycles end.
+	FollowSymlinks bool
+	// MaxDepth is the deepest level of directories walked, counting the root
+	// as the first, or zero for no limit.
+	MaxDepth int
+	// MaxFiles stops the walk once this many markdown files are found, or is
+	// zero for no limit.
+	MaxFiles int
+	// MaxFileSize skips markdown files larger than this many bytes, or is zero
+	// for no limit.
+	MaxFileSize int64
+	// Reports are the paths of generated output files to skip, absolute or
+	// relative to the working directory, so reports written inside a root
+	// aren't read back as notes.
+	Reports []string
+	// ReportPatterns are file name patterns, as in weekly-*.md, of generated
+	// output files to skip wherever they're found.
+	ReportPatterns []string
+}
+
+// WalkError lists the paths that could not be read during a walk, such as
+// directories without read permission, and those left out by the limits of
+// WalkOptions. They are skipped and the walk goes on, unless MaxFiles is
+// reached.
+type WalkError struct {
+	Errors []error
+	// DeepDirectories counts the directories below MaxDepth, which weren't
+	// walked.
+	DeepDirectories int
+	// LargeFiles are the markdown files larger than MaxFileSize.
+	LargeFiles []string
+	// MaxFilesReached is set when the walk stopped at MaxFiles files.
+	MaxFilesReached bool
+}
+
+// Problem is a path a scan couldn't read, or only partly read, and what went
+// wrong.
+type Problem struct {
+	Message string `json:"message"`
+	Path    string `json:"path"`
+}
+
+// Scan finds the tasks in all markdown files under root, sorted by date.
+func Scan(root string) (Tasks, error) {
+	tasks := Tasks{}
+	files, err := MarkdownFiles(root, WalkOptions{ReportPatterns: []string{DefaultOutputFilename}})
+	if files == nil {
+		return tasks, err
+	}
+
+	for _, file := range files {
+		file.DisplayPath = file.RelativePath(false)
+		fileTasks, err := ParseFilePath(file, ParseOptions{})
+		var longLines *LongLinesError
+		if err != nil && !errors.As(err, &longLines) {
+			continue
+		}
+		tasks.Tasks = append(tasks.Tasks, fileTasks.Tasks...)
+	}
+	tasks.SortByDate()
+
+	return tasks, err
+}
+
+// ParseFilePath opens the file at meta.Path and parses its tasks, with the
+// Parser registered for its extension when it isn't markdown. With
+// DateFromGit, a file not dated by its name, or by its journal name with
+// FlavorLogseq, has its lines dated by git blame, unless git can't tell when
+// they were committed. A file named with one of the FilenameDateFormats is
+// dated by its name. With DateSources, the file is dated by the first of
+// its name, birth time, and modification time listed, for parsers of other
+// formats, and its lines by git blame when git is listed.
+func ParseFilePath(meta FileMeta, options ParseOptions) (Tasks, error) {
+	if date := options.fileNameDate(meta.Name); date != nil && len(options.FilenameDateFormats) > 0 {
+		meta.Date = date
+	}
+	if len(options.DateSources) > 0 {
+		meta.Date = nil
+		if date := fileDateCandidates(meta, options).first(options.DateSources); !date.IsZero() {
+			meta.Date = &date
+		}
+		if contains(options.DateSources, DateSourceGit) && meta.LineDates == nil {
+			meta.LineDates, _ = gitLineDates(meta.Path)
+		}
+	} else if options.DateFrom == DateFromGit && meta.LineDates == nil && options.fileNameDate(meta.Name) == nil && (options.Flavor != FlavorLogseq || logseqJournalDate(meta.Name) == nil) {
+		// files outside a repository keep the file's date
+		meta.LineDates, _ = gitLineDates(meta.Path)
+	}
+
+	file, err := os.Open(meta.Path)
+	if err != nil {
+		return Tasks{}, err
+	}
+	defer file.Close()
+
+	if parser, ok := lookupParser(meta.Name); ok {
+		parsed, err := parser.Parse(file, meta, options)
+		parsed.Tasks = completeParsed(parsed.Tasks, meta)
+		return parsed, err
+	}
+	return ParseFile(file, meta, options)
+}
+
+// IsMarkdownFile reports whether the filename has a markdown extension.
+func IsMarkdownFile(filename string) bool {
+	return markdownFilenamePattern.MatchString(filename)
+}
+
+// MarkdownFiles recursively lists the markdown files under root, along with
+// those of formats registered with RegisterParser, skipping the generated
+// output files given by the options, .git directories, and paths matched by
+// .gitignore and .ignore files or excluded by the options. When some paths
+// could not be read, the files found are returned with a *WalkError.
+func MarkdownFiles(root string, options WalkOptions) ([]FileMeta, error) {
+	paths := []FileMeta{}
+	err := WalkMarkdownFiles(root, options, func(file FileMeta) error {
+		paths = append(paths, file)
+		return nil
+	})
+	var walkErr *WalkError
+	if err != nil && !errors.As(err, &walkErr) {
+		return nil, err
+	}
+
+	return paths, err
+}
+
+// WalkMarkdownFiles calls fn for each markdown file under root as it is found,
+// skipping the same paths as MarkdownFiles. Walking stops at the first error
+// returned by fn. Paths that cannot be read are skipped and returned together
+// as a *WalkError once the walk is done.
+func WalkMarkdownFiles(root string, options WalkOptions, fn func(FileMeta) error) error {
+	return walkTree(walker{fn: fn, options: options, root: root})
+}
+
+// WalkDirectories calls fn with each directory under root, root first, that
+// WalkMarkdownFiles walks, skipping the same paths, so the directories
+// watched for changes are those a scan reads notes from.
+func WalkDirectories(root string, options WalkOptions, fn func(dirPath string)) error {
+	return walkTree(walker{dirFn: fn, fn: func(FileMeta) error { return nil }, options: options, root: root})
+}
+
+// walkTree walks the walker's root, returning a *WalkError of the paths
+// skipped.
+func walkTree(w walker) error {
+	w.reports, w.visited = map[string]bool{}, map[string]bool{}
+	for _, report := range w.options.Reports {
+		if reportPath, err := filepath.Abs(report); err == nil {
+			w.reports[reportPath] = true
+		}
+	}
+	rules := ignoreRules{}.withPatterns(w.root, append([]string{".git/"}, w.options.Exclude...))
+	if err := w.walk(w.root, w.root, rules, 0); err != nil {
+		return err
+	}
+	if len(w.skipped.Errors) > 0 || w.skipped.DeepDirectories > 0 || len(w.skipped.LargeFiles) > 0 || w.skipped.MaxFilesReached {
+		return &w.skipped
+	}
+
+	return nil
+}
+
+// walker holds the state of a WalkMarkdownFiles call shared across the
+// symlinked directories it follows.
+type walker struct {
+	// dirFn, when set, is called with each directory walked
+	dirFn   func(string)
+	fn      func(FileMeta) error
+	found   int
+	options WalkOptions
+	// reports holds the absolute paths of WalkOptions.Reports
+	reports map[string]bool
+	root    string
+	skipped WalkError
+	// visited holds the resolved paths of the directories walked so far, when
+	// following symlinks
+	visited map[string]bool
+}
+
+// walk walks the directory at walkPath, reporting the paths under it as under
+// dirPath, which is depth levels below the root. They differ when dirPath is a
+// followed symlink and walkPath is its target.
+func (w *walker) walk(dirPath, walkPath string, rules ignoreRules, depth int) error {
+	dirRules := map[string]ignoreRules{}
+	return filepath.WalkDir(walkPath, func(entryPath string, entry fs.DirEntry, err error) error {
+		if w.skipped.MaxFilesReached {
+			return fs.SkipAll
+		}
+		if err != nil {
+			w.skipped.Errors = append(w.skipped.Errors, err)
+			return nil
+		}
+
+		relPath, err := filepath.Rel(walkPath, entryPath)
+		if err != nil {
+			return err
+		}
+		filePath := path.Join(dirPath, filepath.ToSlash(relPath))
+		if relPath == "." {
+			if !w.visit(entryPath) {
+				return fs.SkipDir
+			}
+			dirRules[filepath.Clean(entryPath)] = rules.withIgnoreFiles(filePath)
+			w.walkedDir(filePath)
+			return nil
+		}
+
+		parentRules := dirRules[filepath.Dir(entryPath)]
+		info, err := entry.Info()
+		if err == nil && entry.Type()&fs.ModeSymlink != 0 {
+			if !w.options.FollowSymlinks {
+				return nil
+			}
+			info, err = os.Stat(entryPath)
+		}
+		if err != nil {
+			w.skipped.Errors = append(w.skipped.Errors, err)
+			return nil
+		}
+
+		if parentRules.ignored(filePath, info.IsDir()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		entryDepth := depth + len(strings.Split(filepath.ToSlash(relPath), "/"))
+		if info.IsDir() && w.options.MaxDepth > 0 && entryDepth >= w.options.MaxDepth {
+			w.skipped.DeepDirectories++
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() && !entry.IsDir() {
+			target, err := filepath.EvalSymlinks(entryPath)
+			if err != nil {
+				w.skipped.Errors = append(w.skipped.Errors, err)
+				return nil
+			}
+			return w.walk(filePath, target, parentRules, entryDepth)
+		}
+		if info.IsDir() {
+			if !w.visit(entryPath) {
+				return fs.SkipDir
+			}
+			dirRules[entryPath] = parentRules.withIgnoreFiles(filePath)
+			w.walkedDir(filePath)
+			return nil
+		}
+
+		if !IsNoteFile(entry.Name()) || w.isReport(filePath, entry.Name()) {
+			return nil
+		}
+		if w.options.MaxFileSize > 0 && info.Size() > w.options.MaxFileSize {
+			w.skipped.LargeFiles = append(w.skipped.LargeFiles, filePath)
+			return nil
+		}
+		if w.options.MaxFiles > 0 && w.found >= w.options.MaxFiles {
+			w.skipped.MaxFilesReached = true
+			return fs.SkipAll
+		}
+		w.found++
+		birthTime := fileBirthTime(filePath, info)
+		date := parseDateFromFile(entry.Name(), birthTime, info.ModTime())
+		return w.fn(FileMeta{BirthTime: birthTime, Date: date, ModTime: info.ModTime(), Name: entry.Name(), Path: filePath, Root: w.root, Size: info.Size()})
+	})
+}
+
+// walkedDir passes the directory to dirFn, when it's set.
+func (w *walker) walkedDir(dirPath string) {
+	if w.dirFn != nil {
+		w.dirFn(dirPath)
+	}
+}
+
+// isReport reports whether the file is one of the generated output files to
+// skip, by its path or its name.
+func (w *walker) isReport(filePath, name string) bool {
+	for _, pattern := range w.options.ReportPatterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	absPath, err := filepath.Abs(filePath)
+	return err == nil && w.reports[absPath]
+}
+
+// visit reports whether the directory has not been walked yet, marking it
+// walked. Directories are only tracked when following symlinks, the only way
+// one can be reached twice.
+func (w *walker) visit(dirPath string) bool {
+	if !w.options.FollowSymlinks {
+		return true
+	}
+	resolved, err := filepath.EvalSymlinks(dirPath)
+	if err != nil {
+		return true
+	}
+	if w.visited[resolved] {
+		return false
+	}
+	w.visited[resolved] = true
+	return true
+}
+
+// RelativePath returns the file path relative to the root it was found under.
+// When prefixRoot is set, the root's base name is kept as a prefix so files
+// from different roots remain distinguishable.
+func (meta FileMeta) RelativePath(prefixRoot bool) string {
+	relPath, err := filepath.Rel(meta.Root, meta.Path)
+	if err != nil {
+		return meta.Path
+	}
+	if prefixRoot {
+		return path.Join(filepath.Base(meta.Root), filepath.ToSlash(relPath))
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// parseDateFromFile dates the file by the date its name begins with, or
+// otherwise by when it was created, or modified where that isn't recorded, in
+// the local time zone.
+func parseDateFromFile(name string, birthTime, modTime time.Time) *time.Time {
+	if result := parseDateFormats(fileDateFormats, name, nil); result != nil {
+		return result
+	}
+
+	result := birthTime
+	if result.IsZero() {
+		result = modTime
+	}
+	if result.IsZero() {
+		return nil
+	}
+	result = result.In(time.Local)
+	return &result
+}
+
+func (err *WalkError) Error() string {
+	summary := []string{}
+	if len(err.Errors) > 0 {
+		messages := []string{}
+		for _, skipped := range err.Errors {
+			messages = append(messages, skipped.Error())
+		}
+		summary = append(summary, fmt.Sprintf("skipped %d unreadable paths: %s", len(err.Errors), strings.Join(messages, "; ")))
+	}
+	if err.DeepDirectories > 0 {
+		summary = append(summary, fmt.Sprintf("skipped %d directories too deep to walk", err.DeepDirectories))
+	}
+	if len(err.LargeFiles) > 0 {
+		summary = append(summary, fmt.Sprintf("skipped %d files too large to read: %s", len(err.LargeFiles), strings.Join(err.LargeFiles, ", ")))
+	}
+	if err.MaxFilesReached {
+		summary = append(summary, "stopped at the most files to read")
+	}
+	return strings.Join(summary, "; ")
+}
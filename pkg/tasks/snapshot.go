@@ -0,0 +1,47 @@
+package tasks
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteSnapshot writes a markdown summary of the tasks on the date, to be
+// appended to a history of earlier snapshots: a header titled with the date,
+// how many tasks are complete, open, and overdue, and the completed tasks the
+// history doesn't already list, by ID, so each run notes what was finished
+// since the last.
+func (tasks Tasks) WriteSnapshot(w io.Writer, date time.Time, history string) error {
+	recorded := map[string]bool{}
+	for _, match := range reportIDPattern.FindAllStringSubmatch(history, -1) {
+		recorded[match[1]] = true
+	}
+	all := Flatten(tasks.Tasks)
+	overdue := 0
+	completed := []Task{}
+	for _, task := range all {
+		if task.Overdue() {
+			overdue++
+		}
+		if task.Complete && !recorded[task.ID] {
+			completed = append(completed, task)
+		}
+	}
+
+	var out strings.Builder
+	stats := newStats(all)
+	out.WriteString(fmt.Sprintf("## %s\n\n%s · %d open · %d overdue\n\n", date.Format(yearMonthDayLayout), stats.ProgressBar(), stats.Incomplete, overdue))
+	if len(completed) == 0 {
+		out.WriteString("_No tasks completed since the last snapshot._\n")
+	} else {
+		out.WriteString("Completed since the last snapshot:\n\n")
+		for _, task := range completed {
+			// subtasks completed are listed on their own
+			task.Subtasks = nil
+			tasks.writeTask(&out, task, 0)
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
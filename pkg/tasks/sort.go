@@ -0,0 +1,167 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SortCompleted = "completed"
+	SortDate      = "date"
+	SortDue       = "due"
+	SortFile      = "file"
+	SortLine      = "line"
+	SortPriority  = "priority"
+	SortText      = "text"
+)
+
+// SortOptions are the keys tasks can be ordered by.
+var SortOptions = []string{SortCompleted, SortDate, SortDue, SortFile, SortLine, SortPriority, SortText}
+
+const (
+	// SortAscending follows a key, as in due:asc, to order it from least to
+	// greatest.
+	SortAscending = "asc"
+	// SortDescending follows a key, as in date:desc, to order it from
+	// greatest to least.
+	SortDescending = "desc"
+)
+
+// SortKey is one of the keys tasks are ordered by, and its direction.
+type SortKey struct {
+	Descending bool
+	// Key is one of SortOptions.
+	Key string
+}
+
+// ParseSort reads comma-separated sort keys, each of SortOptions and
+// optionally followed by :asc or :desc, as in priority,due:desc. Keys are
+// ascending unless given otherwise, except priority, which orders from
+// highest to lowest.
+func ParseSort(spec string) ([]SortKey, error) {
+	keys := []SortKey{}
+	for _, field := range strings.Split(spec, ",") {
+		name, direction, _ := strings.Cut(strings.TrimSpace(field), ":")
+		if !contains(SortOptions, name) {
+			return nil, fmt.Errorf("unknown sort '%s'", name)
+		}
+		key := SortKey{Descending: name == SortPriority, Key: name}
+		switch direction {
+		case "":
+		case SortAscending:
+			key.Descending = false
+		case SortDescending:
+			key.Descending = true
+		default:
+			return nil, fmt.Errorf("unknown sort direction '%s', want %s or %s", direction, SortAscending, SortDescending)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Sort orders the tasks by the comma-separated keys read by ParseSort, or
+// by date when they can't be read.
+func (tasks Tasks) Sort(spec string) {
+	keys, err := ParseSort(spec)
+	if err != nil {
+		keys = []SortKey{{Key: SortDate}}
+	}
+	tasks.SortBy(keys)
+}
+
+// SortBy orders the tasks by each key in turn. Tasks without the date a key
+// orders by sort after those with one, whichever its direction. Tasks equal
+// by every key are ordered by file and line, so the order doesn't depend on
+// the order files were read in.
+func (tasks Tasks) SortBy(keys []SortKey) {
+	keys = append(keys, SortKey{Key: SortFile}, SortKey{Key: SortLine})
+	sort.SliceStable(tasks.Tasks, func(i, j int) bool {
+		for _, key := range keys {
+			if order := compareTasks(tasks.Tasks[i], tasks.Tasks[j], key); order != 0 {
+				return order < 0
+			}
+		}
+		return false
+	})
+}
+
+// compareTasks returns -1 when the task sorts before the other by the key, 1
+// when it sorts after, and 0 when they're equal by it.
+func compareTasks(task, other Task, key SortKey) int {
+	var order int
+	switch key.Key {
+	case SortCompleted:
+		return compareDates(task.CompletedAt, other.CompletedAt, key.Descending)
+	case SortDate:
+		var date, otherDate *time.Time
+		if !task.Undated() {
+			date = &task.Date
+		}
+		if !other.Undated() {
+			otherDate = &other.Date
+		}
+		return compareDates(date, otherDate, key.Descending)
+	case SortDue:
+		return compareDates(task.Due, other.Due, key.Descending)
+	case SortFile:
+		order = comparePaths(task.FilePath, other.FilePath)
+	case SortLine:
+		order = compareInts(task.Line, other.Line)
+	case SortPriority:
+		order = compareInts(task.Priority.rank(), other.Priority.rank())
+	case SortText:
+		order = strings.Compare(strings.ToLower(task.Text), strings.ToLower(other.Text))
+	}
+	if key.Descending {
+		return -order
+	}
+	return order
+}
+
+// compareDates compares the dates in the direction, placing missing dates
+// last.
+func compareDates(date, other *time.Time, descending bool) int {
+	switch {
+	case date == nil && other == nil:
+		return 0
+	case date == nil:
+		return 1
+	case other == nil:
+		return -1
+	}
+	order := 0
+	if date.Before(*other) {
+		order = -1
+	} else if date.After(*other) {
+		order = 1
+	}
+	if descending {
+		return -order
+	}
+	return order
+}
+
+// comparePaths compares slash separated paths a directory at a time, so
+// paths sort as a walk finds them, with notes/a.md before notes-old.md.
+func comparePaths(path, other string) int {
+	segments, otherSegments := strings.Split(path, "/"), strings.Split(other, "/")
+	for i := 0; i < len(segments) && i < len(otherSegments); i++ {
+		if order := strings.Compare(segments[i], otherSegments[i]); order != 0 {
+			return order
+		}
+	}
+	return compareInts(len(segments), len(otherSegments))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
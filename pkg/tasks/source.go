@@ -0,0 +1,100 @@
+package tasks
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// DefaultSourceExtensions are the extensions of the source files usually
+// scanned for TODO comments.
+var DefaultSourceExtensions = []string{"c", "cpp", "cs", "go", "h", "java", "js", "jsx", "kt", "lua", "php", "py", "rb", "rs", "sh", "sql", "swift", "ts", "tsx"}
+
+// sourceOwnerPattern matches the name in a comment like TODO(ann), who the
+// comment is read as assigned to.
+var sourceOwnerPattern = regexp.MustCompile(`^[\p{L}\p{N}_.-]+$`)
+
+// sourceCommentPattern matches a comment starting with one of the keywords,
+// in any of the usual comment styles, such as "// TODO(ann): fix" or
+// "# FIXME handle errors", capturing the keyword, the name in parentheses
+// after it, and the comment's text.
+func sourceCommentPattern(keywords []string) *regexp.Regexp {
+	quoted := []string{}
+	for _, keyword := range keywords {
+		quoted = append(quoted, regexp.QuoteMeta(keyword))
+	}
+	return regexp.MustCompile(`(?:^|\s)(?://+|#+|--|;+|%+|/\*+|\*|<!--)\s*(` + strings.Join(quoted, "|") + `)\b(?:\(([^)]*)\))?:?\s+(.*?)\s*(?:\*/|-->)?\s*$`)
+}
+
+// SourceParser reads TODO comments from source code, registered with
+// RegisterParser for the extensions of the source files to scan. Comments
+// starting with one of the keywords are incomplete tasks tagged with their
+// keyword, and the name in a comment like TODO(ann) is an @ann tag.
+type SourceParser struct {
+	// Keywords start the comments read as tasks, defaulting to
+	// DefaultTodoKeywords.
+	Keywords []string
+}
+
+// Parse reads the TODO comments from the source file.
+func (parser SourceParser) Parse(r io.Reader, meta FileMeta, options ParseOptions) (Tasks, error) {
+	keywords := parser.Keywords
+	if len(keywords) == 0 {
+		keywords = DefaultTodoKeywords
+	}
+	pattern := sourceCommentPattern(keywords)
+	lines := newLineReader(r, options.MaxLineLength)
+	longLines := []int{}
+
+	tasks := Tasks{Tasks: []Task{}}
+	lineNumber := 0
+	for lines.scan() {
+		lineNumber++
+		if lines.tooLong {
+			longLines = append(longLines, lineNumber)
+			continue
+		}
+		match := pattern.FindStringSubmatch(lines.text)
+		if match == nil || match[3] == "" {
+			continue
+		}
+
+		text := match[3]
+		tags := append(parseTags(text), "#"+match[1])
+		if sourceOwnerPattern.MatchString(match[2]) {
+			tags = append(tags, "@"+match[2])
+		}
+		task := Task{
+			Due:      parseDate(duePattern, text, nil),
+			Estimate: parseEstimate(text),
+			Fields:   parseFields(text),
+			Line:     lineNumber,
+			Priority: parsePriority(text),
+			Tags:     tags,
+			Text:     text,
+		}
+		if date, ok := meta.LineDates[lineNumber]; ok && meta.Date == nil {
+			task.Date = date
+		}
+		tasks.Tasks = append(tasks.Tasks, task)
+	}
+
+	if lines.err != nil {
+		return tasks, lines.err
+	}
+	if len(longLines) > 0 {
+		return tasks, &LongLinesError{Lines: longLines}
+	}
+	return tasks, nil
+}
+
+// isSourceFile reports whether the file is read by a SourceParser, so its
+// tasks are linked to their lines rather than to a header.
+func isSourceFile(filename string) bool {
+	parser, ok := lookupParser(filename)
+	if !ok {
+		return false
+	}
+	_, ok = parser.(SourceParser)
+	return ok
+}
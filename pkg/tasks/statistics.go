@@ -0,0 +1,124 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// longestOpenCount is how many of the oldest incomplete tasks Statistics
+// lists.
+const longestOpenCount = 10
+
+// Statistics are completion metrics for tracking progress over time. All
+// counts include subtasks.
+type Statistics struct {
+	// AverageAgeDays is the mean age of incomplete tasks, measured from their
+	// date.
+	AverageAgeDays float64 `json:"averageAgeDays"`
+	// AverageDaysToComplete is the mean time from date to completion of tasks
+	// with a completion date.
+	AverageDaysToComplete float64     `json:"averageDaysToComplete"`
+	ByFile                []Breakdown `json:"byFile"`
+	ByMonth               []Breakdown `json:"byMonth"`
+	ByTag                 []Breakdown `json:"byTag"`
+	ByWeek                []Breakdown `json:"byWeek"`
+	LongestOpen           []Task      `json:"longestOpen"`
+	Stats                 Stats       `json:"stats"`
+}
+
+// Breakdown is the completion of the tasks sharing a file, tag, or period.
+type Breakdown struct {
+	Name  string `json:"name"`
+	Stats Stats  `json:"stats"`
+}
+
+// Statistics computes completion metrics, measuring ages up to now. Undated
+// tasks count toward the totals and the breakdowns by file and tag only.
+func (tasks Tasks) Statistics(now time.Time) Statistics {
+	all := Flatten(tasks.Tasks)
+	statistics := Statistics{
+		Stats:       newStats(all),
+		LongestOpen: []Task{},
+	}
+
+	byFile := map[string][]Task{}
+	byMonth := map[string][]Task{}
+	byTag := map[string][]Task{}
+	byWeek := map[string][]Task{}
+	var totalAge, totalTimeToComplete time.Duration
+	datedCompletions := 0
+	for _, task := range all {
+		if task.Cancelled() {
+			continue
+		}
+		byFile[task.FilePath] = append(byFile[task.FilePath], task)
+		for _, tag := range task.Tags {
+			byTag[strings.ToLower(tag)] = append(byTag[strings.ToLower(tag)], task)
+		}
+		// undated tasks have no month or week, nor an age
+		if task.Undated() {
+			continue
+		}
+		byMonth[task.Date.Format("2006-01")] = append(byMonth[task.Date.Format("2006-01")], task)
+		year, week := task.Date.ISOWeek()
+		weekName := fmt.Sprintf("%d-W%02d", year, week)
+		byWeek[weekName] = append(byWeek[weekName], task)
+
+		if task.Complete && task.CompletedAt != nil {
+			totalTimeToComplete += task.CompletedAt.Sub(task.Date)
+			datedCompletions++
+		}
+		if !task.Complete {
+			totalAge += now.Sub(task.Date)
+			task.Subtasks = nil
+			statistics.LongestOpen = append(statistics.LongestOpen, task)
+		}
+	}
+
+	if len(statistics.LongestOpen) > 0 {
+		statistics.AverageAgeDays = totalAge.Hours() / 24 / float64(len(statistics.LongestOpen))
+	}
+	if datedCompletions > 0 {
+		statistics.AverageDaysToComplete = totalTimeToComplete.Hours() / 24 / float64(datedCompletions)
+	}
+	sort.SliceStable(statistics.LongestOpen, func(i, j int) bool {
+		return statistics.LongestOpen[i].Date.Before(statistics.LongestOpen[j].Date)
+	})
+	if len(statistics.LongestOpen) > longestOpenCount {
+		statistics.LongestOpen = statistics.LongestOpen[:longestOpenCount]
+	}
+
+	statistics.ByFile = breakdowns(byFile)
+	statistics.ByMonth = breakdowns(byMonth)
+	statistics.ByTag = breakdowns(byTag)
+	statistics.ByWeek = breakdowns(byWeek)
+
+	return statistics
+}
+
+// breakdowns returns the stats of each named set of tasks in name order.
+func breakdowns(byName map[string][]Task) []Breakdown {
+	result := []Breakdown{}
+	for _, group := range sortedGroups(byName) {
+		result = append(result, Breakdown{Name: group.Title, Stats: newStats(group.Tasks)})
+	}
+	return result
+}
+
+// newStats counts the tasks, not including their subtasks or cancelled tasks.
+func newStats(all []Task) Stats {
+	stats := Stats{}
+	for _, task := range all {
+		if task.Cancelled() {
+			continue
+		}
+		stats.Total++
+		if task.Complete {
+			stats.Completed++
+		}
+	}
+	stats.Incomplete = stats.Total - stats.Completed
+	return stats
+}
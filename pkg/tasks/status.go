@@ -0,0 +1,89 @@
+package tasks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Status is the state of a task, parsed from the mark in its checkbox.
+type Status int
+
+const (
+	StatusOpen Status = iota
+	StatusDone
+	StatusInProgress
+	StatusCancelled
+	StatusForwarded
+	StatusQuestion
+)
+
+var (
+	statusNames = map[Status]string{
+		StatusOpen:       "open",
+		StatusDone:       "done",
+		StatusInProgress: "in-progress",
+		StatusCancelled:  "cancelled",
+		StatusForwarded:  "forwarded",
+		StatusQuestion:   "question",
+	}
+	statusSymbols = map[Status]string{
+		StatusOpen:       " ",
+		StatusDone:       "x",
+		StatusInProgress: "/",
+		StatusCancelled:  "-",
+		StatusForwarded:  ">",
+		StatusQuestion:   "?",
+	}
+)
+
+// StatusOptions lists the names of the statuses.
+var StatusOptions = []string{"open", "done", "in-progress", "cancelled", "forwarded", "question"}
+
+// parseStatus reads the status from the mark between a checkbox's brackets,
+// where any whitespace leaves it open and x is done in either case.
+func parseStatus(mark string) Status {
+	if strings.TrimSpace(mark) == "" {
+		return StatusOpen
+	}
+	// full-width marks are typed with CJK input methods
+	if mark == "ｘ" || mark == "Ｘ" {
+		return StatusDone
+	}
+	for status, symbol := range statusSymbols {
+		if strings.EqualFold(symbol, mark) {
+			return status
+		}
+	}
+	return StatusOpen
+}
+
+// ParseStatus returns the status with the name, one of StatusOptions.
+func ParseStatus(name string) (Status, error) {
+	var status Status
+	err := status.UnmarshalText([]byte(name))
+	return status, err
+}
+
+// Symbol returns the mark written between the brackets of a checkbox with
+// the status.
+func (status Status) Symbol() string {
+	return statusSymbols[status]
+}
+
+func (status Status) String() string {
+	return statusNames[status]
+}
+
+func (status Status) MarshalText() ([]byte, error) {
+	return []byte(status.String()), nil
+}
+
+func (status *Status) UnmarshalText(text []byte) error {
+	for s, name := range statusNames {
+		if name == string(text) {
+			*status = s
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown status '%s'", text)
+}
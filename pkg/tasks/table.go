@@ -0,0 +1,154 @@
+package tasks
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// LayoutList writes the tasks in markdown reports as a list of
+	// checkboxes, with subtasks nested under their parents.
+	LayoutList = "list"
+	// LayoutTable writes the tasks in markdown reports as a table, one row
+	// per task with subtasks following their parents, in TableColumns.
+	LayoutTable = "table"
+)
+
+// LayoutOptions lists the ways markdown reports can lay out tasks.
+var LayoutOptions = []string{LayoutList, LayoutTable}
+
+// DefaultTableColumns are the columns of tables when none are selected.
+var DefaultTableColumns = []string{"status", "date", "task", "source", "tags"}
+
+// TableColumnOptions lists the columns tables can have.
+var TableColumnOptions = []string{"status", "date", "due", "task", "source", "tags", "priority", "estimate", "assignees", "id"}
+
+// tableStatuses mark each task's status in the status column.
+var tableStatuses = map[Status]string{
+	StatusOpen:       "⬜",
+	StatusDone:       "✅",
+	StatusInProgress: "🔄",
+	StatusCancelled:  "❌",
+	StatusForwarded:  "➡️",
+	StatusQuestion:   "❓",
+}
+
+// CheckTableColumns returns an error naming the first column that isn't one
+// of TableColumnOptions.
+func CheckTableColumns(columns []string) error {
+	for _, column := range columns {
+		if !contains(TableColumnOptions, column) {
+			return fmt.Errorf("unknown table column '%s'", column)
+		}
+	}
+	return nil
+}
+
+// writeTable writes the tasks and their subtasks as the rows of a markdown
+// table with a header row, in the TableColumns or DefaultTableColumns.
+// Subtasks are marked with ↳, indented to their depth.
+func (tasks Tasks) writeTable(out *strings.Builder, all []Task) {
+	columns := tasks.TableColumns
+	if len(columns) == 0 {
+		columns = DefaultTableColumns
+	}
+	headers := []string{}
+	separators := []string{}
+	for _, column := range columns {
+		headers = append(headers, tableHeader(column))
+		separators = append(separators, "---")
+	}
+	out.WriteString("| " + strings.Join(headers, " | ") + " |\n")
+	out.WriteString("| " + strings.Join(separators, " | ") + " |\n")
+
+	var writeRows func(all []Task, depth int)
+	writeRows = func(all []Task, depth int) {
+		for _, task := range all {
+			if depth > 0 && tasks.hidden(task) {
+				continue
+			}
+			cells := []string{}
+			for _, column := range columns {
+				cells = append(cells, escapeTableCell(tasks.tableCell(task, column, depth)))
+			}
+			out.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+			writeRows(task.Subtasks, depth+1)
+		}
+	}
+	writeRows(all, 0)
+}
+
+// tableHeader titles the column, as in Status for status and ID for id.
+func tableHeader(column string) string {
+	if column == "id" {
+		return "ID"
+	}
+	return strings.ToUpper(column[:1]) + column[1:]
+}
+
+// tableCell returns the task's markdown for the column, where the task cell
+// holds its linked text, marked as it is in lists, and its ID in a comment.
+func (tasks Tasks) tableCell(task Task, column string, depth int) string {
+	switch column {
+	case "status":
+		if task.Complete {
+			return tableStatuses[StatusDone]
+		}
+		return tableStatuses[task.Status]
+	case "date":
+		if task.Undated() {
+			return ""
+		}
+		return task.Date.Format(yearMonthDayLayout)
+	case "due":
+		if task.Due == nil {
+			return ""
+		}
+		return task.Due.Format(yearMonthDayLayout)
+	case "task":
+		link := tasks.taskLink(task)
+		if task.Cancelled() {
+			link = fmt.Sprintf("~~%s~~", link)
+		}
+		cell := link
+		if task.Priority != PriorityNone {
+			cell = task.Priority.Badge() + " " + cell
+		}
+		if depth > 0 {
+			cell = strings.Repeat("&emsp;", depth-1) + "↳ " + cell
+		}
+		if task.Overdue() {
+			cell += " **overdue**"
+		}
+		if marker := tasks.staleMarker(task); marker != "" {
+			cell += " " + marker
+		}
+		if completed, total := task.Progress(); tasks.Rollup && total > 0 {
+			cell += fmt.Sprintf(" (%d/%d)", completed, total)
+		}
+		return fmt.Sprintf("%s <!-- id:%s -->", cell, task.ID)
+	case "source":
+		return fmt.Sprintf("%s:%d", task.FilePath, task.Line)
+	case "tags":
+		return strings.Join(task.Tags, " ")
+	case "priority":
+		return task.Priority.String()
+	case "estimate":
+		if task.Estimate == 0 {
+			return ""
+		}
+		return FormatEstimate(task.Estimate)
+	case "assignees":
+		return strings.Join(task.Assignees, ", ")
+	case "id":
+		return task.ID
+	}
+	return ""
+}
+
+// escapeTableCell keeps pipes and line breaks in the cell from ending it or
+// its row.
+func escapeTableCell(cell string) string {
+	cell = strings.ReplaceAll(cell, "|", `\|`)
+	return strings.ReplaceAll(cell, "\n", " ")
+}
@@ -0,0 +1,265 @@
+// Package tasks finds Github-formatted tasks in markdown files and renders
+// them as an aggregated report.
+package tasks
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// FileMeta describes a markdown file being parsed for tasks.
+type FileMeta struct {
+	// BirthTime is when the file was created, or the zero time where the
+	// platform or filesystem doesn't record it.
+	BirthTime time.Time
+	Date      *time.Time
+	// DisplayPath is the path written to tasks found in the file, defaulting
+	// to Path when empty.
+	DisplayPath string
+	// LineDates date the 1-based lines of the file, such as by the commits
+	// that added them, for tasks that nothing in the file dates.
+	LineDates map[int]time.Time
+	ModTime   time.Time
+	Name      string
+	Path      string
+	Root      string
+	Size      int64
+}
+
+type Tasks struct {
+	// AnchorStyle is how links point to the header a task is under, one of
+	// AnchorStyleOptions. The zero value uses GitHub's anchors.
+	AnchorStyle string
+	// Breadcrumbs shows the headers each task is under after it, as in
+	// Project > Sprint 12 > Backlog.
+	Breadcrumbs bool
+	// Chart is one of ChartOptions to draw at the top of markdown reports, or
+	// empty for none.
+	Chart string
+	// CollapseCompleted folds the completed tasks at the end of each section
+	// of markdown and html reports into a block that is opened on demand.
+	CollapseCompleted bool
+	// DateFormat is the Go time layout that date and due date sections are
+	// titled with, defaulting to 2006-01-02.
+	DateFormat string
+	// Editor is the app LinkStyleEditor links open tasks in, one of
+	// EditorOptions. The zero value links to Visual Studio Code.
+	Editor string
+	// GroupBy is one of GroupByOptions, defaulting to GroupByDate.
+	GroupBy string
+	// Undated is where sections by date put the tasks without one, one of
+	// UndatedOptions, defaulting to UndatedLast.
+	Undated string
+	// Layout is how markdown reports write tasks, one of LayoutOptions,
+	// defaulting to LayoutList.
+	Layout string
+	// LinkBase is prepended to task file paths in markdown and html links,
+	// such as ../ for a report written in a subdirectory of the root.
+	LinkBase string
+	// LinkStyle is how markdown reports link tasks to their source, one of
+	// LinkStyleOptions. The zero value writes markdown links.
+	LinkStyle string
+	// NewestFirst orders sections by date, week, month, quarter, or due date
+	// from the newest to the oldest.
+	NewestFirst bool
+	// Omitted counts the tasks left out to keep the report short, as by
+	// Recent, noted at the end of markdown and html reports when not zero.
+	Omitted         int
+	OutputCompleted bool
+	// Progress shows how many of each section's tasks are complete, with a
+	// progress bar, under its header, and of all tasks at the top of markdown
+	// reports.
+	Progress bool
+	// Problems are the paths the scan couldn't fully read, listed at the end
+	// of markdown and html reports.
+	Problems []Problem
+	// ShowCancelled outputs cancelled tasks, which are otherwise left out, in a
+	// section of their own.
+	ShowCancelled bool
+	// Rollup shows the completion progress of each parent task's subtasks.
+	Rollup bool
+	// StaleAfter, when not zero, marks incomplete tasks dated more than this
+	// many days ago with ⚠️ and their age in markdown and html reports.
+	StaleAfter int
+	// SourceBase is the absolute directory task file paths are relative to,
+	// for LinkStyleEditor links.
+	SourceBase string
+	// TableColumns are the columns of LayoutTable tables, of
+	// TableColumnOptions, defaulting to DefaultTableColumns.
+	TableColumns []string
+	Tasks        []Task
+}
+
+type Task struct {
+	// Assignees are the people the task's @mentions name, without the @.
+	Assignees   []string   `json:"assignees,omitempty"`
+	Complete    bool       `json:"complete"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	// Context holds the lines around the task, or the list item it is nested
+	// under, when parsed with ParseOptions.Context.
+	Context []string   `json:"context,omitempty"`
+	Date    time.Time  `json:"date"`
+	Due     *time.Time `json:"due,omitempty"`
+	// Estimate is the effort written on the task, as in ⏱ 2h, est: 30m, or
+	// [estimate:: 1d].
+	Estimate time.Duration `json:"estimate,omitempty"`
+	// Fields holds the Dataview inline fields written on the task, as in
+	// [owner:: bob], by key.
+	Fields   map[string]string `json:"fields,omitempty"`
+	FilePath string            `json:"file"`
+	// FileTitle is the title set in the front matter of the task's note.
+	FileTitle string     `json:"fileTitle,omitempty"`
+	FirstSeen *time.Time `json:"firstSeen,omitempty"`
+	// Headers are the headers the task is under, from the highest level to
+	// the lowest, the last being PreviousHeader.
+	Headers []string `json:"headers,omitempty"`
+	// HeaderOccurrence counts the earlier headers in the file matching the
+	// task's header, to tell repeated headers apart.
+	HeaderOccurrence int        `json:"headerOccurrence,omitempty"`
+	ID               string     `json:"id"`
+	LastSeen         *time.Time `json:"lastSeen,omitempty"`
+	Line             int        `json:"line"`
+	PreviousHeader   string     `json:"header"`
+	Priority         Priority   `json:"priority,omitempty"`
+	// Properties holds the front matter fields of the task's note.
+	Properties map[string]string `json:"properties,omitempty"`
+	// Recurrence is the task's repeat rule, such as "every monday".
+	Recurrence string `json:"recurrence,omitempty"`
+	// RecurrenceOf is the ID of the recurring task this is an upcoming
+	// instance of.
+	RecurrenceOf string   `json:"recurrenceOf,omitempty"`
+	Sources      []string `json:"sources,omitempty"`
+	// Start is the date work on the task is planned to begin, as in
+	// 🛫 2024-03-01, start: 2024-03-01, or [start:: 2024-03-01].
+	Start *time.Time `json:"start,omitempty"`
+	// Status is the task's state. Complete is set when it is StatusDone.
+	Status   Status   `json:"status"`
+	Subtasks []Task   `json:"subtasks,omitempty"`
+	Tags     []string `json:"tags"`
+	Text     string   `json:"text"`
+}
+
+const (
+	DefaultOutputFilename = `TASKS.md`
+	yearMonthDayLayout    = "2006-01-02"
+)
+
+// CompletedCount counts completed tasks, including subtasks.
+func (tasks Tasks) CompletedCount() int {
+	completed, _ := countTasks(tasks.Tasks)
+	return completed
+}
+
+func (tasks Tasks) IncompleteCount() int {
+	return tasks.TotalCount() - tasks.CompletedCount()
+}
+
+// TotalCount counts all tasks, including subtasks, except cancelled tasks,
+// which count as neither complete nor incomplete.
+func (tasks Tasks) TotalCount() int {
+	_, total := countTasks(tasks.Tasks)
+	return total
+}
+
+// Visible returns the tasks to output, leaving out completed tasks and their
+// subtasks unless OutputCompleted is set, and cancelled tasks and their
+// subtasks unless ShowCancelled is set.
+func (tasks Tasks) Visible() []Task {
+	return tasks.visible(tasks.Tasks)
+}
+
+func (tasks Tasks) visible(all []Task) []Task {
+	visible := []Task{}
+	for _, task := range all {
+		if tasks.hidden(task) {
+			continue
+		}
+		task.Subtasks = tasks.visible(task.Subtasks)
+		visible = append(visible, task)
+	}
+	return visible
+}
+
+// hidden reports whether the task, and so its subtasks, is left out of
+// output.
+func (tasks Tasks) hidden(task Task) bool {
+	return (task.Complete && !tasks.OutputCompleted) || (task.Cancelled() && !tasks.ShowCancelled)
+}
+
+// Cancelled reports whether the task was abandoned, as marked by [-].
+func (task Task) Cancelled() bool {
+	return task.Status == StatusCancelled
+}
+
+// taskID returns a stable identifier for the task at the line of the file, so
+// reports and other tools can refer back to it.
+func taskID(filePath string, line int, text string) string {
+	hash := sha1.Sum([]byte(fmt.Sprintf("%s:%d:%s", filePath, line, text)))
+	return hex.EncodeToString(hash[:])[:12]
+}
+
+// Flatten returns the tasks and all of their subtasks in file order.
+func Flatten(all []Task) []Task {
+	flat := []Task{}
+	for _, task := range all {
+		flat = append(flat, task)
+		flat = append(flat, Flatten(task.Subtasks)...)
+	}
+	return flat
+}
+
+// completionDate returns when the task was completed. Without a recorded
+// completion date, the task's own date is used.
+func (task Task) completionDate() time.Time {
+	if task.CompletedAt != nil {
+		return *task.CompletedAt
+	}
+	return task.Date
+}
+
+// Undated reports whether neither a header, the front matter, the file's name,
+// nor its creation time gave the task a date.
+func (task Task) Undated() bool {
+	return task.Date.IsZero()
+}
+
+// Overdue reports whether the task is incomplete, and not cancelled, and due
+// before today.
+func (task Task) Overdue() bool {
+	return !task.Complete && !task.Cancelled() && task.Due != nil && task.Due.Format(yearMonthDayLayout) < time.Now().Format(yearMonthDayLayout)
+}
+
+// Progress returns how many of the task's subtasks, at any depth, are complete.
+func (task Task) Progress() (completed, total int) {
+	return countTasks(task.Subtasks)
+}
+
+func countTasks(tasks []Task) (completed, total int) {
+	for _, task := range tasks {
+		if task.Complete {
+			completed++
+		}
+		if !task.Cancelled() {
+			total++
+		}
+		subCompleted, subTotal := countTasks(task.Subtasks)
+		completed += subCompleted
+		total += subTotal
+	}
+	return completed, total
+}
+
+// SortByDate sorts by date, keeping original order of equal elements. Undated
+// tasks sort last.
+func (tasks Tasks) SortByDate() {
+	sort.SliceStable(tasks.Tasks, func(i, j int) bool {
+		task, other := tasks.Tasks[i], tasks.Tasks[j]
+		if task.Undated() || other.Undated() {
+			return !task.Undated() && other.Undated()
+		}
+		return task.Date.Unix() < other.Date.Unix()
+	})
+}
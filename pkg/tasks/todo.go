@@ -0,0 +1,45 @@
+package tasks
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultTodoKeywords are the usual keywords marking TODO lines, for use as
+// ParseOptions.TodoKeywords.
+var DefaultTodoKeywords = []string{"TODO", "FIXME"}
+
+// todoPattern matches a line, list item, or HTML comment starting with one of
+// the keywords and a colon, such as "TODO: call bob" or "- FIXME(ann): typo",
+// capturing the keyword and the text after it.
+func todoPattern(keywords []string) *regexp.Regexp {
+	quoted := []string{}
+	for _, keyword := range keywords {
+		quoted = append(quoted, regexp.QuoteMeta(keyword))
+	}
+	return regexp.MustCompile(`^\s*(?:(?:[-+*]|\d+[.)])\s+)?(?:<!--\s*)?(` + strings.Join(quoted, "|") + `)(?:\([^)]*\))?:\s*(.*?)\s*(?:-->)?\s*$`)
+}
+
+// parseTodo reads a TODO line as an incomplete task tagged with its keyword.
+func parseTodo(pattern *regexp.Regexp, date time.Time, lastHeader, filePath, line string) (*Task, bool) {
+	match := pattern.FindStringSubmatch(line)
+	if match == nil || match[2] == "" {
+		return nil, false
+	}
+
+	text := match[2]
+	return &Task{
+		Date:           date,
+		Due:            parseDate(duePattern, text, nil),
+		Estimate:       parseEstimate(text),
+		Fields:         parseFields(text),
+		FilePath:       filePath,
+		PreviousHeader: lastHeader,
+		Priority:       parsePriority(text),
+		Recurrence:     parseRecurrence(text),
+		Start:          parseDate(startPattern, text, nil),
+		Tags:           append(parseTags(text), "#"+match[1]),
+		Text:           text,
+	}, true
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+// pluginPrefix starts the name of the program run for each -plugin, so
+// -plugin org runs task-aggregator-plugin-org from the PATH.
+const pluginPrefix = "task-aggregator-plugin-"
+
+// pluginFormats maps the output formats added by plugins to the file each
+// writes when no output filename is given.
+var pluginFormats = map[string]string{}
+
+// pluginDescription is what a plugin prints when run with describe: the
+// extensions of the notes it parses, and the output formats it renders, each
+// with the file written when no output filename is given.
+type pluginDescription struct {
+	Extensions []string          `json:"extensions"`
+	Formats    map[string]string `json:"formats"`
+}
+
+// execPlugin is a plugin program, which is run once for each file it parses
+// or output it renders:
+//
+//	task-aggregator-plugin-org parse notes/todo.org
+//
+// reads the note from stdin and prints its tasks as a JSON array, in the form
+// of -format json, and
+//
+//	task-aggregator-plugin-org render org
+//
+// reads the report, with its tasks, sections, and counts, as JSON from stdin
+// and prints the output. A plugin fails by exiting with an error, printing
+// why to stderr.
+type execPlugin struct {
+	name string
+	path string
+}
+
+// loadPlugins finds the program of each -plugin and registers the parsers and
+// renderers it describes.
+func loadPlugins(names []string) error {
+	for _, name := range names {
+		path, err := exec.LookPath(pluginPrefix + name)
+		if err != nil {
+			return fmt.Errorf("-plugin %s: %w", name, err)
+		}
+		plugin := execPlugin{name: name, path: path}
+
+		output, err := plugin.run(nil, "describe")
+		if err != nil {
+			return err
+		}
+		description := pluginDescription{}
+		if err := json.Unmarshal(output, &description); err != nil {
+			return fmt.Errorf("-plugin %s: invalid description: %w", name, err)
+		}
+		for _, extension := range description.Extensions {
+			if !strings.HasPrefix(extension, ".") {
+				extension = "." + extension
+			}
+			tasks.RegisterParser(extension, plugin)
+		}
+		for format, filename := range description.Formats {
+			if _, ok := defaultOutputFilenames[format]; ok {
+				return fmt.Errorf("-plugin %s: format '%s' is built in", name, format)
+			}
+			if filename == "" {
+				filename = "tasks." + format
+			}
+			pluginFormats[format] = filename
+			tasks.RegisterRenderer(format, pluginRenderer{format: format, plugin: plugin})
+		}
+	}
+	return nil
+}
+
+// Parse runs the plugin to read the tasks from the note.
+func (plugin execPlugin) Parse(r io.Reader, meta tasks.FileMeta, options tasks.ParseOptions) (tasks.Tasks, error) {
+	output, err := plugin.run(r, "parse", meta.DisplayPath)
+	if err != nil {
+		return tasks.Tasks{}, err
+	}
+	parsed := tasks.Tasks{}
+	if err := json.Unmarshal(output, &parsed.Tasks); err != nil {
+		return tasks.Tasks{}, fmt.Errorf("-plugin %s: invalid tasks: %w", plugin.name, err)
+	}
+	return parsed, nil
+}
+
+// pluginRenderer renders one of the formats of a plugin.
+type pluginRenderer struct {
+	format string
+	plugin execPlugin
+}
+
+// Render runs the plugin to write the report in the format.
+func (renderer pluginRenderer) Render(w io.Writer, aggregated tasks.Tasks) error {
+	report, err := json.Marshal(aggregated.Report())
+	if err != nil {
+		return err
+	}
+	output, err := renderer.plugin.run(bytes.NewReader(report), "render", renderer.format)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(output)
+	return err
+}
+
+// run runs the plugin with the arguments and input, returning what it prints.
+func (plugin execPlugin) run(input io.Reader, args ...string) ([]byte, error) {
+	command := exec.Command(plugin.path, args...)
+	command.Stdin = input
+	output, err := command.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("-plugin %s %s: %s", plugin.name, args[0], strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("-plugin %s: %w", plugin.name, err)
+	}
+	return output, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandReview = "review"
+
+func setupReview(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	asJSON := flags.Bool("json", false, "true to print the review as JSON instead of markdown (default=false)")
+	week := flags.String("week", "", "ISO week to review, such as 2024-W07 (default=this week)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		filter, err := options.filter()
+		if err != nil {
+			return err
+		}
+		if *week == "" {
+			*week = tasks.WeekName(time.Now())
+		}
+		monday, err := tasks.ParseWeek(*week, time.Local)
+		if err != nil {
+			return fmt.Errorf("-week: %w", err)
+		}
+
+		scanned, err := scan(options)
+		if err != nil {
+			return err
+		}
+		review := scanned.Filter(filter).Review(monday)
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(review)
+		}
+		return review.WriteMarkdown(os.Stdout)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+// stdinPath is the root, and the path of the file made for it, that reads
+// markdown from standard input.
+const stdinPath = "-"
+
+// scanJob is a file found by the walker, numbered in walk order so results
+// can be merged back into a deterministic order.
+type scanJob struct {
+	file  tasks.FileMeta
+	index int
+}
+
+type scanResult struct {
+	// cached is set when the tasks were read from the cache rather than
+	// parsed.
+	cached bool
+	err    error
+	job    scanJob
+	tasks  []tasks.Task
+}
+
+// scanRoots walks the roots, feeding the markdown files found to a pool of
+// parsers, and returns the tasks of every file in walk order. Unchanged files
+// are read from the cache, and every file's tasks are stored in nextCache.
+// Roots, directories, and files that can't be read are skipped, and returned
+// as problems in order of path along with files whose long lines were skipped.
+func scanRoots(options Options, cache, nextCache Cache) ([]tasks.Task, []tasks.Problem) {
+	jobs := make(chan scanJob)
+	results := make(chan scanResult)
+
+	walkProblems := []tasks.Problem{}
+	go func() {
+		defer close(jobs)
+		index := 0
+		for _, root := range options.Roots {
+			if root == stdinPath {
+				jobs <- scanJob{file: options.stdinFile(), index: index}
+				index++
+				continue
+			}
+			err := tasks.WalkMarkdownFiles(root, options.walkOptions(), func(file tasks.FileMeta) error {
+				file.DisplayPath = file.RelativePath(len(options.Roots) > 1)
+				jobs <- scanJob{file: file, index: index}
+				index++
+				return nil
+			})
+			var walkErr *tasks.WalkError
+			if errors.As(err, &walkErr) {
+				logLimits(walkErr)
+				for _, skipped := range walkErr.Errors {
+					walkProblems = append(walkProblems, newProblem("", skipped))
+				}
+			} else if err != nil {
+				walkProblems = append(walkProblems, newProblem(root, err))
+			}
+		}
+	}()
+
+	workers := options.Jobs
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				results <- parseJob(job, cache, options.parseOptions())
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	parsed := []scanResult{}
+	problems := []tasks.Problem{}
+	for result := range results {
+		var longLines *tasks.LongLinesError
+		if errors.As(result.err, &longLines) {
+			problems = append(problems, tasks.Problem{Message: fmt.Sprintf("skipped lines longer than -max-line-length: %v", longLines.Lines), Path: result.job.file.DisplayPath})
+		} else if result.err != nil {
+			problems = append(problems, newProblem(result.job.file.DisplayPath, result.err))
+			continue
+		}
+		slog.Debug("read file", "file", result.job.file.DisplayPath, "tasks", len(tasks.Flatten(result.tasks)), "cached", result.cached)
+		if result.job.file.Path != stdinPath {
+			nextCache.store(result.job.file, result.tasks)
+		}
+		parsed = append(parsed, result)
+	}
+
+	// results is only closed once the walker has finished, so walkProblems is
+	// safe to read here
+	problems = append(problems, walkProblems...)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+
+	sort.Slice(parsed, func(i, j int) bool {
+		return parsed[i].job.index < parsed[j].job.index
+	})
+	found := []tasks.Task{}
+	for _, result := range parsed {
+		found = append(found, result.tasks...)
+	}
+	return found, problems
+}
+
+// newProblem describes the error reading the path. Errors naming a path are
+// described without it, and path is taken from them when it's empty.
+func newProblem(path string, err error) tasks.Problem {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		if path == "" {
+			path = pathErr.Path
+		}
+		return tasks.Problem{Message: pathErr.Err.Error(), Path: path}
+	}
+	return tasks.Problem{Message: err.Error(), Path: path}
+}
+
+// stdinFile describes the markdown read from standard input, named by
+// -stdin-name and dated, as well as modified, today.
+func (options Options) stdinFile() tasks.FileMeta {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return tasks.FileMeta{Date: &today, DisplayPath: options.StdinName, ModTime: now, Name: path.Base(options.StdinName), Path: stdinPath}
+}
+
+// logSkipped logs the paths a walk could not read or left out by the -max
+// flags, returning any other error.
+func logSkipped(err error) error {
+	var walkErr *tasks.WalkError
+	if !errors.As(err, &walkErr) {
+		return err
+	}
+	for _, skipped := range walkErr.Errors {
+		slog.Warn("skipping unreadable path", "error", skipped)
+	}
+	logLimits(walkErr)
+	return nil
+}
+
+// logLimits logs the paths a walk left out by the -max flags.
+func logLimits(walkErr *tasks.WalkError) {
+	for _, file := range walkErr.LargeFiles {
+		slog.Warn("skipping file larger than -max-file-size", "file", file)
+	}
+	if walkErr.DeepDirectories > 0 {
+		slog.Warn("skipped directories deeper than -max-depth", "directories", walkErr.DeepDirectories)
+	}
+	if walkErr.MaxFilesReached {
+		slog.Warn("stopped scanning the root at -max-files")
+	}
+}
+
+func parseJob(job scanJob, cache Cache, parseOptions tasks.ParseOptions) scanResult {
+	if job.file.Path == stdinPath {
+		parsed, err := tasks.ParseFile(os.Stdin, job.file, parseOptions)
+		return scanResult{err: err, job: job, tasks: parsed.Tasks}
+	}
+	if cached, ok := cache.lookup(job.file); ok {
+		return scanResult{cached: true, job: job, tasks: cached}
+	}
+
+	parsed, err := tasks.ParseFilePath(job.file, parseOptions)
+	return scanResult{err: err, job: job, tasks: parsed.Tasks}
+}
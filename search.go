@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandSearch = "search"
+
+var (
+	// matchStyle highlights the text a search matched, and locationStyle the
+	// file and line of each task found. Colors are left out when stdout isn't
+	// a terminal or NO_COLOR is set.
+	matchStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("3"))
+	locationStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("5"))
+)
+
+func setupSearch(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	noColor := flags.Bool("no-color", false, "true to print matches without colors, which are also left out when NO_COLOR is set (default=false)")
+	useRegexp := flags.Bool("regexp", false, "true to read the query as a Go regular expression, which is case-sensitive unless it starts with (?i), instead of text matched in any case (default=false)")
+
+	return func(args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("search needs the text to look for, as in: search invoice [directories]")
+		}
+		query := regexp.QuoteMeta(args[0])
+		if *useRegexp {
+			query = args[0]
+		} else {
+			query = "(?i)" + query
+		}
+		pattern, err := regexp.Compile(query)
+		if err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		if err := options.prepare(args[1:]); err != nil {
+			return err
+		}
+		setColor(!*noColor)
+
+		found, err := options.searchable()
+		if err != nil {
+			return err
+		}
+		for _, task := range found {
+			if pattern.MatchString(task.Text) {
+				printMatch(task, pattern)
+			}
+		}
+		return nil
+	}
+}
+
+// searchable returns the tasks passing the filter flags, of any status and
+// not nested, read from the -store when there is one instead of scanning the
+// roots.
+func (options Options) searchable() ([]tasks.Task, error) {
+	filter, err := options.filter()
+	if err != nil {
+		return nil, err
+	}
+	var all []tasks.Task
+	if options.Store == "" {
+		scanned, err := scan(options)
+		if err != nil {
+			return nil, err
+		}
+		all = tasks.Flatten(scanned.Tasks)
+	} else if all, err = storeTasks(options.storePath()); err != nil {
+		return nil, err
+	}
+
+	found := []tasks.Task{}
+	for _, task := range all {
+		if filter.Match(task) {
+			found = append(found, task)
+		}
+	}
+	return found, nil
+}
+
+// printMatch prints the task found with its file and line first, as grep
+// does, and the text the pattern matched highlighted.
+func printMatch(task tasks.Task, pattern *regexp.Regexp) {
+	text := pattern.ReplaceAllStringFunc(task.Text, func(match string) string {
+		return matchStyle.Render(match)
+	})
+	location := locationStyle.Render(fmt.Sprintf("%s:%d", task.FilePath, task.Line))
+	fmt.Printf("%s: [%s] %s\n", location, task.Status.Symbol(), strings.TrimSpace(text))
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	commandServe     = "serve"
+	defaultServeAddr = "localhost:8080"
+	// dashboardRefresh is how often, in seconds, the dashboard page reloads.
+	dashboardRefresh = 30
+	// defaultPageSize is the number of tasks /api/tasks returns when the
+	// request gives no limit, and maxPageSize the most it can ask for.
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// apiFile is a note listed by /api/files, with the number of tasks in it.
+type apiFile struct {
+	File       string `json:"file"`
+	Incomplete int    `json:"incomplete"`
+	Title      string `json:"title,omitempty"`
+	Total      int    `json:"total"`
+}
+
+type ServeOptions struct {
+	Addr     string
+	Interval time.Duration
+}
+
+func setupServe(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	serveOptions := ServeOptions{}
+	defineScanFlags(flags, &options)
+	defineRenderFlags(flags, &options)
+	flags.StringVar(&serveOptions.Addr, "addr", defaultServeAddr, fmt.Sprintf("address to listen on (default=%s)", defaultServeAddr))
+	flags.DurationVar(&serveOptions.Interval, "interval", 0, "how often to re-scan, or 0 to re-scan when markdown files change (default=0)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		return serve(options, serveOptions)
+	}
+}
+
+// dashboard holds the most recently scanned tasks for the server's handlers.
+type dashboard struct {
+	events  eventHub
+	mutex   sync.RWMutex
+	options Options
+	tasks   tasks.Tasks
+}
+
+// serve runs an HTTP server with an HTML dashboard at / and a JSON API of the
+// tasks at /api/tasks, their statistics at /api/stats, and the notes holding
+// them at /api/files, re-scanning on an interval or whenever markdown files
+// change. The changes each re-scan finds are streamed to clients of /events.
+func serve(options Options, serveOptions ServeOptions) error {
+	board := &dashboard{options: options}
+	if err := board.scan(); err != nil {
+		return err
+	}
+
+	if serveOptions.Interval > 0 {
+		go func() {
+			for range time.Tick(serveOptions.Interval) {
+				board.rescan()
+			}
+		}()
+	} else {
+		go watch(options, nil, board.rescan, nil)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", board.handleDashboard)
+	mux.HandleFunc("/api/files", board.handleFiles)
+	mux.HandleFunc("/api/stats", board.handleStats)
+	mux.HandleFunc("/api/tasks", board.handleTasks)
+	mux.HandleFunc("/events", board.handleEvents)
+
+	slog.Info("serving tasks", "url", fmt.Sprintf("http://%s/", serveOptions.Addr))
+	return http.ListenAndServe(serveOptions.Addr, mux)
+}
+
+// rescan scans the roots again, keeping the tasks found before when it fails,
+// as it can with -strict, so the server keeps running.
+func (board *dashboard) rescan() {
+	if err := board.scan(); err != nil {
+		slog.Warn("can't rescan, serving the tasks found before", "error", err)
+	}
+}
+
+// scan replaces the tasks served with those found in the roots, publishing
+// the changes to clients of /events.
+func (board *dashboard) scan() error {
+	scanned, err := collect(board.options)
+	if err != nil {
+		return err
+	}
+
+	board.mutex.Lock()
+	previous := board.tasks
+	board.tasks = scanned
+	board.mutex.Unlock()
+	board.events.publish(taskEvents(tasks.Flatten(previous.Tasks), tasks.Flatten(scanned.Tasks)))
+	return nil
+}
+
+func (board *dashboard) current() tasks.Tasks {
+	board.mutex.RLock()
+	defer board.mutex.RUnlock()
+	return board.tasks
+}
+
+func (board *dashboard) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Refresh", strconv.Itoa(dashboardRefresh))
+	if err := writeHTML(w, board.current(), board.options.Template); err != nil {
+		slog.Error("can't render dashboard", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleTasks writes the tasks as JSON, filtered by the query parameters
+// apiFilter reads and paged by limit and offset. The total number of tasks is
+// sent in X-Total-Count, with a Link to the next page when there is one.
+// Completed and cancelled tasks are included when the query asks for a
+// status, and completed tasks with completed=true.
+func (board *dashboard) handleTasks(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	filter, err := apiFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, offset, err := apiPage(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	found := board.current().Filter(filter)
+	if query.Has("status") {
+		found.OutputCompleted = true
+		found.ShowCancelled = true
+	}
+	if query.Get("completed") == "true" {
+		found.OutputCompleted = true
+	}
+	visible := found.Visible()
+	total := len(visible)
+	found.Tasks = visible[min(offset, total):min(offset+limit, total)]
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if offset+limit < total {
+		next := *r.URL
+		nextQuery := r.URL.Query()
+		nextQuery.Set("offset", strconv.Itoa(offset+limit))
+		next.RawQuery = nextQuery.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+	body := bytes.Buffer{}
+	if err := found.WriteJSON(&body); err != nil {
+		slog.Warn("can't write tasks", "error", err)
+		return
+	}
+	writeJSONResponse(w, r, body.Bytes())
+}
+
+// handleStats writes the statistics of the tasks as JSON, as stats -json
+// prints them.
+func (board *dashboard) handleStats(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	body, err := json.MarshalIndent(board.current().Statistics(time.Now()), "", "  ")
+	if err != nil {
+		slog.Warn("can't write statistics", "error", err)
+		return
+	}
+	writeJSONResponse(w, r, body)
+}
+
+// handleFiles writes the notes holding tasks as JSON, in order of their
+// paths, with how many tasks each holds and how many of those are incomplete.
+func (board *dashboard) handleFiles(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	byPath := map[string]*apiFile{}
+	for _, task := range tasks.Flatten(board.current().Tasks) {
+		file, ok := byPath[task.FilePath]
+		if !ok {
+			file = &apiFile{File: task.FilePath, Title: task.FileTitle}
+			byPath[task.FilePath] = file
+		}
+		file.Total++
+		if !task.Complete && !task.Cancelled() {
+			file.Incomplete++
+		}
+	}
+	files := []apiFile{}
+	for _, file := range byPath {
+		files = append(files, *file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].File < files[j].File
+	})
+
+	body, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		slog.Warn("can't write files", "error", err)
+		return
+	}
+	writeJSONResponse(w, r, body)
+}
+
+// apiFilter reads a filter from the query parameters status, tag,
+// exclude-tag, and assignee, which may be repeated, and the YYYY-MM-DD dates
+// since, until, due-by, and completed-since, which work like the flags of the
+// same names.
+func apiFilter(query url.Values) (tasks.Filter, error) {
+	filter := tasks.Filter{
+		Assignees:   query["assignee"],
+		ExcludeTags: query["exclude-tag"],
+		Tags:        query["tag"],
+	}
+	for _, name := range query["status"] {
+		status, err := tasks.ParseStatus(name)
+		if err != nil {
+			return filter, err
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+	for _, date := range []struct {
+		name  string
+		value **time.Time
+	}{
+		{"completed-since", &filter.CompletedSince},
+		{"due-by", &filter.DueBy},
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		parsed, err := parseDateFlag(query.Get(date.name))
+		if err != nil {
+			return filter, fmt.Errorf("%s: %w", date.name, err)
+		}
+		*date.value = parsed
+	}
+	return filter, nil
+}
+
+// apiPage reads the limit and offset query parameters, defaulting to the
+// first defaultPageSize tasks.
+func apiPage(query url.Values) (limit, offset int, err error) {
+	limit = defaultPageSize
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be from 1 to %d", maxPageSize)
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("offset must be a number of tasks to skip")
+		}
+	}
+	return limit, offset, nil
+}
+
+// allowGet answers requests other than GET and HEAD with 405 Method Not
+// Allowed, reporting whether the request was one of them.
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD")
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// writeJSONResponse writes the JSON body with an ETag of its hash, or only
+// 304 Not Modified when the request's If-None-Match already holds the ETag,
+// so clients polling the API skip downloading unchanged tasks.
+func writeJSONResponse(w http.ResponseWriter, r *http.Request, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := fmt.Sprintf(`"%x"`, sum[:16])
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	if match := r.Header.Get("If-None-Match"); match == "*" || strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		slog.Warn("can't write response", "error", err)
+	}
+}
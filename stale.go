@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandStale = "stale"
+
+func setupStale(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	asJSON := flags.Bool("json", false, "true to print the buckets as JSON instead of tables (default=false)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		filter, err := options.filter()
+		if err != nil {
+			return err
+		}
+
+		scanned, err := scan(options)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		buckets := scanned.Filter(filter).Aging(now)
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(buckets)
+		}
+		return printAging(buckets, now)
+	}
+}
+
+// printAging prints each bucket's tasks with their age, date, and location,
+// oldest first.
+func printAging(buckets []tasks.AgeBucket, now time.Time) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for i, bucket := range buckets {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s (%d)\nAge\tTask\tDate\tLocation\n", bucket.Title, len(bucket.Tasks))
+		for _, task := range bucket.Tasks {
+			fmt.Fprintf(out, "%dd\t%s\t%s\t%s:%d\n", task.AgeDays(now), task.Text, task.Date.Format(yearMonthDayLayout), task.FilePath, task.Line)
+		}
+	}
+	return out.Flush()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandStats = "stats"
+
+func setupStats(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	asJSON := flags.Bool("json", false, "true to print the statistics as JSON instead of tables (default=false)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+
+		collected, err := collect(options)
+		if err != nil {
+			return err
+		}
+		statistics := collected.Statistics(time.Now())
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(statistics)
+		}
+		return printStatistics(statistics)
+	}
+}
+
+func printStatistics(statistics tasks.Statistics) error {
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(out, "Total:\t%d\n", statistics.Stats.Total)
+	fmt.Fprintf(out, "Complete:\t%d\n", statistics.Stats.Completed)
+	fmt.Fprintf(out, "Incomplete:\t%d\n", statistics.Stats.Incomplete)
+	fmt.Fprintf(out, "Percent:\t%d%%\n", statistics.Stats.Percent())
+	fmt.Fprintf(out, "Average open age:\t%.1f days\n", statistics.AverageAgeDays)
+	fmt.Fprintf(out, "Average time to complete:\t%.1f days\n", statistics.AverageDaysToComplete)
+
+	for _, section := range []struct {
+		title      string
+		breakdowns []tasks.Breakdown
+	}{
+		{"File", statistics.ByFile},
+		{"Tag", statistics.ByTag},
+		{"Week", statistics.ByWeek},
+		{"Month", statistics.ByMonth},
+	} {
+		if len(section.breakdowns) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s\tComplete\tTotal\tPercent\n", section.title)
+		for _, breakdown := range section.breakdowns {
+			fmt.Fprintf(out, "%s\t%d\t%d\t%d%%\n", breakdown.Name, breakdown.Stats.Completed, breakdown.Stats.Total, breakdown.Stats.Percent())
+		}
+	}
+
+	if len(statistics.LongestOpen) > 0 {
+		fmt.Fprintf(out, "\nLongest open\tDate\tLocation\n")
+		for _, task := range statistics.LongestOpen {
+			fmt.Fprintf(out, "%s\t%s\t%s:%d\n", task.Text, task.Date.Format(yearMonthDayLayout), task.FilePath, task.Line)
+		}
+	}
+
+	return out.Flush()
+}
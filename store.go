@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+	// registers the pure Go "sqlite" driver, so no sqlite3 command or cgo is
+	// needed
+	_ "modernc.org/sqlite"
+)
+
+// storeSchemeSQLite starts a -store naming a SQLite database file.
+const storeSchemeSQLite = "sqlite:"
+
+// storeSchema creates the tables of a store: tasks holds each task ever seen,
+// by its stable key, as it was last seen, and transitions records each time a
+// task appeared, changed status, or was removed.
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS tasks (
+	key TEXT PRIMARY KEY,
+	id TEXT NOT NULL,
+	file TEXT NOT NULL,
+	line INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	status TEXT NOT NULL,
+	complete INTEGER NOT NULL,
+	date TEXT,
+	due TEXT,
+	completed_at TEXT,
+	priority TEXT NOT NULL,
+	tags TEXT NOT NULL,
+	first_seen TEXT NOT NULL,
+	last_seen TEXT NOT NULL,
+	removed_at TEXT
+);
+CREATE TABLE IF NOT EXISTS transitions (
+	key TEXT NOT NULL,
+	file TEXT NOT NULL,
+	text TEXT NOT NULL,
+	from_status TEXT,
+	to_status TEXT NOT NULL,
+	at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS transitions_key ON transitions (key);
+`
+
+// storeColumns are the columns of the tasks table filled from a scan.
+const storeColumns = "key, id, file, line, text, status, complete, date, due, completed_at, priority, tags"
+
+// storeKey is the stable key of a task in the store, a hash of its changeKey,
+// so a task keeps it as it moves within its note or is checked off.
+func storeKey(task tasks.Task) string {
+	sum := sha256.Sum256([]byte(changeKey(task)))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// storePath returns the database file named by -store.
+func (options Options) storePath() string {
+	return strings.TrimPrefix(options.Store, storeSchemeSQLite)
+}
+
+// openStore opens the database, creating it and its tables if needed.
+func openStore(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("store %s: %w", dbPath, err)
+	}
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("store %s: %w", dbPath, err)
+	}
+	return db, nil
+}
+
+// updateStore upserts the tasks into the store, all in one transaction:
+// tasks not seen before are added, tasks seen before are updated, tasks no
+// longer found are marked removed, and each of those changes, or change of
+// status, is recorded as a transition at now.
+func updateStore(dbPath string, all []tasks.Task, now time.Time) error {
+	db, err := openStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("store %s: %w", dbPath, err)
+	}
+	// rolling back after the commit does nothing
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("CREATE TEMP TABLE scan (key TEXT PRIMARY KEY, id, file, line, text, status, complete, date, due, completed_at, priority, tags)"); err != nil {
+		return fmt.Errorf("store %s: %w", dbPath, err)
+	}
+	insert, err := tx.Prepare("INSERT OR IGNORE INTO scan VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("store %s: %w", dbPath, err)
+	}
+	defer insert.Close()
+	for _, task := range all {
+		var date, due, completedAt interface{}
+		if !task.Undated() {
+			date = task.Date.Format(time.RFC3339)
+		}
+		if task.Due != nil {
+			due = task.Due.Format(time.RFC3339)
+		}
+		if task.CompletedAt != nil {
+			completedAt = task.CompletedAt.Format(time.RFC3339)
+		}
+		if _, err := insert.Exec(storeKey(task), task.ID, task.FilePath, task.Line, task.Text, task.Status.String(),
+			task.Complete, date, due, completedAt, task.Priority.String(), strings.Join(task.Tags, " ")); err != nil {
+			return fmt.Errorf("store %s: %w", dbPath, err)
+		}
+	}
+
+	at := now.UTC().Format(time.RFC3339)
+	for _, statement := range []string{`
+INSERT INTO transitions (key, file, text, from_status, to_status, at)
+	SELECT scan.key, scan.file, scan.text, CASE WHEN tasks.removed_at IS NULL THEN tasks.status END, scan.status, ?1
+	FROM scan LEFT JOIN tasks ON tasks.key = scan.key
+	WHERE tasks.key IS NULL OR tasks.removed_at IS NOT NULL OR tasks.status != scan.status`, `
+INSERT INTO transitions (key, file, text, from_status, to_status, at)
+	SELECT key, file, text, status, 'removed', ?1 FROM tasks
+	WHERE removed_at IS NULL AND key NOT IN (SELECT key FROM scan)`, `
+UPDATE tasks SET removed_at = ?1 WHERE removed_at IS NULL AND key NOT IN (SELECT key FROM scan)`, `
+INSERT INTO tasks (` + storeColumns + `, first_seen, last_seen)
+	SELECT ` + storeColumns + `, ?1, ?1 FROM scan WHERE true
+	ON CONFLICT (key) DO UPDATE SET id = excluded.id, file = excluded.file, line = excluded.line, text = excluded.text,
+		status = excluded.status, complete = excluded.complete, date = excluded.date, due = excluded.due,
+		completed_at = excluded.completed_at, priority = excluded.priority, tags = excluded.tags,
+		last_seen = excluded.last_seen, removed_at = NULL`, `
+DROP TABLE scan`,
+	} {
+		if _, err := tx.Exec(statement, at); err != nil {
+			return fmt.Errorf("store %s: %w", dbPath, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("store %s: %w", dbPath, err)
+	}
+	return nil
+}
+
+// storeHistory returns when the tasks in the store were checked off or
+// reopened, oldest first, read from its transitions.
+func storeHistory(dbPath string) ([]tasks.Completion, error) {
+	db, err := openStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT file, text, to_status, at FROM transitions
+	WHERE (from_status = 'done' AND to_status != 'removed') OR (from_status IS NOT NULL AND to_status = 'done')
+	ORDER BY at, rowid`)
+	if err != nil {
+		return nil, fmt.Errorf("store %s: %w", dbPath, err)
+	}
+	defer rows.Close()
+
+	history := []tasks.Completion{}
+	for rows.Next() {
+		var file, text, toStatus, at string
+		if err := rows.Scan(&file, &text, &toStatus, &at); err != nil {
+			return nil, fmt.Errorf("store %s: %w", dbPath, err)
+		}
+		parsed, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, tasks.Completion{Complete: toStatus == "done", FilePath: file, Text: text, Time: parsed.In(time.Local)})
+	}
+	return history, rows.Err()
+}
+
+// storeTasks returns the tasks the store last found, without those since
+// removed, in order of file and line. Their dates, statuses, priorities, and
+// tags are as they were found, and @tags assign them, but subtasks aren't
+// nested under their parents.
+func storeTasks(dbPath string) ([]tasks.Task, error) {
+	db, err := openStore(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT id, file, line, text, status, complete, date, due, completed_at, priority, tags FROM tasks
+	WHERE removed_at IS NULL ORDER BY file, line`)
+	if err != nil {
+		return nil, fmt.Errorf("store %s: %w", dbPath, err)
+	}
+	defer rows.Close()
+
+	// dates are written by updateStore, so they always parse
+	parseTime := func(value sql.NullString) *time.Time {
+		if !value.Valid {
+			return nil
+		}
+		parsed, err := time.Parse(time.RFC3339, value.String)
+		if err != nil {
+			return nil
+		}
+		return &parsed
+	}
+	found := []tasks.Task{}
+	for rows.Next() {
+		task := tasks.Task{}
+		var status, priority, tags string
+		var date, due, completedAt sql.NullString
+		if err := rows.Scan(&task.ID, &task.FilePath, &task.Line, &task.Text, &status, &task.Complete, &date, &due, &completedAt, &priority, &tags); err != nil {
+			return nil, fmt.Errorf("store %s: %w", dbPath, err)
+		}
+		if err := task.Status.UnmarshalText([]byte(status)); err != nil {
+			return nil, fmt.Errorf("store %s: %w", dbPath, err)
+		}
+		if err := task.Priority.UnmarshalText([]byte(priority)); err != nil {
+			return nil, fmt.Errorf("store %s: %w", dbPath, err)
+		}
+		task.CompletedAt = parseTime(completedAt)
+		task.Due = parseTime(due)
+		if parsed := parseTime(date); parsed != nil {
+			task.Date = *parsed
+		}
+		task.Tags = strings.Fields(tags)
+		for _, tag := range task.Tags {
+			if strings.HasPrefix(tag, "@") {
+				task.Assignees = append(task.Assignees, tag[1:])
+			}
+		}
+		found = append(found, task)
+	}
+	return found, rows.Err()
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const commandSync = "sync"
+
+func setupSync(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	flags.StringVar(&options.OutputFilename, "o", tasks.DefaultOutputFilename, fmt.Sprintf("name of the markdown report to read changes from (default=%s)", tasks.DefaultOutputFilename))
+	flags.BoolVar(&options.RelativeLinks, "relative-links", false, "true if the report was written with -relative-links (default=false)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		return syncReport(options)
+	}
+}
+
+// syncReport writes checkbox changes made in the markdown report, including
+// status marks such as [/] and [-], back to the tasks' source files. Tasks are
+// matched by ID, or when the source has changed since the report was written,
+// by file and text; when several source tasks share the text, the first whose
+// checkbox differs is changed.
+func syncReport(options Options) error {
+	report, err := os.Open(options.OutputFilename)
+	if err != nil {
+		return err
+	}
+	defer report.Close()
+
+	reported, err := tasks.ParseMarkdown(report)
+	if err != nil {
+		return err
+	}
+
+	files, err := sourceFiles(options)
+	if err != nil {
+		return err
+	}
+	linkBase, err := options.linkBase()
+	if err != nil {
+		return err
+	}
+
+	// editor links give the absolute path of the source file
+	sourceBase, _ := options.sourceBase()
+
+	// the source is read as the report's tasks were, but TODO comments can't
+	// be checked off where they're written, so they aren't matched
+	parseOptions := options.parseOptions()
+	parseOptions.TodoKeywords = nil
+
+	changed := 0
+	for _, reportedTask := range reported {
+		base := linkBase
+		if filepath.IsAbs(reportedTask.FilePath) {
+			base = sourceBase
+		}
+		displayPath, err := filepath.Rel(base, reportedTask.FilePath)
+		if err != nil {
+			continue
+		}
+		file, ok := files[filepath.ToSlash(displayPath)]
+		if !ok {
+			continue
+		}
+		parsed, err := tasks.ParseFilePath(file, parseOptions)
+		// tasks on lines too long to read are left as they are
+		var longLines *tasks.LongLinesError
+		if err != nil && !errors.As(err, &longLines) {
+			return err
+		}
+
+		for _, task := range matchingTasks(reportedTask, tasks.Flatten(parsed.Tasks)) {
+			if task.Status == reportedTask.Status {
+				continue
+			}
+			if err := tasks.SetStatus(file.Path, task.Line, reportedTask.Status); err != nil {
+				return err
+			}
+			fmt.Printf("[%s] %s (%s:%d)\n", reportedTask.Status.Symbol(), task.Text, file.Path, task.Line)
+			changed++
+			break
+		}
+	}
+
+	fmt.Printf("%d tasks updated from '%s'\n", changed, options.OutputFilename)
+	return nil
+}
+
+// matchingTasks returns the source task with the reported task's ID, or
+// otherwise the source tasks with the same text.
+func matchingTasks(reported tasks.Task, source []tasks.Task) []tasks.Task {
+	sameText := []tasks.Task{}
+	for _, task := range source {
+		if reported.ID != "" && task.ID == reported.ID {
+			return []tasks.Task{task}
+		}
+		if task.Text == reported.Text {
+			sameText = append(sameText, task)
+		}
+	}
+	return sameText
+}
+
+// sourceFiles maps the paths written in reports to the markdown files under
+// the roots.
+func sourceFiles(options Options) (map[string]tasks.FileMeta, error) {
+	files := map[string]tasks.FileMeta{}
+	for _, root := range options.Roots {
+		if root == stdinPath {
+			return nil, fmt.Errorf("sync can't write changes back to stdin")
+		}
+		err := tasks.WalkMarkdownFiles(root, options.walkOptions(), func(file tasks.FileMeta) error {
+			file.DisplayPath = file.RelativePath(len(options.Roots) > 1)
+			files[file.DisplayPath] = file
+			return nil
+		})
+		if err := logSkipped(err); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	commandSyncTodoist    = "sync todoist"
+	defaultTodoistAPI     = "https://api.todoist.com/rest/v2"
+	defaultTodoistProject = "Inbox"
+	// todoistStateFilename records the tasks pushed to Todoist, since tasks
+	// completed there are no longer listed by its API.
+	todoistStateFilename = ".task-aggregator-todoist.json"
+	// todoistTokenVariable is the environment variable holding the token used
+	// to call the Todoist API.
+	todoistTokenVariable = "TODOIST_TOKEN"
+)
+
+// todoistMarkerPattern matches the line in a Todoist task's description
+// recording the task it was pushed for.
+var todoistMarkerPattern = regexp.MustCompile(`(?m)^task id:(\w+) file:(.*) line:(\d+)$`)
+
+// todoistPriorities maps priorities to Todoist's, from 1 for normal to 4 for
+// urgent.
+var todoistPriorities = map[tasks.Priority]int{
+	tasks.PriorityNone:    1,
+	tasks.PriorityLowest:  1,
+	tasks.PriorityLow:     1,
+	tasks.PriorityMedium:  2,
+	tasks.PriorityHigh:    3,
+	tasks.PriorityHighest: 4,
+}
+
+// TodoistOptions are the flag values for syncing tasks to Todoist.
+type TodoistOptions struct {
+	APIURL  string
+	DryRun  bool
+	Project string
+	Pull    bool
+}
+
+// todoistTask is the part of a Todoist task that syncing reads and writes.
+type todoistTask struct {
+	Content     string `json:"content"`
+	Description string `json:"description"`
+	Due         *struct {
+		Date string `json:"date"`
+	} `json:"due"`
+	ID       string   `json:"id"`
+	Labels   []string `json:"labels"`
+	Priority int      `json:"priority"`
+}
+
+// todoistPushed is a task pushed to Todoist, recorded by the source task's
+// ID along with where it was and its text to recognize it once its ID has
+// changed.
+type todoistPushed struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Text      string `json:"text"`
+	TodoistID string `json:"todoistId"`
+}
+
+// todoistClient calls the Todoist REST API.
+type todoistClient struct {
+	apiURL string
+	token  string
+}
+
+func setupSyncTodoist(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	todoistOptions := TodoistOptions{}
+	defineScanFlags(flags, &options)
+	flags.StringVar(&todoistOptions.APIURL, "api-url", defaultTodoistAPI, fmt.Sprintf("Todoist REST API to call (default=%s)", defaultTodoistAPI))
+	flags.BoolVar(&todoistOptions.DryRun, "dry-run", false, "true to print the changes that would be made without making them (default=false)")
+	flags.StringVar(&todoistOptions.Project, "project", defaultTodoistProject, fmt.Sprintf("name of the Todoist project to add tasks to (default=%s)", defaultTodoistProject))
+	flags.BoolVar(&todoistOptions.Pull, "pull", false, "true to also check off the source tasks of tasks completed in Todoist (default=false)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		client := todoistClient{apiURL: strings.TrimSuffix(todoistOptions.APIURL, "/"), token: os.Getenv(todoistTokenVariable)}
+		if client.token == "" {
+			return fmt.Errorf("%s must be set to a Todoist API token", todoistTokenVariable)
+		}
+		return syncTodoist(options, client, todoistOptions)
+	}
+}
+
+// syncTodoist adds each incomplete task to the Todoist project, with its due
+// date, priority, and tags as labels, and keeps the tasks added before in
+// step: they're closed when their source task is completed or cancelled, and
+// their due date, priority, and labels updated when those change. With Pull,
+// source tasks whose Todoist task was completed are checked off. Todoist
+// tasks are matched to source tasks by the ID recorded in their description,
+// or when the source has changed since, by file and either text or line, so
+// tasks moved or edited in place are still recognized.
+func syncTodoist(options Options, client todoistClient, todoistOptions TodoistOptions) error {
+	projectID, err := client.projectID(todoistOptions.Project)
+	if err != nil {
+		return err
+	}
+	active := []todoistTask{}
+	if err := client.request(http.MethodGet, "/tasks?project_id="+url.QueryEscape(projectID), nil, &active); err != nil {
+		return err
+	}
+	pushed, err := loadTodoistState(todoistStateFilename)
+	if err != nil {
+		return err
+	}
+	files := map[string]tasks.FileMeta{}
+	if todoistOptions.Pull {
+		if files, err = sourceFiles(options); err != nil {
+			return err
+		}
+	}
+
+	collected, err := collect(options)
+	if err != nil {
+		return err
+	}
+	all := tasks.Flatten(collected.Tasks)
+	ids := map[string]bool{}
+	for _, task := range all {
+		ids[task.ID] = true
+	}
+	matched := map[string]bool{}
+	activeFor := func(task tasks.Task) *todoistTask {
+		for i, remote := range active {
+			match := todoistMarkerPattern.FindStringSubmatch(remote.Description)
+			if match == nil || matched[remote.ID] {
+				continue
+			}
+			if match[1] == task.ID || (!ids[match[1]] && match[2] == task.FilePath && (remote.Content == task.Text || match[3] == strconv.Itoa(task.Line))) {
+				return &active[i]
+			}
+		}
+		return nil
+	}
+	pushedFor := func(task tasks.Task) (todoistPushed, bool) {
+		if entry, ok := pushed[task.ID]; ok {
+			return entry, true
+		}
+		for id, entry := range pushed {
+			if !ids[id] && entry.File == task.FilePath && (entry.Text == task.Text || entry.Line == task.Line) {
+				return entry, true
+			}
+		}
+		return todoistPushed{}, false
+	}
+
+	prefix := ""
+	if todoistOptions.DryRun {
+		prefix = "would have "
+	}
+	nextPushed := map[string]todoistPushed{}
+	added, updated, closed, pulled := 0, 0, 0, 0
+	for _, task := range all {
+		open := !task.Complete && !task.Cancelled()
+		remote := activeFor(task)
+		entry, wasPushed := pushedFor(task)
+
+		switch {
+		case remote != nil:
+			matched[remote.ID] = true
+			if !open {
+				fmt.Printf("%sclosed: %s (%s:%d)\n", prefix, task.Text, task.FilePath, task.Line)
+				closed++
+				if !todoistOptions.DryRun {
+					if err := client.request(http.MethodPost, "/tasks/"+remote.ID+"/close", nil, nil); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			nextPushed[task.ID] = todoistPushed{File: task.FilePath, Line: task.Line, Text: task.Text, TodoistID: remote.ID}
+			change := todoistChange(task, *remote)
+			if len(change) == 0 {
+				continue
+			}
+			fmt.Printf("%supdated: %s (%s:%d)\n", prefix, task.Text, task.FilePath, task.Line)
+			updated++
+			if !todoistOptions.DryRun {
+				if err := client.request(http.MethodPost, "/tasks/"+remote.ID, change, nil); err != nil {
+					return err
+				}
+			}
+		case wasPushed:
+			// pushed before but no longer active, so completed or deleted in
+			// Todoist
+			if !open || !todoistOptions.Pull {
+				if open {
+					nextPushed[task.ID] = todoistPushed{File: task.FilePath, Line: task.Line, Text: task.Text, TodoistID: entry.TodoistID}
+				}
+				continue
+			}
+			file, ok := files[task.FilePath]
+			if !ok {
+				continue
+			}
+			fmt.Printf("%schecked off: %s (%s:%d)\n", prefix, task.Text, file.Path, task.Line)
+			pulled++
+			if !todoistOptions.DryRun {
+				if err := tasks.SetStatus(file.Path, task.Line, tasks.StatusDone); err != nil {
+					return err
+				}
+			}
+		case open:
+			fmt.Printf("%sadded: %s (%s:%d)\n", prefix, task.Text, task.FilePath, task.Line)
+			added++
+			if todoistOptions.DryRun {
+				continue
+			}
+			created := todoistTask{}
+			if err := client.request(http.MethodPost, "/tasks", todoistNewTask(task, projectID), &created); err != nil {
+				return err
+			}
+			nextPushed[task.ID] = todoistPushed{File: task.FilePath, Line: task.Line, Text: task.Text, TodoistID: created.ID}
+		}
+	}
+
+	if !todoistOptions.DryRun {
+		saveTodoistState(todoistStateFilename, nextPushed)
+	}
+	fmt.Printf("%d tasks %sadded, %d updated, %d closed, and %d checked off from Todoist project '%s'\n", added, prefix, updated, closed, pulled, todoistOptions.Project)
+	return nil
+}
+
+// todoistNewTask is the request body adding the task to the project.
+func todoistNewTask(task tasks.Task, projectID string) map[string]interface{} {
+	body := map[string]interface{}{
+		"content":     task.Text,
+		"description": todoistDescription(task),
+		"labels":      todoistLabels(task),
+		"priority":    todoistPriorities[task.Priority],
+		"project_id":  projectID,
+	}
+	if task.Due != nil {
+		body["due_date"] = task.Due.Format(yearMonthDayLayout)
+	}
+	return body
+}
+
+// todoistChange is the request body updating the Todoist task to match its
+// source task, or empty when they already match. The content is only
+// replaced when the source task's text was edited, and labels are only
+// added, so changes made to them in Todoist stay.
+func todoistChange(task tasks.Task, remote todoistTask) map[string]interface{} {
+	change := map[string]interface{}{}
+	if match := todoistMarkerPattern.FindStringSubmatch(remote.Description); match != nil && match[1] != task.ID && remote.Content != task.Text {
+		change["content"] = task.Text
+	}
+	if description := todoistDescription(task); remote.Description != description {
+		change["description"] = description
+	}
+	if priority := todoistPriorities[task.Priority]; remote.Priority != priority {
+		change["priority"] = priority
+	}
+	if task.Due != nil && (remote.Due == nil || remote.Due.Date != task.Due.Format(yearMonthDayLayout)) {
+		change["due_date"] = task.Due.Format(yearMonthDayLayout)
+	}
+	labels := append([]string{}, remote.Labels...)
+	for _, label := range todoistLabels(task) {
+		if !contains(labels, label) {
+			labels = append(labels, label)
+			change["labels"] = labels
+		}
+	}
+	return change
+}
+
+// todoistDescription is the Todoist task description for the task, naming
+// where it's found and recording its ID to find the task again.
+func todoistDescription(task tasks.Task) string {
+	description := fmt.Sprintf("From `%s` line %d", task.FilePath, task.Line)
+	if task.PreviousHeader != "" {
+		description += fmt.Sprintf(", under %s", task.PreviousHeader)
+	}
+	return fmt.Sprintf("%s.\n\ntask id:%s file:%s line:%d", description, task.ID, task.FilePath, task.Line)
+}
+
+// todoistLabels are the task's tags without their # or @, sorted without
+// duplicates.
+func todoistLabels(task tasks.Task) []string {
+	labels := []string{}
+	for _, tag := range task.Tags {
+		if label := tag[1:]; !contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// loadTodoistState reads the tasks pushed to Todoist by earlier runs, keyed
+// by their source task's ID. A missing file means none were.
+func loadTodoistState(filename string) (map[string]todoistPushed, error) {
+	pushed := map[string]todoistPushed{}
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return pushed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &pushed); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return pushed, nil
+}
+
+func saveTodoistState(filename string, pushed map[string]todoistPushed) {
+	data, err := json.Marshal(pushed)
+	if err != nil {
+		slog.Warn("can't save Todoist state", "error", err)
+		return
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		slog.Warn("can't save Todoist state", "error", err)
+	}
+}
+
+// projectID returns the ID of the project with the name.
+func (client todoistClient) projectID(name string) (string, error) {
+	projects := []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}{}
+	if err := client.request(http.MethodGet, "/projects", nil, &projects); err != nil {
+		return "", err
+	}
+	for _, project := range projects {
+		if project.Name == name {
+			return project.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no Todoist project named '%s'", name)
+}
+
+// request calls the API at the path, sending body as JSON unless it's nil and
+// reading the response into result unless it's nil.
+func (client todoistClient) request(method, path string, body, result interface{}) error {
+	_, err := apiRequest(method, client.apiURL+path, client.token, body, result)
+	return err
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+)
+
+const (
+	commandTUI = "tui"
+	// browserChrome is the number of lines the browser uses around the list.
+	browserChrome = 3
+	// defaultEditor opens files when $EDITOR isn't set.
+	defaultEditor = "vi"
+)
+
+var (
+	cursorStyle = lipgloss.NewStyle().Reverse(true)
+	doneStyle   = lipgloss.NewStyle().Faint(true).Strikethrough(true)
+	helpStyle   = lipgloss.NewStyle().Faint(true)
+	pathStyle   = lipgloss.NewStyle().Faint(true)
+)
+
+// browserItem is a task listed in the browser, indented to its depth.
+type browserItem struct {
+	depth int
+	task  tasks.Task
+}
+
+// browser is the interactive task list run by tui.
+type browser struct {
+	cursor int
+	files  map[string]tasks.FileMeta
+	height int
+	items  []browserItem
+	// listed are the indexes of the items matching the query, in order.
+	listed    []int
+	message   string
+	offset    int
+	options   Options
+	query     string
+	searching bool
+}
+
+// editorFinishedMsg reports that the editor opened on a task was closed.
+type editorFinishedMsg struct {
+	err error
+}
+
+func setupTUI(flags *flag.FlagSet) func(args []string) error {
+	options := Options{}
+	defineScanFlags(flags, &options)
+	flags.BoolVar(&options.OutputCompleted, "c", false, "true to list completed tasks, which can also be toggled with c (default=false)")
+	flags.BoolVar(&options.ShowCancelled, "show-cancelled", false, "true to list cancelled tasks, marked [-] (default=false)")
+
+	return func(args []string) error {
+		if err := options.prepare(args); err != nil {
+			return err
+		}
+		files, err := sourceFiles(options)
+		if err != nil {
+			return err
+		}
+
+		model := &browser{files: files, options: options}
+		if err := model.load(); err != nil {
+			return err
+		}
+		_, err = tea.NewProgram(model, tea.WithAltScreen()).Run()
+		return err
+	}
+}
+
+// load scans the roots again and lists the tasks matching the query. When the
+// scan fails, as it can with -strict, the tasks listed before are kept and the
+// error is shown.
+func (model *browser) load() error {
+	found, err := collect(model.options)
+	if err != nil {
+		model.message = err.Error()
+		return err
+	}
+	model.items = nil
+	var add func(all []tasks.Task, depth int)
+	add = func(all []tasks.Task, depth int) {
+		for _, task := range all {
+			model.items = append(model.items, browserItem{depth: depth, task: task})
+			add(task.Subtasks, depth+1)
+		}
+	}
+	add(found.Visible(), 0)
+	model.filter()
+	return nil
+}
+
+// filter lists the items matching every term of the query. Terms like #tag or
+// @tag must be one of the task's tags, file:text must be in its path, and
+// is:status must be its status; other terms are matched fuzzily against its
+// text and path.
+func (model *browser) filter() {
+	model.listed = nil
+	for i, item := range model.items {
+		if matchesQuery(item.task, model.query) {
+			model.listed = append(model.listed, i)
+		}
+	}
+	if model.cursor >= len(model.listed) {
+		model.cursor = len(model.listed) - 1
+	}
+	if model.cursor < 0 {
+		model.cursor = 0
+	}
+	model.scroll()
+}
+
+func matchesQuery(task tasks.Task, query string) bool {
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		switch {
+		case strings.HasPrefix(term, "#") || strings.HasPrefix(term, "@"):
+			found := false
+			for _, tag := range task.Tags {
+				found = found || strings.ToLower(tag) == term
+			}
+			if !found {
+				return false
+			}
+		case strings.HasPrefix(term, "file:"):
+			if !strings.Contains(strings.ToLower(task.FilePath), strings.TrimPrefix(term, "file:")) {
+				return false
+			}
+		case strings.HasPrefix(term, "is:"):
+			if task.Status.String() != strings.TrimPrefix(term, "is:") {
+				return false
+			}
+		default:
+			if !fuzzyMatch(strings.ToLower(task.Text+" "+task.FilePath), term) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fuzzyMatch reports whether the pattern's characters appear in the text in
+// order, though not necessarily together.
+func fuzzyMatch(text, pattern string) bool {
+	remaining := []rune(pattern)
+	for _, c := range text {
+		if len(remaining) == 0 {
+			break
+		}
+		if c == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
+
+// scroll keeps the cursor within the lines shown.
+func (model *browser) scroll() {
+	rows := model.rows()
+	if model.cursor < model.offset {
+		model.offset = model.cursor
+	}
+	if model.cursor >= model.offset+rows {
+		model.offset = model.cursor - rows + 1
+	}
+}
+
+// rows is the number of tasks that fit on the screen.
+func (model *browser) rows() int {
+	if model.height <= browserChrome {
+		return 1
+	}
+	return model.height - browserChrome
+}
+
+// selected returns the task under the cursor.
+func (model *browser) selected() (*browserItem, bool) {
+	if len(model.listed) == 0 {
+		return nil, false
+	}
+	return &model.items[model.listed[model.cursor]], true
+}
+
+func (model *browser) Init() tea.Cmd {
+	return nil
+}
+
+func (model *browser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		model.height = msg.Height
+		model.scroll()
+	case editorFinishedMsg:
+		model.message = ""
+		if msg.err != nil {
+			model.message = msg.err.Error()
+		}
+		model.load()
+	case tea.KeyMsg:
+		if model.searching {
+			return model, model.search(msg)
+		}
+		return model, model.command(msg)
+	}
+	return model, nil
+}
+
+// search edits the query while searching.
+func (model *browser) search(key tea.KeyMsg) tea.Cmd {
+	switch key.Type {
+	case tea.KeyEnter, tea.KeyEsc:
+		model.searching = false
+	case tea.KeyBackspace:
+		if query := []rune(model.query); len(query) > 0 {
+			model.query = string(query[:len(query)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		model.query += string(key.Runes)
+	case tea.KeyCtrlC:
+		return tea.Quit
+	}
+	model.filter()
+	return nil
+}
+
+// command runs the command bound to the key.
+func (model *browser) command(key tea.KeyMsg) tea.Cmd {
+	model.message = ""
+	switch key.String() {
+	case "ctrl+c", "q":
+		return tea.Quit
+	case "up", "k":
+		model.move(-1)
+	case "down", "j":
+		model.move(1)
+	case "pgup", "ctrl+b":
+		model.move(-model.rows())
+	case "pgdown", "ctrl+f":
+		model.move(model.rows())
+	case "home", "g":
+		model.move(-len(model.listed))
+	case "end", "G":
+		model.move(len(model.listed))
+	case "/":
+		model.searching = true
+	case "esc":
+		model.query = ""
+		model.filter()
+	case "c":
+		model.options.OutputCompleted = !model.options.OutputCompleted
+		model.load()
+	case "r":
+		model.load()
+	case " ", "x":
+		model.toggle()
+	case "enter", "e":
+		return model.edit()
+	}
+	return nil
+}
+
+func (model *browser) move(by int) {
+	model.cursor += by
+	if model.cursor >= len(model.listed) {
+		model.cursor = len(model.listed) - 1
+	}
+	if model.cursor < 0 {
+		model.cursor = 0
+	}
+	model.scroll()
+}
+
+// toggle checks off the selected task in its source file, or unchecks it.
+func (model *browser) toggle() {
+	item, ok := model.selected()
+	if !ok {
+		return
+	}
+	file, ok := model.files[item.task.FilePath]
+	if !ok {
+		model.message = fmt.Sprintf("can't find '%s' to change", item.task.FilePath)
+		return
+	}
+	status := tasks.StatusDone
+	if item.task.Complete {
+		status = tasks.StatusOpen
+	}
+	if err := tasks.SetStatus(file.Path, item.task.Line, status); err != nil {
+		model.message = err.Error()
+		return
+	}
+	// the task stays listed until the list is reloaded, so it can be toggled
+	// back
+	item.task.Status = status
+	item.task.Complete = status == tasks.StatusDone
+}
+
+// edit opens the selected task's file in $EDITOR at the task's line.
+func (model *browser) edit() tea.Cmd {
+	item, ok := model.selected()
+	if !ok {
+		return nil
+	}
+	file, ok := model.files[item.task.FilePath]
+	if !ok {
+		model.message = fmt.Sprintf("can't find '%s' to open", item.task.FilePath)
+		return nil
+	}
+	return tea.ExecProcess(editorCommand(file.Path, item.task.Line), func(err error) tea.Msg {
+		return editorFinishedMsg{err: err}
+	})
+}
+
+// editorCommand opens the file at the line in $EDITOR, which may include
+// arguments. Editors are passed +line, as vi, emacs, nano, and most others
+// accept, except VS Code, which is passed -g file:line.
+func editorCommand(filePath string, line int) *exec.Cmd {
+	args := strings.Fields(os.Getenv("EDITOR"))
+	if len(args) == 0 {
+		args = []string{defaultEditor}
+	}
+	switch strings.TrimSuffix(filepath.Base(args[0]), ".exe") {
+	case "code", "code-insiders", "codium":
+		args = append(args, "-g", fmt.Sprintf("%s:%d", filePath, line))
+	default:
+		args = append(args, "+"+strconv.Itoa(line), filePath)
+	}
+	return exec.Command(args[0], args[1:]...)
+}
+
+func (model *browser) View() string {
+	var out strings.Builder
+	incomplete := 0
+	for _, i := range model.listed {
+		if !model.items[i].task.Complete {
+			incomplete++
+		}
+	}
+	query := model.query
+	if model.searching {
+		query += "█"
+	}
+	fmt.Fprintf(&out, "%d tasks, %d incomplete   /%s\n", len(model.listed), incomplete, query)
+
+	end := model.offset + model.rows()
+	if end > len(model.listed) {
+		end = len(model.listed)
+	}
+	for row := model.offset; row < end; row++ {
+		item := model.items[model.listed[row]]
+		text := item.task.Text
+		if item.task.Complete || item.task.Cancelled() {
+			text = doneStyle.Render(text)
+		}
+		line := fmt.Sprintf("%s[%s] %s  %s", strings.Repeat("  ", item.depth), item.task.Status.Symbol(), text, pathStyle.Render(fmt.Sprintf("%s:%d", item.task.FilePath, item.task.Line)))
+		if row == model.cursor {
+			line = cursorStyle.Render(line)
+		}
+		out.WriteString(line + "\n")
+	}
+	for row := end - model.offset; row < model.rows(); row++ {
+		out.WriteString("\n")
+	}
+
+	if model.message != "" {
+		out.WriteString(model.message + "\n")
+	} else {
+		out.WriteString("\n")
+	}
+	out.WriteString(helpStyle.Render("↑/↓ move  / search (#tag file:name is:status)  space toggle  enter edit  c completed  r reload  q quit"))
+	return out.String()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/feckmore/markdown-task-aggregator/pkg/tasks"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long to wait after the last change before
+// regenerating, so a burst of saves results in a single rewrite.
+const watchDebounce = 500 * time.Millisecond
+
+// watch monitors the roots and calls onChange whenever a markdown file is
+// created, modified, renamed, or deleted, ignoring changes to the output
+// files. It only returns when stop is closed, which a nil stop never is, or
+// if the watcher is.
+func watch(options Options, outputFilenames []string, onChange func(), stop <-chan struct{}) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fail(err)
+	}
+	defer watcher.Close()
+
+	watchRoots(watcher, options)
+
+	outputPaths := []string{}
+	for _, filename := range outputFilenames {
+		outputPath, err := filepath.Abs(filename)
+		if err != nil {
+			fail(err)
+		}
+		outputPaths = append(outputPaths, outputPath)
+	}
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// the roots are walked again so the new directory is
+					// only watched when the scan would read it
+					watchRoots(watcher, options)
+					debounce.Reset(watchDebounce)
+					continue
+				}
+			}
+			if eventPath, err := filepath.Abs(event.Name); err != nil || contains(outputPaths, eventPath) {
+				continue
+			}
+			if tasks.IsNoteFile(event.Name) {
+				debounce.Reset(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("watch failed", "error", err)
+		case <-debounce.C:
+			onChange()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// watchRoots adds every directory the scan walks under the roots to the
+// watcher, since fsnotify doesn't watch recursively, leaving out .git and
+// the directories excluded by -exclude or ignore files. Directories already
+// watched are left as they are.
+func watchRoots(watcher *fsnotify.Watcher, options Options) {
+	for _, root := range options.Roots {
+		err := tasks.WalkDirectories(root, options.walkOptions(), func(dirPath string) {
+			if err := watcher.Add(dirPath); err != nil {
+				slog.Warn("can't watch directory", "dir", dirPath, "error", err)
+			}
+		})
+		// paths the walk skipped are logged by the scan
+		var walkErr *tasks.WalkError
+		if err != nil && !errors.As(err, &walkErr) {
+			slog.Warn("can't watch directory", "dir", root, "error", err)
+		}
+	}
+}